@@ -4,6 +4,7 @@ go 1.22.0
 
 require (
 	github.com/jhump/protoreflect v1.17.0
+	github.com/joyme123/protocol v0.12.0-patch20250429
 	github.com/joyme123/thrift-ls v0.2.9
 	github.com/mozillazg/go-pinyin v0.21.0
 	github.com/stretchr/testify v1.10.0
@@ -17,7 +18,6 @@ require (
 	github.com/cloudwego/thriftgo v0.2.11 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang/protobuf v1.5.4 // indirect
-	github.com/joyme123/protocol v0.12.0-patch20250429 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/segmentio/asm v1.1.3 // indirect
 	github.com/segmentio/encoding v0.3.4 // indirect
@@ -1,6 +1,10 @@
 package idl_ast
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
 
 // -----------------------------------------------------------------------------
 // 新增的、用于表示复杂常量值的核心结构
@@ -15,9 +19,24 @@ type ConstantMapEntry struct {
 	Value *ConstantValue `json:"value"`
 }
 
+// ConstantKind 描述 ConstantValue 的种类。
+type ConstantKind string
+
+const (
+	ConstantString     ConstantKind = "string"     // Value 是保留引号的字符串字面量，例如 `"OK"`
+	ConstantInt        ConstantKind = "int"        // Value 是 int64
+	ConstantDouble     ConstantKind = "double"     // Value 是 float64
+	ConstantBool       ConstantKind = "bool"       // Value 是 bool
+	ConstantIdentifier ConstantKind = "identifier" // Value 是标识符文本，例如 `Status.OK`
+	ConstantList       ConstantKind = "list"       // Value 是 []*ConstantValue
+	ConstantMap        ConstantKind = "map"        // Value 是 []*ConstantMapEntry
+)
+
 // ConstantValue 是一个可以表示任何常量值的递归结构。
 // 它可以是简单字面量、标识符、列表，或 Map/Message 字面量。
 type ConstantValue struct {
+	// Kind 是值的种类。解析器总是会填充它；其他来源构造的值可以留空，见 InferKind。
+	Kind ConstantKind `json:"kind,omitempty"`
 	// Value 字段的实际类型决定了它的种类：
 	// - string: 字符串字面量 (例如 "hello") 或 标识符 (例如 Status.OK)
 	// - int64: 整数
@@ -26,6 +45,38 @@ type ConstantValue struct {
 	// - []*ConstantValue: 列表/数组 (例如 [1, 2, 3])
 	// - []*ConstantMapEntry: Map 或 Message (例如 { "key": "value", info: {...} })
 	Value any `json:"value"`
+	// FullyQualifiedName 是标识符引用的目标，例如 `base.thrift#Status.OK` 或 `main.thrift#LIMIT`，
+	// 只在 Kind 为 ConstantIdentifier 且能解析时填充，见 IDLSchema.ResolveConstantReferences。
+	FullyQualifiedName string `json:"fullyQualifiedName,omitempty"`
+}
+
+// InferKind 返回 cv 的种类。Kind 非空时直接返回它，否则根据 Value 的 Go 类型推断，
+// 其中字符串按是否带引号区分字面量和标识符。无法判断时返回空字符串。
+func (cv *ConstantValue) InferKind() ConstantKind {
+	if cv == nil {
+		return ""
+	}
+	if cv.Kind != "" {
+		return cv.Kind
+	}
+	switch v := cv.Value.(type) {
+	case string:
+		if isQuoted(v) {
+			return ConstantString
+		}
+		return ConstantIdentifier
+	case int64, int:
+		return ConstantInt
+	case float64:
+		return ConstantDouble
+	case bool:
+		return ConstantBool
+	case []*ConstantValue:
+		return ConstantList
+	case []*ConstantMapEntry:
+		return ConstantMap
+	}
+	return ""
 }
 
 func (cv *ConstantValue) StringValue() (string, error) {
@@ -62,10 +113,38 @@ func (cv *ConstantValue) MustStringValueWithQuote() string {
 	return ret
 }
 
+// String 返回值的紧凑文本形式，例如 `"OK"`、`10`、`[1, 2]` 或 `{"a": Status.OK}`，
+// 用于报告和差异描述。字符串保留原有的引号，nil 返回空字符串。
+func (cv *ConstantValue) String() string {
+	if cv == nil {
+		return ""
+	}
+	switch v := cv.Value.(type) {
+	case nil:
+		return ""
+	case []*ConstantValue:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []*ConstantMapEntry:
+		items := make([]string, len(v))
+		for i, entry := range v {
+			items[i] = entry.Key.String() + ": " + entry.Value.String()
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
 // Annotation 代表一个元数据注解或选项。
 // 例如：Thrift 的 `(api.get="/hello")` 或 Protobuf 的 `option (api.get) = "/hello";`
 type Annotation struct {
-	Name  string         `json:"name"` // 例如 "api.get" 或 "(google.api.http).get"
+	Name string `json:"name"` // 例如 "api.get" 或 "(google.api.http).get"
+	// Value 为 nil 表示没有值的注解，例如 `(cpp.bare)`。字符串值保留源码中的引号和转义，例如 `"a\"b"`，
+	// 同名注解可以出现多次，按源码顺序保存在切片中。
 	Value *ConstantValue `json:"value,omitempty"`
 }
 
@@ -78,12 +157,19 @@ type IDLSchema struct {
 	SchemaVersion string `json:"schemaVersion"`
 	IDLType       string `json:"idlType"` // 例如 "thrift" 或 "protobuf"
 	Files         []File `json:"files"`
+
+	// version 在 Files 的结构发生变化时递增：Rename、Move、Prune、thriftparser 的增量更新和 Reindex 都会递增它。
+	version uint64
+	// index 保存 FindByFQN 等查询使用的 *definitionIndex，在首次查询时构建，版本与 version 不一致时重建。
+	// 这里只保存指针而不是锁，复制 IDLSchema 不会复制锁。
+	index atomic.Value
 }
 
 // File 代表一个独立的 IDL 文件及其完整内容。
 type File struct {
 	Path        string       `json:"path"`
 	Location    *Location    `json:"location,omitempty"`
+	Content     string       `json:"content,omitempty"` // 文件的原始源代码，各节点 Location 中的偏移量都指向它
 	Imports     []Import     `json:"imports,omitempty"`
 	Syntax      string       `json:"syntax,omitempty"`
 	Definitions Definitions  `json:"definitions"`
@@ -98,6 +184,9 @@ type Definitions struct {
 	Enums     []Enum     `json:"enums,omitempty"`
 	Constants []Constant `json:"constants,omitempty"`
 	Typedefs  []Typedef  `json:"typedefs,omitempty"`
+
+	// Order 记录各定义在源文件中的声明顺序，见 Ordered。为空时按种类分组的顺序处理。
+	Order []DefinitionRef `json:"order,omitempty"`
 }
 
 // Import 代表一条导入语句，如 'include "shared.thrift"'。
@@ -125,6 +214,7 @@ type Service struct {
 	Comments           []Comment    `json:"comments,omitempty"`
 	Location           *Location    `json:"location,omitempty"`
 	Content            string       `json:"content,omitempty"`
+	Checksum           string       `json:"checksum,omitempty"` // 解析时记录的校验和，见 Checksum
 	Name               string       `json:"name"`
 	FullyQualifiedName string       `json:"fullyQualifiedName,omitempty"`
 	Functions          []Function   `json:"functions"`
@@ -137,6 +227,7 @@ type Message struct {
 	Comments           []Comment    `json:"comments,omitempty"`
 	Location           *Location    `json:"location,omitempty"`
 	Content            string       `json:"content,omitempty"`
+	Checksum           string       `json:"checksum,omitempty"` // 解析时记录的校验和，见 Checksum
 	Name               string       `json:"name"`
 	FullyQualifiedName string       `json:"fullyQualifiedName,omitempty"`
 	Type               string       `json:"type"` // "struct", "union", "exception"
@@ -149,6 +240,7 @@ type Enum struct {
 	Comments           []Comment    `json:"comments,omitempty"`
 	Location           *Location    `json:"location,omitempty"`
 	Content            string       `json:"content,omitempty"`
+	Checksum           string       `json:"checksum,omitempty"` // 解析时记录的校验和，见 Checksum
 	Name               string       `json:"name"`
 	FullyQualifiedName string       `json:"fullyQualifiedName,omitempty"`
 	Values             []EnumValue  `json:"values"`
@@ -157,26 +249,29 @@ type Enum struct {
 
 // Constant 定义了一个具名常量。
 type Constant struct {
+	Comments           []Comment      `json:"comments,omitempty"`
+	Location           *Location      `json:"location,omitempty"`
+	Content            string         `json:"content,omitempty"`
+	Checksum           string         `json:"checksum,omitempty"` // 解析时记录的校验和，见 Checksum
+	Name               string         `json:"name"`
+	FullyQualifiedName string         `json:"fullyQualifiedName,omitempty"`
+	Type               Type           `json:"type"`
+	Value              *ConstantValue `json:"value"`
+	Annotations        []Annotation   `json:"annotations,omitempty"`
+}
+
+// Typedef 定义了一个类型别名。
+type Typedef struct {
 	Comments           []Comment    `json:"comments,omitempty"`
 	Location           *Location    `json:"location,omitempty"`
 	Content            string       `json:"content,omitempty"`
-	Name               string       `json:"name"`
+	Checksum           string       `json:"checksum,omitempty"` // 解析时记录的校验和，见 Checksum
+	Alias              string       `json:"alias"`
 	FullyQualifiedName string       `json:"fullyQualifiedName,omitempty"`
 	Type               Type         `json:"type"`
-	Value              string       `json:"value"` // 值的字符串表示 (为了简单兼容旧逻辑，未来可替换为 ConstantValue)
 	Annotations        []Annotation `json:"annotations,omitempty"`
 }
 
-// Typedef 定义了一个类型别名。
-type Typedef struct {
-	Comments    []Comment    `json:"comments,omitempty"`
-	Location    *Location    `json:"location,omitempty"`
-	Content     string       `json:"content,omitempty"`
-	Alias       string       `json:"alias"`
-	Type        Type         `json:"type"`
-	Annotations []Annotation `json:"annotations,omitempty"`
-}
-
 // -----------------------------------------------------------------------------
 // 子级定义和核心构建块 (已更新)
 // -----------------------------------------------------------------------------
@@ -192,6 +287,7 @@ type Function struct {
 	Parameters         []Field      `json:"parameters"`
 	Throws             []Field      `json:"throws,omitempty"`
 	Annotations        []Annotation `json:"annotations,omitempty"`
+	Oneway             bool         `json:"oneway,omitempty"` // 是否使用 oneway 关键字声明
 }
 
 // Field 定义了消息体中的一个字段或函数的参数。
@@ -223,6 +319,23 @@ type Type struct {
 	FullyQualifiedName string    `json:"fullyQualifiedName,omitempty"`
 	KeyType            *Type     `json:"keyType,omitempty"`
 	ValueType          *Type     `json:"valueType,omitempty"`
+	// Unresolved 表示这是一个引用了其它定义的类型，但找不到被引用的定义（例如定义所在的文件不存在或无法解析），
+	// 此时 FullyQualifiedName 为空。
+	Unresolved bool `json:"unresolved,omitempty"`
+}
+
+// String 返回类型在源码中的写法，例如 `map<string,list<base.Entity>>`，nil 返回空字符串。
+func (t *Type) String() string {
+	if t == nil {
+		return ""
+	}
+	switch t.Name {
+	case "map":
+		return fmt.Sprintf("map<%s,%s>", t.KeyType.String(), t.ValueType.String())
+	case "list", "set":
+		return fmt.Sprintf("%s<%s>", t.Name, t.ValueType.String())
+	}
+	return t.Name
 }
 
 // -----------------------------------------------------------------------------
@@ -0,0 +1,263 @@
+package idl_ast
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+)
+
+// Checksum 返回定义（*Service、*Message、*Enum、*Constant 或 *Typedef）语义内容的 SHA-256 校验和。
+// Location、Content、Signature 和 Checksum 字段不参与计算，因此只要定义本身（包括注释、注解和
+// FullyQualifiedName）没有被修改，校验和就保持不变。def 的类型不受支持，或者常量值中含有
+// ConstantValue 不支持的 Go 类型时返回错误。
+func Checksum(def any) (string, error) {
+	w := &checksumWriter{h: sha256.New()}
+	switch d := def.(type) {
+	case *Service:
+		w.str("service")
+		w.comments(d.Comments)
+		w.str(d.Name)
+		w.str(d.FullyQualifiedName)
+		w.uint(uint64(len(d.Functions)))
+		for i := range d.Functions {
+			w.function(&d.Functions[i])
+		}
+		w.str(d.Extends)
+		w.annotations(d.Annotations)
+	case *Message:
+		w.str("message")
+		w.comments(d.Comments)
+		w.str(d.Name)
+		w.str(d.FullyQualifiedName)
+		w.str(d.Type)
+		w.fields(d.Fields)
+		w.annotations(d.Annotations)
+	case *Enum:
+		w.str("enum")
+		w.comments(d.Comments)
+		w.str(d.Name)
+		w.str(d.FullyQualifiedName)
+		w.uint(uint64(len(d.Values)))
+		for i := range d.Values {
+			v := &d.Values[i]
+			w.comments(v.Comments)
+			w.str(v.Name)
+			w.int(int64(v.Value))
+			w.annotations(v.Annotations)
+		}
+		w.annotations(d.Annotations)
+	case *Constant:
+		w.str("constant")
+		w.comments(d.Comments)
+		w.str(d.Name)
+		w.str(d.FullyQualifiedName)
+		w.typ(&d.Type)
+		w.constant(d.Value)
+		w.annotations(d.Annotations)
+	case *Typedef:
+		w.str("typedef")
+		w.comments(d.Comments)
+		w.str(d.Alias)
+		w.str(d.FullyQualifiedName)
+		w.typ(&d.Type)
+		w.annotations(d.Annotations)
+	default:
+		return "", fmt.Errorf("checksum: unsupported definition type %T", def)
+	}
+	if w.err != nil {
+		return "", w.err
+	}
+	return hex.EncodeToString(w.h.Sum(nil)), nil
+}
+
+// checksumWriter 把定义的语义内容逐字段写入哈希。字符串带长度前缀，切片带元素个数，
+// 指针带是否为 nil 的标记，因此不同的结构不会写出相同的字节序列。err 记录遇到的第一个错误。
+type checksumWriter struct {
+	h   hash.Hash
+	buf [8]byte
+	err error
+}
+
+func (w *checksumWriter) uint(n uint64) {
+	binary.BigEndian.PutUint64(w.buf[:], n)
+	w.h.Write(w.buf[:])
+}
+
+func (w *checksumWriter) int(n int64) {
+	w.uint(uint64(n))
+}
+
+func (w *checksumWriter) bool(b bool) {
+	if b {
+		w.uint(1)
+	} else {
+		w.uint(0)
+	}
+}
+
+func (w *checksumWriter) str(s string) {
+	w.uint(uint64(len(s)))
+	io.WriteString(w.h, s)
+}
+
+func (w *checksumWriter) comments(comments []Comment) {
+	w.uint(uint64(len(comments)))
+	for _, c := range comments {
+		w.str(c.Text)
+	}
+}
+
+func (w *checksumWriter) annotations(annotations []Annotation) {
+	w.uint(uint64(len(annotations)))
+	for i := range annotations {
+		w.str(annotations[i].Name)
+		w.constant(annotations[i].Value)
+	}
+}
+
+func (w *checksumWriter) typ(t *Type) {
+	w.bool(t != nil)
+	if t == nil {
+		return
+	}
+	w.str(t.Name)
+	w.bool(t.IsPrimitive)
+	w.str(t.FullyQualifiedName)
+	w.typ(t.KeyType)
+	w.typ(t.ValueType)
+	w.bool(t.Unresolved)
+}
+
+func (w *checksumWriter) fields(fields []Field) {
+	w.uint(uint64(len(fields)))
+	for i := range fields {
+		f := &fields[i]
+		w.comments(f.Comments)
+		w.int(int64(f.ID))
+		w.str(f.Name)
+		w.typ(&f.Type)
+		w.str(f.Required)
+		w.constant(f.DefaultValue)
+		w.annotations(f.Annotations)
+	}
+}
+
+func (w *checksumWriter) function(f *Function) {
+	w.comments(f.Comments)
+	w.str(f.Name)
+	w.str(f.FullyQualifiedName)
+	w.typ(&f.ReturnType)
+	w.fields(f.Parameters)
+	w.fields(f.Throws)
+	w.annotations(f.Annotations)
+	w.bool(f.Oneway)
+}
+
+func (w *checksumWriter) constant(cv *ConstantValue) {
+	w.bool(cv != nil)
+	if cv == nil {
+		return
+	}
+	w.str(string(cv.Kind))
+	w.str(cv.FullyQualifiedName)
+	switch v := cv.Value.(type) {
+	case nil:
+		w.uint(0)
+	case string:
+		w.uint(1)
+		w.str(v)
+	case int64:
+		w.uint(2)
+		w.int(v)
+	case int:
+		w.uint(2)
+		w.int(int64(v))
+	case float64:
+		w.uint(3)
+		w.uint(math.Float64bits(v))
+	case bool:
+		w.uint(4)
+		w.bool(v)
+	case []*ConstantValue:
+		w.uint(5)
+		w.uint(uint64(len(v)))
+		for _, item := range v {
+			w.constant(item)
+		}
+	case []*ConstantMapEntry:
+		w.uint(6)
+		w.uint(uint64(len(v)))
+		for _, entry := range v {
+			w.bool(entry != nil)
+			if entry != nil {
+				w.constant(entry.Key)
+				w.constant(entry.Value)
+			}
+		}
+	default:
+		if w.err == nil {
+			w.err = fmt.Errorf("checksum: unsupported constant value type %T", cv.Value)
+		}
+	}
+}
+
+// UpdateChecksums 为 file 中的每个顶层定义重新计算 Checksum。解析器在生成 AST 后调用它，
+// 之后可以通过 IsModified 判断定义是否在内存中被修改过。
+func (f *File) UpdateChecksums() error {
+	defs := &f.Definitions
+	var err error
+	update := func(fqn string, def any, checksum *string) {
+		if err != nil {
+			return
+		}
+		if *checksum, err = Checksum(def); err != nil {
+			err = fmt.Errorf("%s: %w", fqn, err)
+		}
+	}
+	for i := range defs.Services {
+		update(defs.Services[i].FullyQualifiedName, &defs.Services[i], &defs.Services[i].Checksum)
+	}
+	for i := range defs.Messages {
+		update(defs.Messages[i].FullyQualifiedName, &defs.Messages[i], &defs.Messages[i].Checksum)
+	}
+	for i := range defs.Enums {
+		update(defs.Enums[i].FullyQualifiedName, &defs.Enums[i], &defs.Enums[i].Checksum)
+	}
+	for i := range defs.Constants {
+		update(defs.Constants[i].FullyQualifiedName, &defs.Constants[i], &defs.Constants[i].Checksum)
+	}
+	for i := range defs.Typedefs {
+		update(defs.Typedefs[i].FullyQualifiedName, &defs.Typedefs[i], &defs.Typedefs[i].Checksum)
+	}
+	return err
+}
+
+// IsModified 判断定义自解析以来是否被修改过。没有记录校验和的定义（例如手动构造的定义）总是被视为已修改；
+// 无法计算校验和时返回错误。
+func IsModified(def any) (bool, error) {
+	var recorded string
+	switch d := def.(type) {
+	case *Service:
+		recorded = d.Checksum
+	case *Message:
+		recorded = d.Checksum
+	case *Enum:
+		recorded = d.Checksum
+	case *Constant:
+		recorded = d.Checksum
+	case *Typedef:
+		recorded = d.Checksum
+	}
+	if recorded == "" {
+		return true, nil
+	}
+	sum, err := Checksum(def)
+	if err != nil {
+		return false, err
+	}
+	return sum != recorded, nil
+}
@@ -0,0 +1,171 @@
+package idl_ast
+
+// Clone 返回 schema 的深拷贝。拷贝与原 schema 不共享任何切片或指针，
+// 修改其中一个不会影响另一个；拷贝会在首次查询时构建自己的索引。
+func (schema *IDLSchema) Clone() *IDLSchema {
+	if schema == nil {
+		return nil
+	}
+	return &IDLSchema{
+		SchemaVersion: schema.SchemaVersion,
+		IDLType:       schema.IDLType,
+		Files:         cloneSlice(schema.Files, cloneFile),
+	}
+}
+
+func cloneSlice[T any](s []T, clone func(T) T) []T {
+	if s == nil {
+		return nil
+	}
+	res := make([]T, len(s))
+	for i := range s {
+		res[i] = clone(s[i])
+	}
+	return res
+}
+
+func cloneFile(f File) File {
+	f.Location = cloneLocation(f.Location)
+	f.Imports = cloneSlice(f.Imports, func(imp Import) Import {
+		imp.Comments = cloneComments(imp.Comments)
+		imp.Location = cloneLocation(imp.Location)
+		return imp
+	})
+	f.Definitions = cloneDefinitions(f.Definitions)
+	f.Namespaces = cloneSlice(f.Namespaces, func(ns Namespace) Namespace {
+		ns.Comments = cloneComments(ns.Comments)
+		ns.Location = cloneLocation(ns.Location)
+		return ns
+	})
+	f.Options = cloneAnnotations(f.Options)
+	return f
+}
+
+func cloneDefinitions(d Definitions) Definitions {
+	d.Services = cloneSlice(d.Services, cloneService)
+	d.Messages = cloneSlice(d.Messages, cloneMessage)
+	d.Enums = cloneSlice(d.Enums, cloneEnum)
+	d.Constants = cloneSlice(d.Constants, cloneConstant)
+	d.Typedefs = cloneSlice(d.Typedefs, cloneTypedef)
+	d.Order = cloneSlice(d.Order, func(ref DefinitionRef) DefinitionRef { return ref })
+	return d
+}
+
+func cloneService(s Service) Service {
+	s.Comments = cloneComments(s.Comments)
+	s.Location = cloneLocation(s.Location)
+	s.Functions = cloneSlice(s.Functions, cloneFunction)
+	s.Annotations = cloneAnnotations(s.Annotations)
+	return s
+}
+
+func cloneFunction(fn Function) Function {
+	fn.Comments = cloneComments(fn.Comments)
+	fn.Location = cloneLocation(fn.Location)
+	fn.ReturnType = cloneType(fn.ReturnType)
+	fn.Parameters = cloneSlice(fn.Parameters, cloneField)
+	fn.Throws = cloneSlice(fn.Throws, cloneField)
+	fn.Annotations = cloneAnnotations(fn.Annotations)
+	return fn
+}
+
+func cloneMessage(m Message) Message {
+	m.Comments = cloneComments(m.Comments)
+	m.Location = cloneLocation(m.Location)
+	m.Fields = cloneSlice(m.Fields, cloneField)
+	m.Annotations = cloneAnnotations(m.Annotations)
+	return m
+}
+
+func cloneField(f Field) Field {
+	f.Comments = cloneComments(f.Comments)
+	f.Location = cloneLocation(f.Location)
+	f.Type = cloneType(f.Type)
+	f.DefaultValue = cloneConstantValue(f.DefaultValue)
+	f.Annotations = cloneAnnotations(f.Annotations)
+	return f
+}
+
+func cloneEnum(e Enum) Enum {
+	e.Comments = cloneComments(e.Comments)
+	e.Location = cloneLocation(e.Location)
+	e.Values = cloneSlice(e.Values, func(v EnumValue) EnumValue {
+		v.Comments = cloneComments(v.Comments)
+		v.Location = cloneLocation(v.Location)
+		v.Annotations = cloneAnnotations(v.Annotations)
+		return v
+	})
+	e.Annotations = cloneAnnotations(e.Annotations)
+	return e
+}
+
+func cloneConstant(c Constant) Constant {
+	c.Comments = cloneComments(c.Comments)
+	c.Location = cloneLocation(c.Location)
+	c.Type = cloneType(c.Type)
+	c.Value = cloneConstantValue(c.Value)
+	c.Annotations = cloneAnnotations(c.Annotations)
+	return c
+}
+
+func cloneTypedef(t Typedef) Typedef {
+	t.Comments = cloneComments(t.Comments)
+	t.Location = cloneLocation(t.Location)
+	t.Type = cloneType(t.Type)
+	t.Annotations = cloneAnnotations(t.Annotations)
+	return t
+}
+
+func cloneType(t Type) Type {
+	t.Location = cloneLocation(t.Location)
+	if t.KeyType != nil {
+		key := cloneType(*t.KeyType)
+		t.KeyType = &key
+	}
+	if t.ValueType != nil {
+		value := cloneType(*t.ValueType)
+		t.ValueType = &value
+	}
+	return t
+}
+
+func cloneAnnotations(annos []Annotation) []Annotation {
+	return cloneSlice(annos, func(a Annotation) Annotation {
+		a.Value = cloneConstantValue(a.Value)
+		return a
+	})
+}
+
+func cloneConstantValue(cv *ConstantValue) *ConstantValue {
+	if cv == nil {
+		return nil
+	}
+	res := *cv
+	switch v := cv.Value.(type) {
+	case []*ConstantValue:
+		res.Value = cloneSlice(v, cloneConstantValue)
+	case []*ConstantMapEntry:
+		res.Value = cloneSlice(v, func(entry *ConstantMapEntry) *ConstantMapEntry {
+			if entry == nil {
+				return nil
+			}
+			return &ConstantMapEntry{Key: cloneConstantValue(entry.Key), Value: cloneConstantValue(entry.Value)}
+		})
+	}
+	return &res
+}
+
+func cloneComments(comments []Comment) []Comment {
+	return cloneSlice(comments, func(c Comment) Comment {
+		c.Location = cloneLocation(c.Location)
+		return c
+	})
+}
+
+func cloneLocation(loc *Location) *Location {
+	if loc == nil {
+		return nil
+	}
+	res := *loc
+	return &res
+}
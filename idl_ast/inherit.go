@@ -0,0 +1,116 @@
+package idl_ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InheritedFunction 是 service 有效方法集中的一个函数。
+type InheritedFunction struct {
+	Function *Function `json:"function"`
+	// Service 是声明该函数的 service：函数直接定义在被展开的 service 中时就是它本身，
+	// 否则是 extends 链上的某个祖先。
+	Service *Service `json:"service"`
+}
+
+// FunctionConflictKind 描述同名函数之间的关系。
+type FunctionConflictKind string
+
+const (
+	FunctionOverridden FunctionConflictKind = "overridden" // 派生 service 重新定义了祖先中的同名函数
+	FunctionDuplicated FunctionConflictKind = "duplicated" // 同一个 service 中多次定义了同名函数
+)
+
+// FunctionConflict 记录有效方法集中被遮盖的同名函数。
+type FunctionConflict struct {
+	Kind FunctionConflictKind `json:"kind"`
+	Name string               `json:"name"`
+	// Function 是保留在有效方法集中的函数。
+	Function InheritedFunction `json:"function"`
+	// Hidden 是被 Function 遮盖、不再出现在有效方法集中的函数。
+	Hidden InheritedFunction `json:"hidden"`
+}
+
+// ResolvedService 是 ResolveService 的结果。
+type ResolvedService struct {
+	Service *Service `json:"service"`
+	// Chain 是 extends 链，从 Service 本身开始，依次是它的父 service、祖父 service……
+	Chain []*Service `json:"chain"`
+	// Functions 是有效方法集：从最远的祖先开始，按声明顺序列出每个 service 的函数。
+	// 同名函数只保留离 Service 最近的定义（同一个 service 中保留第一个），它出现在声明它的 service 的位置上。
+	Functions []InheritedFunction `json:"functions"`
+	// Conflicts 列出所有被遮盖的同名函数，按它们在 extends 链上出现的顺序排列。
+	Conflicts []FunctionConflict `json:"conflicts,omitempty"`
+}
+
+// Function 返回有效方法集中名为 name 的函数。
+func (rs *ResolvedService) Function(name string) (InheritedFunction, bool) {
+	for _, f := range rs.Functions {
+		if f.Function.Name == name {
+			return f, true
+		}
+	}
+	return InheritedFunction{}, false
+}
+
+// ResolveService 跨文件展开 fqn 指向的 service 的 extends 链，返回它的有效方法集。
+// 每个函数都标注了声明它的 service；派生 service 覆盖祖先的同名函数，或同一个 service 中
+// 重复定义的函数会记录在 Conflicts 中。service 不存在、extends 无法解析或继承关系存在循环时返回错误。
+func (schema *IDLSchema) ResolveService(fqn string) (*ResolvedService, error) {
+	idx := schema.currentIndex()
+	svc, ok := idx.fqnMap[fqn].(*Service)
+	if !ok {
+		return nil, fmt.Errorf("service %q not found", fqn)
+	}
+
+	chain := []*Service{svc}
+	seen := map[string]bool{fqn: true}
+	for cur := svc; cur.Extends != ""; {
+		path, _, _ := SplitFQN(cur.FullyQualifiedName)
+		parentFQN := resolveExtends(idx, schema.fileByPath(path), cur.Extends)
+		if parentFQN == "" {
+			return nil, fmt.Errorf("cannot resolve extends %q of service %q", cur.Extends, cur.FullyQualifiedName)
+		}
+		if seen[parentFQN] {
+			names := make([]string, 0, len(chain)+1)
+			for _, s := range chain {
+				names = append(names, s.FullyQualifiedName)
+			}
+			names = append(names, parentFQN)
+			return nil, fmt.Errorf("service inheritance cycle: %s", strings.Join(names, " -> "))
+		}
+		seen[parentFQN] = true
+		cur = idx.fqnMap[parentFQN].(*Service)
+		chain = append(chain, cur)
+	}
+
+	res := &ResolvedService{Service: svc, Chain: chain}
+	// 从 Service 本身向祖先方向遍历，先遇到的定义遮盖后遇到的。
+	effective := make(map[string]InheritedFunction)
+	kept := make(map[*Function]bool)
+	for _, s := range chain {
+		for i := range s.Functions {
+			fn := InheritedFunction{Function: &s.Functions[i], Service: s}
+			winner, exists := effective[fn.Function.Name]
+			if !exists {
+				effective[fn.Function.Name] = fn
+				kept[fn.Function] = true
+				continue
+			}
+			kind := FunctionOverridden
+			if winner.Service == s {
+				kind = FunctionDuplicated
+			}
+			res.Conflicts = append(res.Conflicts, FunctionConflict{Kind: kind, Name: fn.Function.Name, Function: winner, Hidden: fn})
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		s := chain[i]
+		for j := range s.Functions {
+			if kept[&s.Functions[j]] {
+				res.Functions = append(res.Functions, InheritedFunction{Function: &s.Functions[j], Service: s})
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,390 @@
+package idl_ast
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Move 把 fqn 指向的定义（Message、Enum、Typedef 或 Constant）从它所在的文件移动到 targetPath。
+// targetPath 对应的文件不存在时会新建一个空文件。移动时会：
+//   - 更新定义自身的 FullyQualifiedName；
+//   - 把其他文件中的引用改写为新的写法，例如 Foo -> common.Foo，或反向移动时 common.Foo -> Foo；
+//   - 为需要的文件补充 include，包括被移动的定义所依赖的文件；
+//   - 删除因为这次移动而不再被使用的 include。
+//
+// Thrift 不允许循环 include，移动后文件之间会出现循环 include 时返回错误，schema 保持不变。
+func (schema *IDLSchema) Move(fqn, targetPath string) error {
+	return schema.move(fqn, targetPath, true)
+}
+
+// move 实现 Move。checkCycles 为 false 时跳过循环 include 检查，用于在副本上试移动。
+func (schema *IDLSchema) move(fqn, targetPath string, checkCycles bool) error {
+	srcPath, name, ok := SplitFQN(fqn)
+	if !ok {
+		return fmt.Errorf("invalid fully qualified name %q", fqn)
+	}
+	if srcPath == targetPath {
+		return nil
+	}
+
+	idx := schema.currentIndex()
+	def, ok := idx.fqnMap[fqn]
+	if !ok {
+		return fmt.Errorf("definition %q not found", fqn)
+	}
+	switch def.(type) {
+	case *Message, *Enum, *Typedef, *Constant:
+	default:
+		return fmt.Errorf("definition %q of type %T cannot be moved", fqn, def)
+	}
+	newFQN := targetPath + "#" + name
+	if _, exists := idx.fqnMap[newFQN]; exists {
+		return fmt.Errorf("definition %q already exists", newFQN)
+	}
+
+	// 先检查所有需要新增的 include 是否会与已有的 include 重名，避免修改到一半失败。
+	target := schema.fileByPath(targetPath)
+	needImport := make(map[string]map[string]struct{}) // 文件路径 -> 需要 include 的文件路径
+	addNeed := func(filePath, includePath string) {
+		if filePath == includePath {
+			return
+		}
+		if needImport[filePath] == nil {
+			needImport[filePath] = make(map[string]struct{})
+		}
+		needImport[filePath][includePath] = struct{}{}
+	}
+	for _, ref := range schema.collectReferences(idx)[fqn] {
+		if !isWithin(ref.Referrer, fqn) {
+			addNeed(ref.File, targetPath)
+		}
+	}
+	schema.rewriteIdentifierRefs(func(file *File, ident string) (string, bool) {
+		if defFQN, _, ok := resolveIdentifier(idx, file, ident); ok && defFQN == fqn {
+			addNeed(file.Path, targetPath)
+		}
+		return ident, false
+	})
+	for _, dep := range outgoingDependencies(idx, schema.fileByPath(srcPath), def) {
+		if dep != fqn {
+			depPath, _, _ := SplitFQN(dep)
+			addNeed(targetPath, depPath)
+		}
+	}
+	for filePath, includes := range needImport {
+		if err := checkImports(schema.fileByPath(filePath), filePath, includes); err != nil {
+			return err
+		}
+	}
+	// 新增的 include 都与目标文件相连，新出现的循环一定经过它。这里的检查没有扣除移动后会删除的 include，
+	// 发现循环时在副本上实际移动一次，再确认循环是否仍然存在。
+	if checkCycles && includeCycle(schema, needImport, targetPath) != nil {
+		trial := schema.Clone()
+		if err := trial.move(fqn, targetPath, false); err != nil {
+			return err
+		}
+		if cycle := includeCycle(trial, nil, targetPath); cycle != nil {
+			return fmt.Errorf("moving %q to %s would create an include cycle: %s", fqn, targetPath, strings.Join(cycle, " -> "))
+		}
+	}
+
+	usedBefore := make(map[string]map[string]bool)
+	for i := range schema.Files {
+		usedBefore[schema.Files[i].Path] = usedIncludes(idx, &schema.Files[i])
+	}
+
+	if target == nil {
+		schema.Files = append(schema.Files, File{Path: targetPath})
+		schema.Reindex()
+		idx = schema.currentIndex()
+		def = idx.fqnMap[fqn]
+	}
+	for filePath, includes := range needImport {
+		file := schema.fileByPath(filePath)
+		for includePath := range includes {
+			addImport(file, includePath)
+		}
+	}
+	target = schema.fileByPath(targetPath)
+
+	// 改写指向被移动定义的引用。被移动定义内部的自引用也在其中，它们之后属于目标文件。
+	// 上面可能追加了文件，需要基于当前的 AST 重新收集引用。
+	for _, ref := range schema.collectReferences(idx)[fqn] {
+		file := schema.fileByPath(ref.File)
+		if isWithin(ref.Referrer, fqn) {
+			file = target
+		}
+		ref.Type.Name = requalify(file, ref.Type.Name, targetPath, name)
+		ref.Type.FullyQualifiedName = newFQN
+	}
+
+	// 改写其他文件中指向被移动定义的标识符，例如 Status.OK -> common.Status.OK。
+	schema.rewriteIdentifierRefs(func(file *File, ident string) (string, bool) {
+		defFQN, member, ok := resolveIdentifier(idx, file, ident)
+		if !ok || defFQN != fqn {
+			return ident, false
+		}
+		return requalify(file, strings.TrimSuffix(ident, member), targetPath, name) + member, true
+	})
+
+	// 改写被移动定义中指向其他定义的类型引用和标识符，它们现在要从目标文件的视角书写。
+	src := schema.fileByPath(srcPath)
+	Inspect(def, func(node any) bool {
+		t, ok := node.(*Type)
+		if !ok || t.FullyQualifiedName == "" || t.FullyQualifiedName == newFQN {
+			return true
+		}
+		depPath, depName, _ := SplitFQN(t.FullyQualifiedName)
+		t.Name = requalify(target, t.Name, depPath, depName)
+		return true
+	})
+	rewriteMoved := func(ident string) (string, bool) {
+		defFQN, member, ok := resolveIdentifier(idx, src, ident)
+		if !ok {
+			return ident, false
+		}
+		depPath, depName, _ := SplitFQN(defFQN)
+		return requalify(target, strings.TrimSuffix(ident, member), depPath, depName) + member, true
+	}
+	Inspect(def, func(node any) bool {
+		switch n := node.(type) {
+		case *Constant:
+			rewriteIdentifiersInValue(n.Value, rewriteMoved)
+		case *Field:
+			rewriteIdentifiersInValue(n.DefaultValue, rewriteMoved)
+		}
+		return true
+	})
+
+	// 把定义从源文件挪到目标文件，它在目标文件中排在最后。
+	target.Definitions.appendToOrder(RefOf(def))
+	switch d := def.(type) {
+	case *Message:
+		moved := *d
+		moved.FullyQualifiedName = newFQN
+		src.Definitions.Messages = removeAt(src.Definitions.Messages, d)
+		target.Definitions.Messages = append(target.Definitions.Messages, moved)
+	case *Enum:
+		moved := *d
+		moved.FullyQualifiedName = newFQN
+		src.Definitions.Enums = removeAt(src.Definitions.Enums, d)
+		target.Definitions.Enums = append(target.Definitions.Enums, moved)
+	case *Typedef:
+		moved := *d
+		moved.FullyQualifiedName = newFQN
+		src.Definitions.Typedefs = removeAt(src.Definitions.Typedefs, d)
+		target.Definitions.Typedefs = append(target.Definitions.Typedefs, moved)
+	case *Constant:
+		moved := *d
+		moved.FullyQualifiedName = newFQN
+		src.Definitions.Constants = removeAt(src.Definitions.Constants, d)
+		target.Definitions.Constants = append(target.Definitions.Constants, moved)
+	}
+	src.Definitions.pruneOrder()
+
+	// 删除因为这次移动而不再被使用的 include。
+	schema.Reindex()
+	idx = schema.currentIndex()
+	for i := range schema.Files {
+		file := &schema.Files[i]
+		before := usedBefore[file.Path]
+		after := usedIncludes(idx, file)
+		imports := file.Imports[:0]
+		for _, imp := range file.Imports {
+			if before[imp.Path] && !after[imp.Path] {
+				continue
+			}
+			imports = append(imports, imp)
+		}
+		file.Imports = imports
+	}
+
+	schema.Reindex()
+	schema.ResolveConstantReferences()
+	return nil
+}
+
+// includeCycle 返回从 start 出发又回到 start 的 include 路径，没有循环时返回 nil。
+// extra 是尚未写入 AST 的 include，键为发起 include 的文件。
+func includeCycle(schema *IDLSchema, extra map[string]map[string]struct{}, start string) []string {
+	visited := make(map[string]bool)
+	var path []string
+	var visit func(filePath string) bool
+	visit = func(filePath string) bool {
+		path = append(path, filePath)
+		var next []string
+		if file := schema.fileByPath(filePath); file != nil {
+			for _, imp := range file.Imports {
+				next = append(next, imp.Path)
+			}
+		}
+		for includePath := range extra[filePath] {
+			next = append(next, includePath)
+		}
+		sort.Strings(next)
+		for _, includePath := range next {
+			if includePath == start {
+				path = append(path, start)
+				return true
+			}
+			if !visited[includePath] {
+				visited[includePath] = true
+				if visit(includePath) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(start) {
+		return path
+	}
+	return nil
+}
+
+// isWithin 判断 referrer 是否是 fqn 本身或者它的成员，例如 "a.thrift#Foo.bar"。
+func isWithin(referrer, fqn string) bool {
+	return referrer == fqn || strings.HasPrefix(referrer, fqn+".")
+}
+
+func removeAt[T any](s []T, elem *T) []T {
+	for i := range s {
+		if &s[i] == elem {
+			return append(s[:i:i], s[i+1:]...)
+		}
+	}
+	return s
+}
+
+// outgoingDependencies 返回 def 中类型引用和常量标识符所指向的定义 FQN。
+func outgoingDependencies(idx *definitionIndex, file *File, def any) []string {
+	var deps []string
+	seen := make(map[string]struct{})
+	add := func(fqn string) {
+		if _, ok := seen[fqn]; !ok && fqn != "" {
+			seen[fqn] = struct{}{}
+			deps = append(deps, fqn)
+		}
+	}
+	collect := func(ident string) (string, bool) {
+		if defFQN, _, ok := resolveIdentifier(idx, file, ident); ok {
+			add(defFQN)
+		}
+		return ident, false
+	}
+	Inspect(def, func(node any) bool {
+		switch n := node.(type) {
+		case *Type:
+			add(n.FullyQualifiedName)
+		case *Constant:
+			rewriteIdentifiersInValue(n.Value, collect)
+		case *Field:
+			rewriteIdentifiersInValue(n.DefaultValue, collect)
+		}
+		return true
+	})
+	return deps
+}
+
+// usedIncludes 返回 file 中实际被使用的 include 路径集合。
+func usedIncludes(idx *definitionIndex, file *File) map[string]bool {
+	used := make(map[string]bool)
+	collect := func(ident string) (string, bool) {
+		if defFQN, _, ok := resolveIdentifier(idx, file, ident); ok {
+			path, _, _ := SplitFQN(defFQN)
+			used[path] = true
+		}
+		return ident, false
+	}
+	Inspect(&file.Definitions, func(node any) bool {
+		switch n := node.(type) {
+		case *Type:
+			if path, _, ok := SplitFQN(n.FullyQualifiedName); ok {
+				used[path] = true
+			}
+		case *Service:
+			if target := resolveExtends(idx, file, n.Extends); target != "" {
+				path, _, _ := SplitFQN(target)
+				used[path] = true
+			}
+		case *Constant:
+			rewriteIdentifiersInValue(n.Value, collect)
+		case *Field:
+			rewriteIdentifiersInValue(n.DefaultValue, collect)
+		}
+		return true
+	})
+	return used
+}
+
+// resolveIdentifier 把常量值中的标识符（例如 "VERSION"、"Status.OK"、"base.Status.OK"）
+// 解析为它所引用的定义的 FQN，member 是标识符中剩余的成员部分，例如 ".OK"。
+func resolveIdentifier(idx *definitionIndex, file *File, ident string) (defFQN, member string, ok bool) {
+	if file == nil {
+		return "", "", false
+	}
+	lookup := func(path, rest string) (string, string, bool) {
+		head, tail := rest, ""
+		if i := strings.Index(rest, "."); i != -1 {
+			head, tail = rest[:i], rest[i:]
+		}
+		fqn := path + "#" + head
+		if _, found := idx.fqnMap[fqn]; found {
+			return fqn, tail, true
+		}
+		return "", "", false
+	}
+	if fqn, tail, found := lookup(file.Path, ident); found {
+		return fqn, tail, true
+	}
+	for _, imp := range file.Imports {
+		prefix := includeName(imp.Path) + "."
+		if strings.HasPrefix(ident, prefix) {
+			if fqn, tail, found := lookup(imp.Path, strings.TrimPrefix(ident, prefix)); found {
+				return fqn, tail, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// checkImports 检查 file 能否 include includes 中的所有文件，而不与已有的或新增的 include 前缀冲突。
+// file 为 nil 表示这是一个将要新建的文件。
+func checkImports(file *File, filePath string, includes map[string]struct{}) error {
+	names := make(map[string]string)
+	if file != nil {
+		for _, imp := range file.Imports {
+			names[includeName(imp.Path)] = imp.Path
+		}
+	}
+	for includePath := range includes {
+		name := includeName(includePath)
+		if existing, ok := names[name]; ok && existing != includePath {
+			return fmt.Errorf("cannot include %q in %q: include name %q is already used by %q", includePath, filePath, name, existing)
+		}
+		names[name] = includePath
+	}
+	return nil
+}
+
+// addImport 在 file 中追加一条指向 targetPath 的 include，路径相对于 file 所在目录。
+func addImport(file *File, targetPath string) {
+	if file.Path == targetPath {
+		return
+	}
+	if _, ok := includePrefix(file, targetPath); ok {
+		return
+	}
+	rel, err := filepath.Rel(filepath.Dir(file.Path), targetPath)
+	if err != nil {
+		rel = targetPath
+	}
+	file.Imports = append(file.Imports, Import{
+		Value: strconv.Quote(filepath.ToSlash(rel)),
+		Path:  targetPath,
+	})
+}
@@ -0,0 +1,173 @@
+package idl_ast
+
+import "sort"
+
+// DefinitionKind 描述 DefinitionRef 指向的定义种类。
+type DefinitionKind string
+
+const (
+	DefinitionService  DefinitionKind = "service"
+	DefinitionMessage  DefinitionKind = "message" // struct、union 和 exception
+	DefinitionEnum     DefinitionKind = "enum"
+	DefinitionConstant DefinitionKind = "constant"
+	DefinitionTypedef  DefinitionKind = "typedef"
+)
+
+// DefinitionRef 通过种类和名称指向同一文件中的一个顶层定义，用于在 Definitions.Order 中记录声明顺序。
+type DefinitionRef struct {
+	Kind DefinitionKind `json:"kind"`
+	Name string         `json:"name"` // Typedef 使用 Alias
+}
+
+// Ordered 按 Order 记录的顺序返回所有顶层定义（*Service、*Message、*Enum、*Constant 或 *Typedef）。
+// Order 中找不到的引用会被跳过；没有出现在 Order 中的定义按 constant、typedef、enum、message、service
+// 的分组顺序排在最后，因此 Order 为空时结果就是按种类分组的顺序。
+func (defs *Definitions) Ordered() []any {
+	grouped := defs.groupedRefs()
+	pending := make(map[DefinitionRef][]int, len(grouped))
+	for i, ref := range grouped {
+		pending[ref] = append(pending[ref], i)
+	}
+
+	res := make([]any, 0, len(grouped))
+	used := make([]bool, len(grouped))
+	for _, ref := range defs.Order {
+		if indexes := pending[ref]; len(indexes) > 0 {
+			res = append(res, defs.groupedAt(indexes[0]))
+			used[indexes[0]] = true
+			pending[ref] = indexes[1:]
+		}
+	}
+	for i := range grouped {
+		if !used[i] {
+			res = append(res, defs.groupedAt(i))
+		}
+	}
+	return res
+}
+
+// OrderByLocation 按各定义 Location 的起始偏移量重建 Order，解析器用它记录源码中的声明顺序。
+// 没有 Location 的定义排在最后。
+func (defs *Definitions) OrderByLocation() {
+	type entry struct {
+		ref    DefinitionRef
+		offset int
+	}
+	var entries []entry
+	for _, def := range defs.Ordered() {
+		offset := -1
+		if loc := definitionLocation(def); loc != nil {
+			offset = loc.Start.Offset
+		}
+		entries = append(entries, entry{ref: RefOf(def), offset: offset})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if (entries[i].offset < 0) != (entries[j].offset < 0) {
+			return entries[j].offset < 0
+		}
+		return entries[i].offset < entries[j].offset
+	})
+	defs.Order = make([]DefinitionRef, len(entries))
+	for i, e := range entries {
+		defs.Order[i] = e.ref
+	}
+	if len(defs.Order) == 0 {
+		defs.Order = nil
+	}
+}
+
+// RefOf 返回指向 def 的 DefinitionRef。def 不是顶层定义时返回零值。
+func RefOf(def any) DefinitionRef {
+	switch d := def.(type) {
+	case *Service:
+		return DefinitionRef{Kind: DefinitionService, Name: d.Name}
+	case *Message:
+		return DefinitionRef{Kind: DefinitionMessage, Name: d.Name}
+	case *Enum:
+		return DefinitionRef{Kind: DefinitionEnum, Name: d.Name}
+	case *Constant:
+		return DefinitionRef{Kind: DefinitionConstant, Name: d.Name}
+	case *Typedef:
+		return DefinitionRef{Kind: DefinitionTypedef, Name: d.Alias}
+	}
+	return DefinitionRef{}
+}
+
+func definitionLocation(def any) *Location {
+	switch d := def.(type) {
+	case *Service:
+		return d.Location
+	case *Message:
+		return d.Location
+	case *Enum:
+		return d.Location
+	case *Constant:
+		return d.Location
+	case *Typedef:
+		return d.Location
+	}
+	return nil
+}
+
+// groupedRefs 按 constant、typedef、enum、message、service 的顺序返回所有定义的引用，
+// 与 groupedAt 的下标一一对应。
+func (defs *Definitions) groupedRefs() []DefinitionRef {
+	n := len(defs.Constants) + len(defs.Typedefs) + len(defs.Enums) + len(defs.Messages) + len(defs.Services)
+	refs := make([]DefinitionRef, n)
+	for i := range refs {
+		refs[i] = RefOf(defs.groupedAt(i))
+	}
+	return refs
+}
+
+func (defs *Definitions) groupedAt(i int) any {
+	if i < len(defs.Constants) {
+		return &defs.Constants[i]
+	}
+	i -= len(defs.Constants)
+	if i < len(defs.Typedefs) {
+		return &defs.Typedefs[i]
+	}
+	i -= len(defs.Typedefs)
+	if i < len(defs.Enums) {
+		return &defs.Enums[i]
+	}
+	i -= len(defs.Enums)
+	if i < len(defs.Messages) {
+		return &defs.Messages[i]
+	}
+	i -= len(defs.Messages)
+	return &defs.Services[i]
+}
+
+// renameInOrder 把 Order 中指向 old 的引用改为 newName。
+func (defs *Definitions) renameInOrder(old DefinitionRef, newName string) {
+	for i := range defs.Order {
+		if defs.Order[i] == old {
+			defs.Order[i].Name = newName
+		}
+	}
+}
+
+// pruneOrder 删除 Order 中已经不存在的定义的引用。
+func (defs *Definitions) pruneOrder() {
+	if len(defs.Order) == 0 {
+		return
+	}
+	existing := make(map[DefinitionRef]bool)
+	for _, ref := range defs.groupedRefs() {
+		existing[ref] = true
+	}
+	defs.Order = filter(defs.Order, func(ref *DefinitionRef) bool { return existing[*ref] })
+}
+
+// appendToOrder 在 Order 末尾追加 ref。应在把定义加入对应切片之前调用：
+// 如果 Order 为空而文件中已有其他定义，会先把它们当前的顺序记录下来，保证新定义排在最后。
+func (defs *Definitions) appendToOrder(ref DefinitionRef) {
+	if len(defs.Order) == 0 {
+		for _, def := range defs.Ordered() {
+			defs.Order = append(defs.Order, RefOf(def))
+		}
+	}
+	defs.Order = append(defs.Order, ref)
+}
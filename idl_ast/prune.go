@@ -0,0 +1,119 @@
+package idl_ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prune 返回一个只包含 roots 及其传递依赖的新 schema，原 schema 不会被修改。
+//
+// roots 是完整的 FQN，通常是 Service（保留全部方法）或 Function
+// （例如 "main.thrift#Greeter.sayHello"，所属 Service 只保留被选中的方法），
+// 也可以是任意其他定义。依赖包括字段、参数、返回值、throws、typedef 和常量中的类型引用，
+// 常量值和字段默认值中引用的常量与枚举，以及 service 的 extends（被继承的 Service 完整保留）。
+//
+// 结果保持原有的文件路径；不再包含任何定义的文件会被删除，没有被使用的 include 也会被删除，
+// 可以直接交给 thriftwriter.Generate 输出。
+func (schema *IDLSchema) Prune(roots ...string) (*IDLSchema, error) {
+	res := schema.Clone()
+	idx := res.currentIndex()
+
+	visited := make(map[string]bool)
+	wholeServices := make(map[string]bool)
+	functions := make(map[string]map[string]bool) // service FQN -> 被保留的方法名
+	var queue []string
+	enqueue := func(fqn string) {
+		if !visited[fqn] {
+			visited[fqn] = true
+			queue = append(queue, fqn)
+		}
+	}
+	for _, root := range roots {
+		def, ok := idx.fqnMap[root]
+		if !ok {
+			return nil, fmt.Errorf("definition %q not found", root)
+		}
+		if _, ok := def.(*EnumValue); ok {
+			root = root[:strings.LastIndex(root, ".")]
+		}
+		enqueue(root)
+	}
+
+	for len(queue) > 0 {
+		fqn := queue[0]
+		queue = queue[1:]
+		path, _, _ := SplitFQN(fqn)
+		file := res.fileByPath(path)
+		def := idx.fqnMap[fqn]
+
+		switch d := def.(type) {
+		case *Function:
+			svcFQN := fqn[:strings.LastIndex(fqn, ".")]
+			if functions[svcFQN] == nil {
+				functions[svcFQN] = make(map[string]bool)
+				if svc, ok := idx.fqnMap[svcFQN].(*Service); ok {
+					if base := resolveExtends(idx, file, svc.Extends); base != "" {
+						enqueue(base)
+					}
+				}
+			}
+			functions[svcFQN][d.Name] = true
+		case *Service:
+			wholeServices[fqn] = true
+			if base := resolveExtends(idx, file, d.Extends); base != "" {
+				enqueue(base)
+			}
+		}
+		for _, dep := range outgoingDependencies(idx, file, def) {
+			enqueue(dep)
+		}
+	}
+
+	files := res.Files[:0]
+	for _, file := range res.Files {
+		defs := &file.Definitions
+		defs.Services = filter(defs.Services, func(svc *Service) bool {
+			if wholeServices[svc.FullyQualifiedName] {
+				return true
+			}
+			kept := functions[svc.FullyQualifiedName]
+			if len(kept) == 0 {
+				return false
+			}
+			svc.Functions = filter(svc.Functions, func(fn *Function) bool { return kept[fn.Name] })
+			return true
+		})
+		defs.Messages = filter(defs.Messages, func(m *Message) bool { return visited[m.FullyQualifiedName] })
+		defs.Enums = filter(defs.Enums, func(e *Enum) bool { return visited[e.FullyQualifiedName] })
+		defs.Constants = filter(defs.Constants, func(c *Constant) bool { return visited[c.FullyQualifiedName] })
+		defs.Typedefs = filter(defs.Typedefs, func(t *Typedef) bool { return visited[t.FullyQualifiedName] })
+		defs.pruneOrder()
+		if len(defs.Services)+len(defs.Messages)+len(defs.Enums)+len(defs.Constants)+len(defs.Typedefs) > 0 {
+			files = append(files, file)
+		}
+	}
+	res.Files = files
+
+	res.Reindex()
+	idx = res.currentIndex()
+	for i := range res.Files {
+		file := &res.Files[i]
+		used := usedIncludes(idx, file)
+		file.Imports = filter(file.Imports, func(imp *Import) bool { return used[imp.Path] })
+	}
+	return res, nil
+}
+
+// filter 原地保留 s 中满足 keep 的元素。
+func filter[T any](s []T, keep func(*T) bool) []T {
+	res := s[:0]
+	for i := range s {
+		if keep(&s[i]) {
+			res = append(res, s[i])
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
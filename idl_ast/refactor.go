@@ -0,0 +1,202 @@
+package idl_ast
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Rename 把 fqn 指向的定义（Message、Enum、Typedef、Constant 或 Service）重命名为 newName，
+// 并同步更新：
+//   - 定义自身的名称和 FullyQualifiedName（Service 下的 Function FQN 也会一并更新）；
+//   - 所有引用它的 Type.Name 和 Type.FullyQualifiedName，跨文件的引用会带上 include 前缀；
+//   - 所有引用它的 service extends；
+//   - 常量值和字段默认值中引用它的标识符，例如 Status.OK。
+//
+// 修改完成后 schema 可以直接交给 thriftwriter.Generate 输出。
+func (schema *IDLSchema) Rename(fqn, newName string) error {
+	if !identifierRegex.MatchString(newName) {
+		return fmt.Errorf("invalid identifier %q", newName)
+	}
+	filePath, oldName, ok := SplitFQN(fqn)
+	if !ok {
+		return fmt.Errorf("invalid fully qualified name %q", fqn)
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	idx := schema.currentIndex()
+	def, ok := idx.fqnMap[fqn]
+	if !ok {
+		return fmt.Errorf("definition %q not found", fqn)
+	}
+	newFQN := filePath + "#" + newName
+	if _, exists := idx.fqnMap[newFQN]; exists {
+		return fmt.Errorf("definition %q already exists", newFQN)
+	}
+
+	// 引用要在修改定义之前收集，之后 extends 中的旧名称就无法解析了。
+	refs := schema.collectReferences(idx)[fqn]
+	oldRef := RefOf(def)
+	switch d := def.(type) {
+	case *Message:
+		d.Name, d.FullyQualifiedName = newName, newFQN
+	case *Enum:
+		d.Name, d.FullyQualifiedName = newName, newFQN
+	case *Typedef:
+		d.Alias, d.FullyQualifiedName = newName, newFQN
+	case *Constant:
+		d.Name, d.FullyQualifiedName = newName, newFQN
+	case *Service:
+		d.Name, d.FullyQualifiedName = newName, newFQN
+		for i := range d.Functions {
+			d.Functions[i].FullyQualifiedName = fmt.Sprintf("%s.%s", newFQN, d.Functions[i].Name)
+		}
+	default:
+		return fmt.Errorf("definition %q of type %T cannot be renamed", fqn, def)
+	}
+	if file := schema.fileByPath(filePath); file != nil {
+		file.Definitions.renameInOrder(oldRef, newName)
+	}
+
+	for _, ref := range refs {
+		file := schema.fileByPath(ref.File)
+		switch ref.Kind {
+		case ReferenceExtends:
+			svc, ok := idx.fqnMap[ref.Referrer].(*Service)
+			if ok {
+				svc.Extends = requalify(file, svc.Extends, filePath, newName)
+			}
+		default:
+			ref.Type.Name = requalify(file, ref.Type.Name, filePath, newName)
+			ref.Type.FullyQualifiedName = newFQN
+		}
+	}
+
+	schema.rewriteIdentifierRefs(func(file *File, ident string) (string, bool) {
+		for _, spelling := range spellingsOf(file, filePath, oldName) {
+			if ident == spelling || strings.HasPrefix(ident, spelling+".") {
+				prefix := strings.TrimSuffix(spelling, oldName)
+				return prefix + newName + strings.TrimPrefix(ident, spelling), true
+			}
+		}
+		return ident, false
+	})
+
+	schema.Reindex()
+	schema.ResolveConstantReferences()
+	return nil
+}
+
+func (schema *IDLSchema) fileByPath(path string) *File {
+	for i := range schema.Files {
+		if schema.Files[i].Path == path {
+			return &schema.Files[i]
+		}
+	}
+	return nil
+}
+
+// includePrefix 返回 file 中 include targetPath 时使用的前缀，例如 "base"。
+// 如果 file 没有 include targetPath，返回 false。
+func includePrefix(file *File, targetPath string) (string, bool) {
+	if file == nil {
+		return "", false
+	}
+	for _, imp := range file.Imports {
+		if imp.Path == targetPath {
+			return includeName(imp.Path), true
+		}
+	}
+	return "", false
+}
+
+// qualifiedName 返回在 file 中引用 targetPath 里名为 name 的定义时应当使用的写法。
+func qualifiedName(file *File, targetPath, name string) (string, bool) {
+	if file != nil && file.Path == targetPath {
+		return name, true
+	}
+	prefix, ok := includePrefix(file, targetPath)
+	if !ok {
+		return "", false
+	}
+	return prefix + "." + name, true
+}
+
+// requalify 计算引用被重命名或移动后的新写法。当 file 无法确定写法时，
+// 保留原有的前缀，只替换最后一段名称。
+func requalify(file *File, current, targetPath, name string) string {
+	if qualified, ok := qualifiedName(file, targetPath, name); ok {
+		return qualified
+	}
+	if i := strings.LastIndex(current, "."); i != -1 {
+		return current[:i+1] + name
+	}
+	return name
+}
+
+// spellingsOf 返回在 file 中引用 targetPath 里名为 name 的定义时所有合法的写法。
+func spellingsOf(file *File, targetPath, name string) []string {
+	if file == nil {
+		return nil
+	}
+	if file.Path == targetPath {
+		return []string{name}
+	}
+	var res []string
+	for _, imp := range file.Imports {
+		if imp.Path == targetPath {
+			res = append(res, includeName(imp.Path)+"."+name)
+		}
+	}
+	return res
+}
+
+// rewriteIdentifierRefs 遍历所有常量值和字段默认值中的标识符，用 rewrite 返回的新标识符替换它们。
+func (schema *IDLSchema) rewriteIdentifierRefs(rewrite func(file *File, ident string) (string, bool)) {
+	for i := range schema.Files {
+		file := &schema.Files[i]
+		fn := func(ident string) (string, bool) {
+			return rewrite(file, ident)
+		}
+		Inspect(file, func(node any) bool {
+			switch n := node.(type) {
+			case *Constant:
+				rewriteIdentifiersInValue(n.Value, fn)
+			case *Field:
+				rewriteIdentifiersInValue(n.DefaultValue, fn)
+			}
+			return true
+		})
+	}
+}
+
+func rewriteIdentifiersInValue(cv *ConstantValue, fn func(string) (string, bool)) {
+	if cv == nil {
+		return
+	}
+	switch v := cv.Value.(type) {
+	case string:
+		if cv.InferKind() == ConstantIdentifier {
+			if ident, ok := fn(v); ok {
+				cv.Value = ident
+			}
+		}
+	case []*ConstantValue:
+		for _, item := range v {
+			rewriteIdentifiersInValue(item, fn)
+		}
+	case []*ConstantMapEntry:
+		for _, entry := range v {
+			rewriteIdentifiersInValue(entry.Key, fn)
+			rewriteIdentifiersInValue(entry.Value, fn)
+		}
+	}
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0]
+}
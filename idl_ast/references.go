@@ -0,0 +1,234 @@
+package idl_ast
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ReferenceKind 描述一次引用出现在什么位置。
+type ReferenceKind string
+
+const (
+	ReferenceField      ReferenceKind = "field"      // Message 字段的类型
+	ReferenceParameter  ReferenceKind = "parameter"  // Function 参数的类型
+	ReferenceReturnType ReferenceKind = "returnType" // Function 的返回类型
+	ReferenceThrows     ReferenceKind = "throws"     // Function throws 子句中的异常类型
+	ReferenceTypedef    ReferenceKind = "typedef"    // Typedef 的原始类型
+	ReferenceConstant   ReferenceKind = "constant"   // Constant 的类型
+	ReferenceExtends    ReferenceKind = "extends"    // Service 的 extends 子句
+)
+
+// Reference 代表对某个定义的一次引用。
+type Reference struct {
+	Kind ReferenceKind `json:"kind"`
+	// File 是引用所在文件的路径。
+	File string `json:"file"`
+	// Referrer 是发起引用的元素，例如 "main.thrift#HelloRequest.profile"、
+	// "main.thrift#Greeter.sayHello" 或 "main.thrift#Greeter"。
+	Referrer string `json:"referrer"`
+	// Target 是被引用定义的 FQN。
+	Target   string    `json:"target"`
+	Location *Location `json:"location,omitempty"`
+	// Type 指向 AST 中发起引用的类型节点（可能嵌套在容器类型中）。
+	// extends 引用没有对应的类型节点，此时为 nil。
+	Type *Type `json:"-"`
+}
+
+// FindReferences 返回 schema 中所有指向 fqn 的引用，包括字段、参数、返回值、
+// throws、typedef、常量的类型以及 service 的 extends。fqn 必须是完整的 FQN，
+// 例如 "common/entity/entity.thrift#Entity"。结果按 AST 的遍历顺序排列。
+//
+// 引用中的 Type 指向 AST 内部，字段、参数等随时可能被原地修改，因此引用不会被缓存，
+// 每次调用都会基于当前的 AST 重新收集。
+func (schema *IDLSchema) FindReferences(fqn string) []Reference {
+	return schema.collectReferences(schema.currentIndex())[fqn]
+}
+
+// collectReferences 遍历整个 schema，按被引用的 FQN 收集所有引用。
+// 类型引用依赖解析器已经填充的 Type.FullyQualifiedName。
+func (schema *IDLSchema) collectReferences(idx *definitionIndex) map[string][]Reference {
+	refs := make(map[string][]Reference)
+	Walk(schema, func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *Service:
+			if n.Extends == "" {
+				return true
+			}
+			file := enclosingFile(c.Path())
+			target := resolveExtends(idx, file, n.Extends)
+			if target == "" {
+				return true
+			}
+			refs[target] = append(refs[target], Reference{
+				Kind:     ReferenceExtends,
+				File:     file.Path,
+				Referrer: n.FullyQualifiedName,
+				Target:   target,
+				Location: n.Location,
+			})
+		case *Type:
+			if n.FullyQualifiedName == "" {
+				return true
+			}
+			ref, ok := newTypeReference(c.Path(), n)
+			if ok {
+				refs[n.FullyQualifiedName] = append(refs[n.FullyQualifiedName], ref)
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// newTypeReference 根据类型节点的祖先路径判断引用的种类和发起者。
+func newTypeReference(path []any, t *Type) (Reference, bool) {
+	// 跳过外层的容器类型，找到真正拥有这个类型的节点。
+	i := len(path) - 1
+	for i >= 0 {
+		if _, ok := path[i].(*Type); !ok {
+			break
+		}
+		i--
+	}
+	if i < 0 {
+		return Reference{}, false
+	}
+
+	ref := Reference{Target: t.FullyQualifiedName, Location: t.Location, Type: t}
+	if file := enclosingFile(path); file != nil {
+		ref.File = file.Path
+	}
+
+	var ownerLoc *Location
+	switch owner := path[i].(type) {
+	case *Field:
+		ownerLoc = owner.Location
+		if i == 0 {
+			return Reference{}, false
+		}
+		switch parent := path[i-1].(type) {
+		case *Message:
+			ref.Kind = ReferenceField
+			ref.Referrer = parent.FullyQualifiedName + "." + owner.Name
+		case *Function:
+			ref.Kind = ReferenceParameter
+			if containsField(parent.Throws, owner) {
+				ref.Kind = ReferenceThrows
+			}
+			ref.Referrer = parent.FullyQualifiedName + "." + owner.Name
+		default:
+			return Reference{}, false
+		}
+	case *Function:
+		ref.Kind = ReferenceReturnType
+		ref.Referrer = owner.FullyQualifiedName
+		ownerLoc = owner.Location
+	case *Typedef:
+		ref.Kind = ReferenceTypedef
+		ref.Referrer = owner.FullyQualifiedName
+		ownerLoc = owner.Location
+	case *Constant:
+		ref.Kind = ReferenceConstant
+		ref.Referrer = owner.FullyQualifiedName
+		ownerLoc = owner.Location
+	default:
+		return Reference{}, false
+	}
+	if ref.Location == nil {
+		ref.Location = ownerLoc
+	}
+	return ref, true
+}
+
+func containsField(fields []Field, f *Field) bool {
+	for i := range fields {
+		if &fields[i] == f {
+			return true
+		}
+	}
+	return false
+}
+
+func enclosingFile(path []any) *File {
+	for _, node := range path {
+		if f, ok := node.(*File); ok {
+			return f
+		}
+	}
+	return nil
+}
+
+// includeName 返回 include 在引用方文件中使用的前缀，即文件名去掉扩展名，
+// 例如 "common/entity/entity.thrift" -> "entity"。
+func includeName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// ResolveConstantReferences 解析所有常量值和字段默认值中的标识符，把引用目标的 FQN
+// 写入 ConstantValue.FullyQualifiedName，例如 `base.Status.OK` -> `common/base.thrift#Status.OK`。
+// 无法解析的标识符（例如引用了未 include 的文件）的 FullyQualifiedName 会被清空。
+// 解析器在所有文件转换完成后调用它；Rename 和 Move 会在改写标识符后重新解析。
+func (schema *IDLSchema) ResolveConstantReferences() {
+	idx := schema.currentIndex()
+	for i := range schema.Files {
+		file := &schema.Files[i]
+		Inspect(file, func(node any) bool {
+			switch n := node.(type) {
+			case *Constant:
+				resolveConstantValue(idx, file, n.Value)
+			case *Field:
+				resolveConstantValue(idx, file, n.DefaultValue)
+			}
+			return true
+		})
+	}
+}
+
+func resolveConstantValue(idx *definitionIndex, file *File, cv *ConstantValue) {
+	if cv == nil {
+		return
+	}
+	switch v := cv.Value.(type) {
+	case string:
+		cv.FullyQualifiedName = ""
+		if cv.InferKind() == ConstantIdentifier {
+			if defFQN, member, ok := resolveIdentifier(idx, file, v); ok {
+				cv.FullyQualifiedName = defFQN + member
+			}
+		}
+	case []*ConstantValue:
+		for _, item := range v {
+			resolveConstantValue(idx, file, item)
+		}
+	case []*ConstantMapEntry:
+		for _, entry := range v {
+			if entry != nil {
+				resolveConstantValue(idx, file, entry.Key)
+				resolveConstantValue(idx, file, entry.Value)
+			}
+		}
+	}
+}
+
+// resolveExtends 把 service extends 子句中的名称（如 "Base" 或 "base.Base"）解析为 FQN。
+// 无法解析时返回空字符串。
+func resolveExtends(idx *definitionIndex, file *File, name string) string {
+	if file == nil {
+		return ""
+	}
+	if _, ok := idx.fqnMap[file.Path+"#"+name].(*Service); ok {
+		return file.Path + "#" + name
+	}
+	for _, imp := range file.Imports {
+		prefix := includeName(imp.Path) + "."
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		fqn := imp.Path + "#" + strings.TrimPrefix(name, prefix)
+		if _, ok := idx.fqnMap[fqn].(*Service); ok {
+			return fqn
+		}
+	}
+	return ""
+}
@@ -1,86 +1,117 @@
 package idl_ast
 
 import (
+	"sort"
 	"strings"
-	"sync"
 )
 
 type definitionIndex struct {
 	// a map from FullyQualifiedName to the actual definition object.
 	// The object is stored as an `any` type to hold different definition types
-	// (e.g., *Service, *Message, *Enum, *Constant, *Function).
+	// (e.g., *Service, *Message, *Enum, *Constant, *Typedef, *Function, *EnumValue).
 	fqnMap map[string]any
+	// fqns 是 fqnMap 中所有键的有序列表，保证后缀匹配的结果顺序稳定。
+	fqns []string
+	// version 是构建索引时 schema 的版本。
+	version uint64
 }
 
-var (
-	indexOnce sync.Once
-	index     *definitionIndex
-)
+// Reindex 使当前的定义索引失效，下一次查询时会重新构建。Rename、Move、Prune 和
+// thriftparser 的增量更新会自动调用它；直接修改 Files（增删定义、替换切片或原地改名）后需要显式调用。
+func (schema *IDLSchema) Reindex() {
+	schema.version++
+}
+
+// currentIndex 返回与 schema 当前版本一致的索引，必要时重新构建。
+// 返回的索引构建后不再修改，因此可以并发读取；并发的首次查询可能各自构建一次，结果相同。
+func (schema *IDLSchema) currentIndex() *definitionIndex {
+	if idx, _ := schema.index.Load().(*definitionIndex); idx != nil && idx.version == schema.version {
+		return idx
+	}
+	idx := schema.buildIndex()
+	schema.index.Store(idx)
+	return idx
+}
 
-func (schema *IDLSchema) buildIndex() {
-	indexOnce.Do(func() {
-		index = &definitionIndex{
-			fqnMap: make(map[string]any),
+func (schema *IDLSchema) buildIndex() *definitionIndex {
+	idx := &definitionIndex{
+		fqnMap:  make(map[string]any),
+		version: schema.version,
+	}
+	add := func(fqn string, def any) {
+		if fqn != "" {
+			idx.fqnMap[fqn] = def
 		}
-		for i := range schema.Files {
-			file := &schema.Files[i] // Use pointer to avoid copying
-			defs := &file.Definitions
-
-			// 索引 Services 和它们的 Functions
-			for j := range defs.Services {
-				service := &defs.Services[j]
-				if service.FullyQualifiedName != "" {
-					index.fqnMap[service.FullyQualifiedName] = service
-				}
-				for k := range service.Functions {
-					function := &service.Functions[k]
-					if function.FullyQualifiedName != "" {
-						index.fqnMap[function.FullyQualifiedName] = function
-					}
-				}
-			}
+	}
 
-			// 索引 Messages (structs, unions, exceptions)
-			for j := range defs.Messages {
-				message := &defs.Messages[j]
-				if message.FullyQualifiedName != "" {
-					index.fqnMap[message.FullyQualifiedName] = message
-				}
+	for i := range schema.Files {
+		file := &schema.Files[i] // Use pointer to avoid copying
+		defs := &file.Definitions
+
+		// 索引 Services 和它们的 Functions
+		for j := range defs.Services {
+			service := &defs.Services[j]
+			add(service.FullyQualifiedName, service)
+			for k := range service.Functions {
+				function := &service.Functions[k]
+				add(function.FullyQualifiedName, function)
 			}
+		}
 
-			// 索引 Enums
-			for j := range defs.Enums {
-				enum := &defs.Enums[j]
-				if enum.FullyQualifiedName != "" {
-					index.fqnMap[enum.FullyQualifiedName] = enum
-				}
-			}
+		// 索引 Messages (structs, unions, exceptions)
+		for j := range defs.Messages {
+			message := &defs.Messages[j]
+			add(message.FullyQualifiedName, message)
+		}
 
-			// 索引 Constants
-			for j := range defs.Constants {
-				constant := &defs.Constants[j]
-				if constant.FullyQualifiedName != "" {
-					index.fqnMap[constant.FullyQualifiedName] = constant
-				}
+		// 索引 Enums 和它们的成员，成员的 FQN 形如 path/to/file.thrift#Enum.VALUE
+		for j := range defs.Enums {
+			enum := &defs.Enums[j]
+			add(enum.FullyQualifiedName, enum)
+			if enum.FullyQualifiedName == "" {
+				continue
+			}
+			for k := range enum.Values {
+				value := &enum.Values[k]
+				add(enum.FullyQualifiedName+"."+value.Name, value)
 			}
 		}
-	})
+
+		// 索引 Constants
+		for j := range defs.Constants {
+			constant := &defs.Constants[j]
+			add(constant.FullyQualifiedName, constant)
+		}
+
+		// 索引 Typedefs
+		for j := range defs.Typedefs {
+			typedef := &defs.Typedefs[j]
+			add(typedef.FullyQualifiedName, typedef)
+		}
+	}
+
+	idx.fqns = make([]string, 0, len(idx.fqnMap))
+	for fqn := range idx.fqnMap {
+		idx.fqns = append(idx.fqns, fqn)
+	}
+	sort.Strings(idx.fqns)
+	return idx
 }
 
 func (schema *IDLSchema) FindByFQN(fqn string) []any {
-	schema.buildIndex()
+	idx := schema.currentIndex()
 
 	// 1. 尝试精确匹配
-	if def, found := index.fqnMap[fqn]; found {
+	if def, found := idx.fqnMap[fqn]; found {
 		return []any{def}
 	}
 
 	// 2. 如果精确匹配失败，则进行后缀匹配
 	var results []any
-	for key, def := range index.fqnMap {
+	for _, key := range idx.fqns {
 		if strings.HasSuffix(key, "#"+fqn) || key == fqn {
 			// 后缀匹配时，确保 # 前面的部分也匹配，或者 fqn 本身就是一个完整的后缀
-			results = append(results, def)
+			results = append(results, idx.fqnMap[key])
 		}
 	}
 
@@ -125,6 +156,16 @@ func (schema *IDLSchema) FindFunctionsByFQN(fqn string) []*Function {
 	return findByType[*Function](schema, fqn)
 }
 
+// FindTypedefsByFQN 查找所有 FQN 以指定字符串结尾的 Typedef。
+func (schema *IDLSchema) FindTypedefsByFQN(fqn string) []*Typedef {
+	return findByType[*Typedef](schema, fqn)
+}
+
+// FindEnumValuesByFQN 查找所有 FQN 以指定字符串结尾的枚举成员，例如 "Status.OK"。
+func (schema *IDLSchema) FindEnumValuesByFQN(fqn string) []*EnumValue {
+	return findByType[*EnumValue](schema, fqn)
+}
+
 // --- 新增：更细粒度的查找函数 ---
 
 // FindStructsByFQN 查找所有 FQN 以指定字符串结尾的 Struct 类型的 Message。
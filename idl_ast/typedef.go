@@ -0,0 +1,91 @@
+package idl_ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResolvedType 是 ResolveType 的结果。
+type ResolvedType struct {
+	// Type 是展开所有 typedef 之后的规范类型：它本身不再指向 typedef，
+	// 容器的 KeyType / ValueType 也已递归展开。容器类型会复制出新的 Type 节点，
+	// 原 AST 不会被修改。
+	Type *Type `json:"type"`
+	// Aliases 是展开顶层类型时依次经过的 typedef。例如 `typedef common.ID UserID`
+	// 且 common.thrift 中有 `typedef i64 ID` 时，展开 UserID 得到 [UserID, ID]。
+	// 容器元素类型经过的 typedef 不计入其中。
+	Aliases []*Typedef `json:"aliases,omitempty"`
+}
+
+// ResolveType 跨文件展开 t 背后的 typedef 链，返回规范类型以及经过的别名。
+// 无法找到定义的类型会原样保留。typedef 之间存在循环时（包括经过容器元素类型形成的循环，
+// 例如 `typedef list<B> A` 与 `typedef list<A> B`）返回错误，错误信息列出循环链上的 FQN。
+func (schema *IDLSchema) ResolveType(t *Type) (*ResolvedType, error) {
+	if t == nil {
+		return nil, fmt.Errorf("type is nil")
+	}
+	return schema.resolveType(t, nil)
+}
+
+// resolveType 展开 t。expanding 是外层正在展开的 typedef，用于发现经过容器元素类型形成的循环。
+func (schema *IDLSchema) resolveType(t *Type, expanding []string) (*ResolvedType, error) {
+	aliases, err := schema.typedefChain(t, expanding)
+	if err != nil {
+		return nil, err
+	}
+	base := t
+	if len(aliases) > 0 {
+		base = &aliases[len(aliases)-1].Type
+	}
+	inner := make([]string, len(expanding), len(expanding)+len(aliases))
+	copy(inner, expanding)
+	for _, td := range aliases {
+		inner = append(inner, td.FullyQualifiedName)
+	}
+	canonical, err := schema.resolveElements(base, inner)
+	if err != nil {
+		return nil, err
+	}
+	return &ResolvedType{Type: canonical, Aliases: aliases}, nil
+}
+
+// typedefChain 返回从 t 开始依次经过的 typedef。
+func (schema *IDLSchema) typedefChain(t *Type, expanding []string) ([]*Typedef, error) {
+	var chain []*Typedef
+	stack := append([]string(nil), expanding...)
+	for t.FullyQualifiedName != "" {
+		typedefs := schema.FindTypedefsByFQN(t.FullyQualifiedName)
+		if len(typedefs) == 0 {
+			break
+		}
+		for i, fqn := range stack {
+			if fqn == t.FullyQualifiedName {
+				names := append(stack[i:], t.FullyQualifiedName)
+				return nil, fmt.Errorf("typedef cycle: %s", strings.Join(names, " -> "))
+			}
+		}
+		stack = append(stack, t.FullyQualifiedName)
+		chain = append(chain, typedefs[0])
+		t = &typedefs[0].Type
+	}
+	return chain, nil
+}
+
+// resolveElements 递归展开容器的元素类型。
+func (schema *IDLSchema) resolveElements(t *Type, expanding []string) (*Type, error) {
+	if t.KeyType == nil && t.ValueType == nil {
+		return t, nil
+	}
+	res := *t
+	for _, elem := range []**Type{&res.KeyType, &res.ValueType} {
+		if *elem == nil {
+			continue
+		}
+		resolved, err := schema.resolveType(*elem, expanding)
+		if err != nil {
+			return nil, err
+		}
+		*elem = resolved.Type
+	}
+	return &res, nil
+}
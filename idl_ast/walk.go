@@ -0,0 +1,201 @@
+package idl_ast
+
+import "fmt"
+
+// Cursor 描述 Walk 遍历到的当前节点及其所有祖先节点。
+// 节点总是指向 AST 内部的指针，例如 *File、*Message、*Field、*Type。
+type Cursor struct {
+	node any
+	path []any
+}
+
+// Node 返回当前节点。
+func (c *Cursor) Node() any {
+	return c.node
+}
+
+// Parent 返回当前节点的父节点，根节点的父节点为 nil。
+func (c *Cursor) Parent() any {
+	if len(c.path) == 0 {
+		return nil
+	}
+	return c.path[len(c.path)-1]
+}
+
+// Path 返回从根节点到父节点的祖先路径（不含当前节点）。
+// 返回的切片是一份拷贝，调用方可以自由保存或修改。
+func (c *Cursor) Path() []any {
+	path := make([]any, len(c.path))
+	copy(path, c.path)
+	return path
+}
+
+// Replace 用 n 的内容原地替换当前节点。n 必须与当前节点是同一种指针类型，
+// 否则会 panic。替换之后 Walk 会继续遍历新节点的子节点。
+// 根节点 *IDLSchema 不能被替换。
+func (c *Cursor) Replace(n any) {
+	var ok bool
+	switch dst := c.node.(type) {
+	case *File:
+		ok = assign(dst, n)
+	case *Import:
+		ok = assign(dst, n)
+	case *Namespace:
+		ok = assign(dst, n)
+	case *Definitions:
+		ok = assign(dst, n)
+	case *Service:
+		ok = assign(dst, n)
+	case *Function:
+		ok = assign(dst, n)
+	case *Message:
+		ok = assign(dst, n)
+	case *Field:
+		ok = assign(dst, n)
+	case *Enum:
+		ok = assign(dst, n)
+	case *EnumValue:
+		ok = assign(dst, n)
+	case *Constant:
+		ok = assign(dst, n)
+	case *Typedef:
+		ok = assign(dst, n)
+	case *Type:
+		ok = assign(dst, n)
+	case *Annotation:
+		ok = assign(dst, n)
+	}
+	if !ok {
+		panic(fmt.Sprintf("idl_ast: cannot replace %T with %T", c.node, n))
+	}
+}
+
+func assign[T any](dst *T, n any) bool {
+	src, ok := n.(*T)
+	if ok && src != nil {
+		*dst = *src
+	}
+	return ok && src != nil
+}
+
+// WalkFunc 在 Walk 访问每个节点时被调用。返回 false 时跳过该节点的所有子节点。
+type WalkFunc func(c *Cursor) bool
+
+// Walk 以深度优先的顺序遍历 root 及其所有子节点。root 可以是任意 AST 节点的指针，
+// 例如 *IDLSchema、*File、*Definitions、*Service 或 *Type。
+//
+// 遍历顺序遵循结构体字段的定义顺序：
+// IDLSchema → File → Import / Definitions / Namespace / Options，
+// Definitions → Service / Message / Enum / Constant / Typedef，
+// Service → Function → ReturnType / Parameters / Throws，
+// Message → Field → Type → KeyType / ValueType，
+// 以及各个节点上的 Annotation。
+func Walk(root any, fn WalkFunc) {
+	if root == nil || fn == nil {
+		return
+	}
+	w := &walker{fn: fn}
+	w.visit(root)
+}
+
+// Inspect 是 Walk 的简化形式，只关心节点本身而不关心它在树中的位置。
+// 与 go/ast.Inspect 类似，f 返回 false 时跳过该节点的子节点。
+func Inspect(root any, f func(node any) bool) {
+	Walk(root, func(c *Cursor) bool {
+		return f(c.Node())
+	})
+}
+
+type walker struct {
+	fn   WalkFunc
+	path []any
+}
+
+func (w *walker) visit(node any) {
+	if !w.fn(&Cursor{node: node, path: w.path}) {
+		return
+	}
+
+	w.path = append(w.path, node)
+	defer func() { w.path = w.path[:len(w.path)-1] }()
+
+	switch n := node.(type) {
+	case *IDLSchema:
+		for i := range n.Files {
+			w.visit(&n.Files[i])
+		}
+	case *File:
+		for i := range n.Imports {
+			w.visit(&n.Imports[i])
+		}
+		w.visit(&n.Definitions)
+		for i := range n.Namespaces {
+			w.visit(&n.Namespaces[i])
+		}
+		w.visitAnnotations(n.Options)
+	case *Definitions:
+		for i := range n.Services {
+			w.visit(&n.Services[i])
+		}
+		for i := range n.Messages {
+			w.visit(&n.Messages[i])
+		}
+		for i := range n.Enums {
+			w.visit(&n.Enums[i])
+		}
+		for i := range n.Constants {
+			w.visit(&n.Constants[i])
+		}
+		for i := range n.Typedefs {
+			w.visit(&n.Typedefs[i])
+		}
+	case *Service:
+		for i := range n.Functions {
+			w.visit(&n.Functions[i])
+		}
+		w.visitAnnotations(n.Annotations)
+	case *Function:
+		w.visit(&n.ReturnType)
+		w.visitFields(n.Parameters)
+		w.visitFields(n.Throws)
+		w.visitAnnotations(n.Annotations)
+	case *Message:
+		w.visitFields(n.Fields)
+		w.visitAnnotations(n.Annotations)
+	case *Field:
+		w.visit(&n.Type)
+		w.visitAnnotations(n.Annotations)
+	case *Enum:
+		for i := range n.Values {
+			w.visit(&n.Values[i])
+		}
+		w.visitAnnotations(n.Annotations)
+	case *EnumValue:
+		w.visitAnnotations(n.Annotations)
+	case *Constant:
+		w.visit(&n.Type)
+		w.visitAnnotations(n.Annotations)
+	case *Typedef:
+		w.visit(&n.Type)
+		w.visitAnnotations(n.Annotations)
+	case *Type:
+		if n.KeyType != nil {
+			w.visit(n.KeyType)
+		}
+		if n.ValueType != nil {
+			w.visit(n.ValueType)
+		}
+	}
+}
+
+func (w *walker) visitFields(fields []Field) {
+	for i := range fields {
+		w.visit(&fields[i])
+	}
+}
+
+func (w *walker) visitAnnotations(annos []Annotation) {
+	for i := range annos {
+		w.visit(&annos[i])
+	}
+}
@@ -0,0 +1,365 @@
+package idldiff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+// Compare 计算两个 IDLSchema 之间的完整结构差异，包括新增、删除和修改的文件、import、namespace、
+// 定义、字段、函数、枚举成员、注解和注释。定义按 FQN 匹配，字段、参数和 throws 按字段 ID 匹配，
+// 枚举成员按名称匹配。Location、Content 和 Signature 这类只反映源码排版的信息不参与比较。
+func Compare(oldSchema, newSchema *idl_ast.IDLSchema) *Diff {
+	d := &differ{}
+	var oldFiles, newFiles []idl_ast.File
+	if oldSchema != nil {
+		oldFiles = oldSchema.Files
+	}
+	if newSchema != nil {
+		newFiles = newSchema.Files
+	}
+
+	newByPath := make(map[string]*idl_ast.File, len(newFiles))
+	for i := range newFiles {
+		newByPath[newFiles[i].Path] = &newFiles[i]
+	}
+	oldByPath := make(map[string]*idl_ast.File, len(oldFiles))
+	for i := range oldFiles {
+		of := &oldFiles[i]
+		oldByPath[of.Path] = of
+		if nf, ok := newByPath[of.Path]; ok {
+			d.compareFile(of, nf)
+		} else {
+			d.add(Removed, ElementFile, of.Path, of.Path, "", "", "")
+		}
+	}
+	for i := range newFiles {
+		if _, ok := oldByPath[newFiles[i].Path]; !ok {
+			d.add(Added, ElementFile, newFiles[i].Path, newFiles[i].Path, "", "", "")
+		}
+	}
+	return &Diff{Changes: d.changes}
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(t ChangeType, element Element, fqn, file, property, oldValue, newValue string) {
+	d.changes = append(d.changes, Change{
+		Type:     t,
+		Element:  element,
+		FQN:      fqn,
+		Property: property,
+		Old:      oldValue,
+		New:      newValue,
+		File:     file,
+	})
+}
+
+// modified 在 oldValue 与 newValue 不同时记录一次属性修改。
+func (d *differ) modified(element Element, fqn, file, property, oldValue, newValue string) {
+	if oldValue != newValue {
+		d.add(Modified, element, fqn, file, property, oldValue, newValue)
+	}
+}
+
+func (d *differ) compareFile(of, nf *idl_ast.File) {
+	file := nf.Path
+
+	oldImports := make(map[string]bool)
+	for _, imp := range of.Imports {
+		oldImports[imp.Path] = true
+	}
+	newImports := make(map[string]bool)
+	for _, imp := range nf.Imports {
+		newImports[imp.Path] = true
+		if !oldImports[imp.Path] {
+			d.add(Added, ElementImport, file, file, imp.Path, "", imp.Value)
+		}
+	}
+	for _, imp := range of.Imports {
+		if !newImports[imp.Path] {
+			d.add(Removed, ElementImport, file, file, imp.Path, imp.Value, "")
+		}
+	}
+
+	oldNamespaces := make(map[string]string)
+	for _, ns := range of.Namespaces {
+		oldNamespaces[ns.Scope] = ns.Name
+	}
+	newNamespaces := make(map[string]string)
+	for _, ns := range nf.Namespaces {
+		newNamespaces[ns.Scope] = ns.Name
+		oldName, ok := oldNamespaces[ns.Scope]
+		switch {
+		case !ok:
+			d.add(Added, ElementNamespace, file, file, ns.Scope, "", ns.Name)
+		case oldName != ns.Name:
+			d.add(Modified, ElementNamespace, file, file, ns.Scope, oldName, ns.Name)
+		}
+	}
+	for _, ns := range of.Namespaces {
+		if _, ok := newNamespaces[ns.Scope]; !ok {
+			d.add(Removed, ElementNamespace, file, file, ns.Scope, ns.Name, "")
+		}
+	}
+	d.compareAnnotations(file, file, of.Options, nf.Options)
+
+	d.compareDefinitions(of, nf)
+}
+
+// definition 是某个文件中的一个顶层定义。
+type definition struct {
+	fqn     string
+	element Element
+	node    any
+}
+
+func collectDefinitions(file *idl_ast.File) []definition {
+	var res []definition
+	defs := &file.Definitions
+	for i := range defs.Services {
+		res = append(res, definition{defs.Services[i].FullyQualifiedName, ElementService, &defs.Services[i]})
+	}
+	for i := range defs.Messages {
+		res = append(res, definition{defs.Messages[i].FullyQualifiedName, ElementMessage, &defs.Messages[i]})
+	}
+	for i := range defs.Enums {
+		res = append(res, definition{defs.Enums[i].FullyQualifiedName, ElementEnum, &defs.Enums[i]})
+	}
+	for i := range defs.Constants {
+		res = append(res, definition{defs.Constants[i].FullyQualifiedName, ElementConstant, &defs.Constants[i]})
+	}
+	for i := range defs.Typedefs {
+		res = append(res, definition{defs.Typedefs[i].FullyQualifiedName, ElementTypedef, &defs.Typedefs[i]})
+	}
+	return res
+}
+
+func (d *differ) compareDefinitions(of, nf *idl_ast.File) {
+	file := nf.Path
+	oldDefs := collectDefinitions(of)
+	newDefs := collectDefinitions(nf)
+	newByFQN := make(map[string]definition, len(newDefs))
+	for _, def := range newDefs {
+		newByFQN[def.fqn] = def
+	}
+	oldByFQN := make(map[string]definition, len(oldDefs))
+
+	for _, od := range oldDefs {
+		oldByFQN[od.fqn] = od
+		nd, ok := newByFQN[od.fqn]
+		if !ok {
+			d.add(Removed, od.element, od.fqn, of.Path, "", "", "")
+			continue
+		}
+		if od.element != nd.element {
+			d.add(Modified, nd.element, nd.fqn, file, "kind", string(od.element), string(nd.element))
+			continue
+		}
+
+		switch o := od.node.(type) {
+		case *idl_ast.Service:
+			n := nd.node.(*idl_ast.Service)
+			d.modified(ElementService, n.FullyQualifiedName, file, "extends", o.Extends, n.Extends)
+			d.compareComments(n.FullyQualifiedName, file, o.Comments, n.Comments)
+			d.compareAnnotations(n.FullyQualifiedName, file, o.Annotations, n.Annotations)
+			d.compareFunctions(file, o.Functions, n.Functions)
+		case *idl_ast.Message:
+			n := nd.node.(*idl_ast.Message)
+			d.modified(ElementMessage, n.FullyQualifiedName, file, "type", o.Type, n.Type)
+			d.compareComments(n.FullyQualifiedName, file, o.Comments, n.Comments)
+			d.compareAnnotations(n.FullyQualifiedName, file, o.Annotations, n.Annotations)
+			d.compareFields(n.FullyQualifiedName, file, o.Fields, n.Fields)
+		case *idl_ast.Enum:
+			n := nd.node.(*idl_ast.Enum)
+			d.compareComments(n.FullyQualifiedName, file, o.Comments, n.Comments)
+			d.compareAnnotations(n.FullyQualifiedName, file, o.Annotations, n.Annotations)
+			d.compareEnumValues(n.FullyQualifiedName, file, o.Values, n.Values)
+		case *idl_ast.Constant:
+			n := nd.node.(*idl_ast.Constant)
+			d.modified(ElementConstant, n.FullyQualifiedName, file, "type", o.Type.String(), n.Type.String())
+			d.modified(ElementConstant, n.FullyQualifiedName, file, "value", o.Value.String(), n.Value.String())
+			d.compareComments(n.FullyQualifiedName, file, o.Comments, n.Comments)
+			d.compareAnnotations(n.FullyQualifiedName, file, o.Annotations, n.Annotations)
+		case *idl_ast.Typedef:
+			n := nd.node.(*idl_ast.Typedef)
+			d.modified(ElementTypedef, n.FullyQualifiedName, file, "type", o.Type.String(), n.Type.String())
+			d.compareComments(n.FullyQualifiedName, file, o.Comments, n.Comments)
+			d.compareAnnotations(n.FullyQualifiedName, file, o.Annotations, n.Annotations)
+		}
+	}
+
+	for _, nd := range newDefs {
+		if _, ok := oldByFQN[nd.fqn]; !ok {
+			d.add(Added, nd.element, nd.fqn, file, "", "", "")
+		}
+	}
+}
+
+func (d *differ) compareFunctions(file string, oldFuncs, newFuncs []idl_ast.Function) {
+	newByName := make(map[string]*idl_ast.Function, len(newFuncs))
+	for i := range newFuncs {
+		newByName[newFuncs[i].Name] = &newFuncs[i]
+	}
+	oldByName := make(map[string]bool, len(oldFuncs))
+	for i := range oldFuncs {
+		of := &oldFuncs[i]
+		oldByName[of.Name] = true
+		nf, ok := newByName[of.Name]
+		if !ok {
+			d.add(Removed, ElementFunction, of.FullyQualifiedName, file, "", "", "")
+			continue
+		}
+		d.modified(ElementFunction, nf.FullyQualifiedName, file, "returnType", of.ReturnType.String(), nf.ReturnType.String())
+		d.modified(ElementFunction, nf.FullyQualifiedName, file, "oneway", strconv.FormatBool(of.Oneway), strconv.FormatBool(nf.Oneway))
+		d.compareComments(nf.FullyQualifiedName, file, of.Comments, nf.Comments)
+		d.compareAnnotations(nf.FullyQualifiedName, file, of.Annotations, nf.Annotations)
+		// 参数和 throws 各自编号，字段 ID 可能重复，因此用不同的所有者 FQN 区分两个列表。
+		d.compareFields(nf.FullyQualifiedName+"(params)", file, of.Parameters, nf.Parameters)
+		d.compareFields(nf.FullyQualifiedName+"(throws)", file, of.Throws, nf.Throws)
+	}
+	for i := range newFuncs {
+		if !oldByName[newFuncs[i].Name] {
+			d.add(Added, ElementFunction, newFuncs[i].FullyQualifiedName, file, "", "", "")
+		}
+	}
+}
+
+func (d *differ) compareFields(ownerFQN, file string, oldFields, newFields []idl_ast.Field) {
+	newByID := make(map[int]*idl_ast.Field, len(newFields))
+	for i := range newFields {
+		newByID[newFields[i].ID] = &newFields[i]
+	}
+	oldIDs := make(map[int]bool, len(oldFields))
+	for i := range oldFields {
+		of := &oldFields[i]
+		oldIDs[of.ID] = true
+		nf, ok := newByID[of.ID]
+		if !ok {
+			d.add(Removed, ElementField, ownerFQN+"."+of.Name, file, "", fieldString(of), "")
+			continue
+		}
+		fqn := ownerFQN + "." + nf.Name
+		d.modified(ElementField, fqn, file, "name", of.Name, nf.Name)
+		d.modified(ElementField, fqn, file, "type", of.Type.String(), nf.Type.String())
+		d.modified(ElementField, fqn, file, "required", of.Required, nf.Required)
+		d.modified(ElementField, fqn, file, "defaultValue", of.DefaultValue.String(), nf.DefaultValue.String())
+		d.compareComments(fqn, file, of.Comments, nf.Comments)
+		d.compareAnnotations(fqn, file, of.Annotations, nf.Annotations)
+	}
+	for i := range newFields {
+		nf := &newFields[i]
+		if !oldIDs[nf.ID] {
+			d.add(Added, ElementField, ownerFQN+"."+nf.Name, file, "", "", fieldString(nf))
+		}
+	}
+}
+
+func (d *differ) compareEnumValues(enumFQN, file string, oldValues, newValues []idl_ast.EnumValue) {
+	newByName := make(map[string]*idl_ast.EnumValue, len(newValues))
+	for i := range newValues {
+		newByName[newValues[i].Name] = &newValues[i]
+	}
+	oldByName := make(map[string]bool, len(oldValues))
+	for i := range oldValues {
+		ov := &oldValues[i]
+		oldByName[ov.Name] = true
+		fqn := enumFQN + "." + ov.Name
+		nv, ok := newByName[ov.Name]
+		if !ok {
+			d.add(Removed, ElementEnumValue, fqn, file, "", fmt.Sprint(ov.Value), "")
+			continue
+		}
+		d.modified(ElementEnumValue, fqn, file, "value", fmt.Sprint(ov.Value), fmt.Sprint(nv.Value))
+		d.compareComments(fqn, file, ov.Comments, nv.Comments)
+		d.compareAnnotations(fqn, file, ov.Annotations, nv.Annotations)
+	}
+	for i := range newValues {
+		nv := &newValues[i]
+		if !oldByName[nv.Name] {
+			d.add(Added, ElementEnumValue, enumFQN+"."+nv.Name, file, "", "", fmt.Sprint(nv.Value))
+		}
+	}
+}
+
+// compareAnnotations 按名称比较注解。同名注解出现多次时按出现顺序一一对应。
+func (d *differ) compareAnnotations(ownerFQN, file string, oldAnnos, newAnnos []idl_ast.Annotation) {
+	oldByName := groupAnnotations(oldAnnos)
+	newByName := groupAnnotations(newAnnos)
+
+	for _, name := range annotationNames(oldAnnos, newAnnos) {
+		olds, news := oldByName[name], newByName[name]
+		for i := 0; i < len(olds) || i < len(news); i++ {
+			switch {
+			case i >= len(news):
+				d.add(Removed, ElementAnnotation, ownerFQN, file, name, olds[i], "")
+			case i >= len(olds):
+				d.add(Added, ElementAnnotation, ownerFQN, file, name, "", news[i])
+			default:
+				d.modified(ElementAnnotation, ownerFQN, file, name, olds[i], news[i])
+			}
+		}
+	}
+}
+
+func groupAnnotations(annos []idl_ast.Annotation) map[string][]string {
+	res := make(map[string][]string)
+	for _, a := range annos {
+		res[a.Name] = append(res[a.Name], a.Value.String())
+	}
+	return res
+}
+
+// annotationNames 按首次出现的顺序返回两组注解中的所有名称。
+func annotationNames(oldAnnos, newAnnos []idl_ast.Annotation) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, annos := range [][]idl_ast.Annotation{oldAnnos, newAnnos} {
+		for _, a := range annos {
+			if !seen[a.Name] {
+				seen[a.Name] = true
+				names = append(names, a.Name)
+			}
+		}
+	}
+	return names
+}
+
+// compareComments 比较元素上注释的文本，注释的位置不参与比较。
+func (d *differ) compareComments(ownerFQN, file string, oldComments, newComments []idl_ast.Comment) {
+	oldText, newText := commentText(oldComments), commentText(newComments)
+	switch {
+	case oldText == newText:
+	case oldText == "":
+		d.add(Added, ElementComment, ownerFQN, file, "", "", newText)
+	case newText == "":
+		d.add(Removed, ElementComment, ownerFQN, file, "", oldText, "")
+	default:
+		d.add(Modified, ElementComment, ownerFQN, file, "", oldText, newText)
+	}
+}
+
+func commentText(comments []idl_ast.Comment) string {
+	texts := make([]string, len(comments))
+	for i, c := range comments {
+		texts[i] = strings.TrimSpace(c.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+func fieldString(f *idl_ast.Field) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d: ", f.ID))
+	if f.Required != "" {
+		sb.WriteString(f.Required + " ")
+	}
+	sb.WriteString(f.Type.String() + " " + f.Name)
+	if f.DefaultValue != nil {
+		sb.WriteString(" = " + f.DefaultValue.String())
+	}
+	return sb.String()
+}
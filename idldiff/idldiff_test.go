@@ -0,0 +1,131 @@
+package idldiff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/internal/idltest"
+)
+
+func hasChange(diff *Diff, want Change) bool {
+	for _, c := range diff.Changes {
+		if c == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompare(t *testing.T) {
+	oldSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(`
+namespace go user
+
+enum Gender {
+  MALE = 1,
+  FEMALE = 2
+}
+
+// 用户信息
+struct User {
+  1: i64 id
+  2: string name (api.query = "name")
+  3: Gender gender
+}
+
+service UserService {
+  User getUser(1: i64 id) (api.get = "/user")
+  void ping()
+}
+`)})
+	newSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(`
+namespace go user.v2
+
+enum Gender {
+  MALE = 1,
+  FEMALE = 3,
+  OTHER = 4
+}
+
+// 用户的基本信息
+struct User {
+
+
+  1: i64 id
+  2: required string name (api.query = "user_name")
+  4: optional string email
+}
+
+service UserService {
+  User getUser(1: i64 id, 2: bool verbose) (api.get = "/v2/user")
+}
+
+struct Empty {}
+`)})
+
+	diff := Compare(oldSchema, newSchema)
+	file := "user.thrift"
+	wants := []Change{
+		{Type: Modified, Element: ElementNamespace, FQN: file, Property: "go", Old: "user", New: "user.v2", File: file},
+		{Type: Modified, Element: ElementEnumValue, FQN: "user.thrift#Gender.FEMALE", Property: "value", Old: "2", New: "3", File: file},
+		{Type: Added, Element: ElementEnumValue, FQN: "user.thrift#Gender.OTHER", New: "4", File: file},
+		{Type: Modified, Element: ElementComment, FQN: "user.thrift#User", Old: "// 用户信息", New: "// 用户的基本信息", File: file},
+		{Type: Modified, Element: ElementField, FQN: "user.thrift#User.name", Property: "required", Old: "optional", New: "required", File: file},
+		{Type: Modified, Element: ElementAnnotation, FQN: "user.thrift#User.name", Property: "api.query", Old: `"name"`, New: `"user_name"`, File: file},
+		{Type: Removed, Element: ElementField, FQN: "user.thrift#User.gender", Old: "3: optional Gender gender", File: file},
+		{Type: Added, Element: ElementField, FQN: "user.thrift#User.email", New: "4: optional string email", File: file},
+		{Type: Modified, Element: ElementAnnotation, FQN: "user.thrift#UserService.getUser", Property: "api.get", Old: `"/user"`, New: `"/v2/user"`, File: file},
+		{Type: Added, Element: ElementField, FQN: "user.thrift#UserService.getUser(params).verbose", New: "2: optional bool verbose", File: file},
+		{Type: Removed, Element: ElementFunction, FQN: "user.thrift#UserService.ping", File: file},
+		{Type: Added, Element: ElementMessage, FQN: "user.thrift#Empty", File: file},
+	}
+	for _, want := range wants {
+		assert.True(t, hasChange(diff, want), "missing change %+v", want)
+	}
+	// 只有位置发生变化的元素（例如 User.id）不应出现在结果中。
+	for _, c := range diff.Changes {
+		assert.NotEqual(t, "user.thrift#User.id", c.FQN)
+	}
+	assert.Len(t, diff.Changes, len(wants))
+
+	data, err := diff.JSON()
+	require.NoError(t, err)
+	var decoded Diff
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, diff.Changes, decoded.Changes)
+
+	md := diff.Markdown()
+	assert.Contains(t, md, "**12** change(s): 4 added, 2 removed, 6 modified.")
+	assert.Contains(t, md, "### `user.thrift`")
+	assert.Contains(t, md, "| modified | field | `user.thrift#User.name` | required: `optional` → `required` |")
+}
+
+func TestCompare_ParamsAndThrowsShareIDs(t *testing.T) {
+	oldSchema := idltest.ParseThrift(t, map[string][]byte{"a.thrift": []byte(`
+exception Err {}
+service Svc {
+  void fn(1: string req) throws (1: Err err)
+}
+`)})
+	newSchema := idltest.ParseThrift(t, map[string][]byte{"a.thrift": []byte(`
+exception Err {}
+service Svc {
+  void fn(1: i64 req) throws (1: Err failure)
+}
+`)})
+
+	diff := Compare(oldSchema, newSchema)
+	assert.Equal(t, []Change{
+		{Type: Modified, Element: ElementField, FQN: "a.thrift#Svc.fn(params).req", Property: "type", Old: "string", New: "i64", File: "a.thrift"},
+		{Type: Modified, Element: ElementField, FQN: "a.thrift#Svc.fn(throws).failure", Property: "name", Old: "err", New: "failure", File: "a.thrift"},
+	}, diff.Changes)
+}
+
+func TestCompare_Identical(t *testing.T) {
+	files := map[string][]byte{"a.thrift": []byte(`struct A { 1: string a }`)}
+	diff := Compare(idltest.ParseThrift(t, files), idltest.ParseThrift(t, files))
+	assert.True(t, diff.IsEmpty())
+	assert.Contains(t, diff.Markdown(), "No changes.")
+}
@@ -0,0 +1,126 @@
+package idldiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChangeType 表示元素是被新增、删除还是修改。
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Modified ChangeType = "modified"
+)
+
+// Element 表示发生变化的元素种类。
+type Element string
+
+const (
+	ElementFile       Element = "file"
+	ElementImport     Element = "import"
+	ElementNamespace  Element = "namespace"
+	ElementService    Element = "service"
+	ElementFunction   Element = "function"
+	ElementMessage    Element = "message" // struct、union 或 exception
+	ElementField      Element = "field"   // Message 字段、函数参数或 throws 中的异常
+	ElementEnum       Element = "enum"
+	ElementEnumValue  Element = "enumValue"
+	ElementConstant   Element = "constant"
+	ElementTypedef    Element = "typedef"
+	ElementAnnotation Element = "annotation"
+	ElementComment    Element = "comment"
+)
+
+// Change 描述两个 schema 之间的一处结构差异。
+type Change struct {
+	Type    ChangeType `json:"type"`
+	Element Element    `json:"element"`
+	// FQN 标识发生变化的元素，例如 "main.thrift#User"、"main.thrift#User.name"、
+	// "main.thrift#UserService.getUser(params).req" 或 "main.thrift#UserService.getUser(throws).err"。
+	// 文件、import 和 namespace 使用文件路径。
+	// 注解和注释的 FQN 是它们所属元素的 FQN。
+	FQN string `json:"fqn"`
+	// Property 是被修改的属性，例如 "type"、"required"、"defaultValue"、"extends"，
+	// 对注解来说是注解名，对 import 和 namespace 来说是 include 路径和 scope。
+	Property string `json:"property,omitempty"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+	// File 是元素所在文件的路径（新增和修改时为新版本中的路径，删除时为旧版本中的路径）。
+	File string `json:"file"`
+}
+
+// Diff 是两个 IDLSchema 之间的结构差异，按文件和 AST 顺序排列。
+type Diff struct {
+	Changes []Change `json:"changes"`
+}
+
+// IsEmpty 检查两个 schema 之间是否没有任何结构差异。
+func (d *Diff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// JSON 以缩进格式返回差异的 JSON 表示。
+func (d *Diff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Markdown 返回一份按文件分组的 Markdown 报告，可以直接贴到 code review 中。
+func (d *Diff) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("## Schema diff\n\n")
+	if d.IsEmpty() {
+		sb.WriteString("No changes.\n")
+		return sb.String()
+	}
+
+	counts := make(map[ChangeType]int)
+	var files []string
+	byFile := make(map[string][]Change)
+	for _, c := range d.Changes {
+		counts[c.Type]++
+		if _, ok := byFile[c.File]; !ok {
+			files = append(files, c.File)
+		}
+		byFile[c.File] = append(byFile[c.File], c)
+	}
+	sb.WriteString(fmt.Sprintf("**%d** change(s): %d added, %d removed, %d modified.\n",
+		len(d.Changes), counts[Added], counts[Removed], counts[Modified]))
+
+	for _, file := range files {
+		sb.WriteString(fmt.Sprintf("\n### `%s`\n\n", file))
+		sb.WriteString("| Change | Element | Name | Details |\n")
+		sb.WriteString("| --- | --- | --- | --- |\n")
+		for _, c := range byFile[file] {
+			sb.WriteString(fmt.Sprintf("| %s | %s | `%s` | %s |\n", c.Type, c.Element, escapeCell(c.FQN), details(c)))
+		}
+	}
+	return sb.String()
+}
+
+func details(c Change) string {
+	var parts []string
+	if c.Property != "" {
+		parts = append(parts, escapeCell(c.Property))
+	}
+	switch {
+	case c.Old != "" && c.New != "":
+		parts = append(parts, fmt.Sprintf("%s → %s", code(c.Old), code(c.New)))
+	case c.Old != "":
+		parts = append(parts, code(c.Old))
+	case c.New != "":
+		parts = append(parts, code(c.New))
+	}
+	return strings.Join(parts, ": ")
+}
+
+func code(s string) string {
+	return "`" + escapeCell(strings.ReplaceAll(s, "`", "'")) + "`"
+}
+
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
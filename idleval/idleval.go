@@ -0,0 +1,453 @@
+package idleval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+// Evaluate 计算 schema 中每个常量、struct 字段默认值和函数参数默认值的有效值。
+// 求值时会解析对其他常量和枚举成员（例如 Gender.MALE）的引用、展开 typedef，
+// 并按声明的类型做检查：字符串赋给整数、超出 i8 范围的整数、把列表赋给 map 等都会作为 Problem 报告。
+// 存在 Problem 时，返回的 error 就是 *Report 本身；求值成功的结果仍然保留在 Report.Results 中。
+func Evaluate(schema *idl_ast.IDLSchema) (*Report, error) {
+	if schema == nil {
+		return nil, errors.New("schema must be non-nil")
+	}
+	e := &evaluator{schema: schema, constants: make(map[string]*constantState)}
+	report := &Report{}
+	for i := range schema.Files {
+		file := &schema.Files[i]
+		for _, def := range file.Definitions.Ordered() {
+			switch d := def.(type) {
+			case *idl_ast.Constant:
+				if value, ok := e.evalConstant(d, file.Path); ok {
+					report.Results = append(report.Results, Result{FQN: constantFQN(d, file.Path), File: file.Path, Location: d.Location, Type: &d.Type, Value: value})
+				}
+			case *idl_ast.Message:
+				report.Results = append(report.Results, e.evalDefaults(file.Path, messageFQN(d, file.Path), d.Fields)...)
+			case *idl_ast.Service:
+				for j := range d.Functions {
+					fn := &d.Functions[j]
+					fnFQN := fn.FullyQualifiedName
+					if fnFQN == "" {
+						fnFQN = fmt.Sprintf("%s#%s.%s", file.Path, d.Name, fn.Name)
+					}
+					report.Results = append(report.Results, e.evalDefaults(file.Path, fnFQN, fn.Parameters)...)
+				}
+			}
+		}
+	}
+
+	report.Problems = e.problems
+	if len(report.Problems) > 0 {
+		return report, report
+	}
+	return report, nil
+}
+
+type evaluator struct {
+	schema    *idl_ast.IDLSchema
+	constants map[string]*constantState
+	problems  []Problem
+}
+
+// constantState 记录常量的求值状态，用于缓存结果和检测循环引用。
+type constantState struct {
+	evaluating bool
+	value      any
+	ok         bool
+}
+
+// site 是当前正在求值的常量或字段，问题会报告在它上面。
+type site struct {
+	fqn  string
+	file string
+	loc  *idl_ast.Location
+}
+
+func (e *evaluator) report(s site, format string, args ...any) {
+	e.problems = append(e.problems, Problem{FQN: s.fqn, File: s.file, Location: s.loc, Message: fmt.Sprintf(format, args...)})
+}
+
+func constantFQN(c *idl_ast.Constant, file string) string {
+	if c.FullyQualifiedName != "" {
+		return c.FullyQualifiedName
+	}
+	return file + "#" + c.Name
+}
+
+func messageFQN(m *idl_ast.Message, file string) string {
+	if m.FullyQualifiedName != "" {
+		return m.FullyQualifiedName
+	}
+	return file + "#" + m.Name
+}
+
+func (e *evaluator) evalConstant(c *idl_ast.Constant, file string) (any, bool) {
+	fqn := constantFQN(c, file)
+	if state, ok := e.constants[fqn]; ok {
+		return state.value, state.ok
+	}
+	state := &constantState{evaluating: true}
+	e.constants[fqn] = state
+	state.value, state.ok = e.eval(site{fqn: fqn, file: file, loc: c.Location}, c.Value, &c.Type)
+	state.evaluating = false
+	return state.value, state.ok
+}
+
+func (e *evaluator) evalDefaults(file, ownerFQN string, fields []idl_ast.Field) []Result {
+	var res []Result
+	for i := range fields {
+		f := &fields[i]
+		if f.DefaultValue == nil {
+			continue
+		}
+		fqn := ownerFQN + "." + f.Name
+		if value, ok := e.eval(site{fqn: fqn, file: file, loc: f.Location}, f.DefaultValue, &f.Type); ok {
+			res = append(res, Result{FQN: fqn, File: file, Location: f.Location, Type: &f.Type, Value: value})
+		}
+	}
+	return res
+}
+
+// enumMember 是对枚举成员的引用，转换为 enum 类型时需要确认它属于同一个枚举。
+type enumMember struct {
+	enum  *idl_ast.Enum
+	name  string
+	value int64
+}
+
+// eval 按类型 t 计算常量值 cv。t 为 nil 或者无法解析时不做类型检查。
+func (e *evaluator) eval(s site, cv *idl_ast.ConstantValue, t *idl_ast.Type) (any, bool) {
+	if cv == nil || cv.Value == nil {
+		e.report(s, "missing value")
+		return nil, false
+	}
+	rt, named := e.resolveType(t)
+
+	switch cv.InferKind() {
+	case idl_ast.ConstantIdentifier:
+		ident, _ := cv.Value.(string)
+		return e.evalIdentifier(s, ident, cv.FullyQualifiedName, t)
+	case idl_ast.ConstantString:
+		text, _ := cv.Value.(string)
+		return e.convert(s, unquote(text), t)
+	case idl_ast.ConstantList:
+		items, _ := cv.Value.([]*idl_ast.ConstantValue)
+		if rt != nil && rt.Name != "list" && rt.Name != "set" {
+			e.report(s, "cannot use list as %s", t.String())
+			return nil, false
+		}
+		var elemType *idl_ast.Type
+		if rt != nil {
+			elemType = rt.ValueType
+		}
+		res := make([]any, 0, len(items))
+		ok := true
+		for _, item := range items {
+			v, itemOK := e.eval(s, item, elemType)
+			res = append(res, v)
+			ok = ok && itemOK
+		}
+		return res, ok
+	case idl_ast.ConstantMap:
+		entries, _ := cv.Value.([]*idl_ast.ConstantMapEntry)
+		if msg, isMessage := named.(*idl_ast.Message); isMessage {
+			return e.evalMessage(s, entries, msg)
+		}
+		if rt != nil && rt.Name != "map" {
+			e.report(s, "cannot use map as %s", t.String())
+			return nil, false
+		}
+		var keyType, valueType *idl_ast.Type
+		if rt != nil {
+			keyType, valueType = rt.KeyType, rt.ValueType
+		}
+		res := make([]MapEntry, 0, len(entries))
+		ok := true
+		for _, entry := range entries {
+			k, keyOK := e.eval(s, entry.Key, keyType)
+			v, valueOK := e.eval(s, entry.Value, valueType)
+			res = append(res, MapEntry{Key: k, Value: v})
+			ok = ok && keyOK && valueOK
+		}
+		return res, ok
+	default:
+		return e.convert(s, cv.Value, t)
+	}
+}
+
+func (e *evaluator) evalIdentifier(s site, ident, fqn string, t *idl_ast.Type) (any, bool) {
+	if fqn == "" {
+		e.report(s, "unresolved identifier %s", ident)
+		return nil, false
+	}
+	switch target := e.lookup(fqn).(type) {
+	case *idl_ast.Constant:
+		path, _, _ := idl_ast.SplitFQN(fqn)
+		if state, ok := e.constants[fqn]; ok && state.evaluating {
+			e.report(s, "reference cycle through constant %s", ident)
+			return nil, false
+		}
+		v, ok := e.evalConstant(target, path)
+		if !ok {
+			e.report(s, "constant %s has no valid value", ident)
+			return nil, false
+		}
+		return e.convert(s, v, t)
+	case *idl_ast.EnumValue:
+		enumFQN := fqn[:strings.LastIndex(fqn, ".")]
+		enum, _ := e.lookup(enumFQN).(*idl_ast.Enum)
+		return e.convert(s, enumMember{enum: enum, name: ident, value: int64(target.Value)}, t)
+	}
+	e.report(s, "identifier %s does not refer to a constant or enum value", ident)
+	return nil, false
+}
+
+// evalMessage 把 map 字面量当作 struct、union 或 exception 求值，键必须是字段名。
+func (e *evaluator) evalMessage(s site, entries []*idl_ast.ConstantMapEntry, msg *idl_ast.Message) (any, bool) {
+	fields := make(map[string]*idl_ast.Field, len(msg.Fields))
+	for i := range msg.Fields {
+		fields[msg.Fields[i].Name] = &msg.Fields[i]
+	}
+	res := make(map[string]any, len(entries))
+	ok := true
+	for _, entry := range entries {
+		key, keyOK := e.eval(s, entry.Key, &idl_ast.Type{Name: "string", IsPrimitive: true})
+		name, _ := key.(string)
+		field, exists := fields[name]
+		switch {
+		case !keyOK:
+			ok = false
+			continue
+		case !exists:
+			e.report(s, "%s %s has no field %q", msg.Type, msg.Name, name)
+			ok = false
+			continue
+		}
+		v, valueOK := e.eval(s, entry.Value, &field.Type)
+		res[name] = v
+		ok = ok && valueOK
+	}
+	if msg.Type == "union" && len(res) > 1 {
+		e.report(s, "union %s sets %d fields, at most one is allowed", msg.Name, len(res))
+		ok = false
+	}
+	return res, ok
+}
+
+// convert 检查已经求出的值 v 能否赋给类型 t，并把它转换为 t 对应的 Go 表示。
+func (e *evaluator) convert(s site, v any, t *idl_ast.Type) (any, bool) {
+	rt, named := e.resolveType(t)
+	if member, isMember := v.(enumMember); isMember {
+		if enum, isEnum := named.(*idl_ast.Enum); isEnum && member.enum != nil && member.enum != enum {
+			e.report(s, "cannot use %s as %s", member.name, t.String())
+			return nil, false
+		}
+		v = member.value
+	}
+	if rt == nil {
+		return v, true
+	}
+
+	mismatch := func() (any, bool) {
+		e.report(s, "cannot use %s as %s", describe(v), t.String())
+		return nil, false
+	}
+	switch target := named.(type) {
+	case *idl_ast.Enum:
+		n, isInt := v.(int64)
+		if !isInt {
+			return mismatch()
+		}
+		for _, ev := range target.Values {
+			if int64(ev.Value) == n {
+				return n, true
+			}
+		}
+		e.report(s, "%d is not a value of enum %s", n, target.Name)
+		return nil, false
+	case *idl_ast.Message:
+		fields, isFields := v.(map[string]any)
+		if !isFields {
+			return mismatch()
+		}
+		for name := range fields {
+			if !hasField(target, name) {
+				e.report(s, "%s %s has no field %q", target.Type, target.Name, name)
+				return nil, false
+			}
+		}
+		return fields, true
+	}
+
+	switch rt.Name {
+	case "bool":
+		switch b := v.(type) {
+		case bool:
+			return b, true
+		case int64:
+			if b == 0 || b == 1 {
+				return b == 1, true
+			}
+		}
+		return mismatch()
+	case "byte", "i8", "i16", "i32", "i64":
+		n, isInt := v.(int64)
+		if !isInt {
+			return mismatch()
+		}
+		if min, max := intRange(rt.Name); n < min || n > max {
+			e.report(s, "value %d overflows %s", n, rt.Name)
+			return nil, false
+		}
+		return n, true
+	case "double":
+		switch f := v.(type) {
+		case float64:
+			return f, true
+		case int64:
+			return float64(f), true
+		}
+		return mismatch()
+	case "string", "binary":
+		if str, isString := v.(string); isString {
+			return str, true
+		}
+		return mismatch()
+	case "list", "set":
+		items, isList := v.([]any)
+		if !isList {
+			return mismatch()
+		}
+		res := make([]any, 0, len(items))
+		for _, item := range items {
+			converted, ok := e.convert(s, item, rt.ValueType)
+			if !ok {
+				return nil, false
+			}
+			res = append(res, converted)
+		}
+		return res, true
+	case "map":
+		entries, isMap := v.([]MapEntry)
+		if !isMap {
+			return mismatch()
+		}
+		res := make([]MapEntry, 0, len(entries))
+		for _, entry := range entries {
+			k, keyOK := e.convert(s, entry.Key, rt.KeyType)
+			val, valueOK := e.convert(s, entry.Value, rt.ValueType)
+			if !keyOK || !valueOK {
+				return nil, false
+			}
+			res = append(res, MapEntry{Key: k, Value: val})
+		}
+		return res, true
+	}
+	// 无法识别的类型（例如没有解析出 FQN 的自定义类型）不做检查。
+	return v, true
+}
+
+// resolveType 展开 typedef，返回最终的类型以及它引用的 enum 或 message（基本类型和容器为 nil）。
+func (e *evaluator) resolveType(t *idl_ast.Type) (*idl_ast.Type, any) {
+	if t == nil {
+		return nil, nil
+	}
+	if resolved, err := e.schema.ResolveType(t); err == nil {
+		t = resolved.Type
+	}
+	if t.FullyQualifiedName == "" {
+		return t, nil
+	}
+	switch def := e.lookup(t.FullyQualifiedName).(type) {
+	case *idl_ast.Enum, *idl_ast.Message:
+		return t, def
+	}
+	return t, nil
+}
+
+func (e *evaluator) lookup(fqn string) any {
+	if defs := e.schema.FindByFQN(fqn); len(defs) > 0 {
+		return defs[0]
+	}
+	return nil
+}
+
+func hasField(msg *idl_ast.Message, name string) bool {
+	for _, f := range msg.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func intRange(name string) (int64, int64) {
+	switch name {
+	case "byte", "i8":
+		return math.MinInt8, math.MaxInt8
+	case "i16":
+		return math.MinInt16, math.MaxInt16
+	case "i32":
+		return math.MinInt32, math.MaxInt32
+	}
+	return math.MinInt64, math.MaxInt64
+}
+
+// unquote 去掉字符串字面量两端的引号并处理其中的转义。没有引号的字符串按原样返回。
+func unquote(text string) string {
+	if len(text) < 2 || (text[0] != '"' && text[0] != '\'') || text[len(text)-1] != text[0] {
+		return text
+	}
+	body := text[1 : len(text)-1]
+	if !strings.Contains(body, `\`) {
+		return body
+	}
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] == '\\' && i+1 < len(body) {
+			i++
+			switch body[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"', '\'', '\\':
+				sb.WriteByte(body[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(body[i])
+			}
+			continue
+		}
+		sb.WriteByte(body[i])
+	}
+	return sb.String()
+}
+
+func describe(v any) string {
+	switch x := v.(type) {
+	case string:
+		return fmt.Sprintf("string %q", x)
+	case int64:
+		return fmt.Sprintf("integer %d", x)
+	case float64:
+		return fmt.Sprintf("double %v", x)
+	case bool:
+		return fmt.Sprintf("bool %v", x)
+	case []any:
+		return "list"
+	case []MapEntry:
+		return "map"
+	case map[string]any:
+		return "struct"
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,141 @@
+package idleval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/internal/idltest"
+)
+
+func TestEvaluate(t *testing.T) {
+	schema := idltest.ParseThrift(t, map[string][]byte{
+		"common/base.thrift": []byte(`
+enum Gender {
+  UNKNOWN = 0,
+  MALE = 1,
+  FEMALE = 2
+}
+
+typedef i32 Count
+const Count PAGE_SIZE = 20
+`),
+		"main.thrift": []byte(`
+include "common/base.thrift"
+
+typedef base.Gender Sex
+
+const base.Gender DEFAULT_GENDER = base.Gender.MALE
+const i64 MAX_SIZE = base.PAGE_SIZE
+const double RATIO = 1
+const string GREETING = "say \"hi\""
+const list<Sex> GENDERS = [base.Gender.MALE, 2]
+const map<string, base.Count> LIMITS = {"page": base.PAGE_SIZE, "max": 100}
+
+struct Point {
+  1: i32 x
+  2: i32 y
+}
+
+const Point ORIGIN = {"x": 0, "y": 0}
+
+struct Query {
+  1: Sex gender = DEFAULT_GENDER
+  2: i32 size = base.PAGE_SIZE
+  3: bool verbose = 0
+  4: optional string name
+}
+
+service Search {
+  list<Query> search(1: i32 limit = 10)
+}
+`),
+	})
+
+	report, err := Evaluate(schema)
+	require.NoError(t, err)
+
+	expected := map[string]any{
+		"common/base.thrift#PAGE_SIZE":    int64(20),
+		"main.thrift#DEFAULT_GENDER":      int64(1),
+		"main.thrift#MAX_SIZE":            int64(20),
+		"main.thrift#RATIO":               float64(1),
+		"main.thrift#GREETING":            `say "hi"`,
+		"main.thrift#GENDERS":             []any{int64(1), int64(2)},
+		"main.thrift#LIMITS":              []MapEntry{{Key: "page", Value: int64(20)}, {Key: "max", Value: int64(100)}},
+		"main.thrift#ORIGIN":              map[string]any{"x": int64(0), "y": int64(0)},
+		"main.thrift#Query.gender":        int64(1),
+		"main.thrift#Query.size":          int64(20),
+		"main.thrift#Query.verbose":       false,
+		"main.thrift#Search.search.limit": int64(10),
+	}
+	assert.Len(t, report.Results, len(expected))
+	for fqn, value := range expected {
+		res, ok := report.Lookup(fqn)
+		if assert.True(t, ok, fqn) {
+			assert.Equal(t, value, res.Value, fqn)
+		}
+	}
+	_, ok := report.Lookup("main.thrift#Query.name")
+	assert.False(t, ok)
+}
+
+func TestEvaluate_Problems(t *testing.T) {
+	schema := idltest.ParseThrift(t, map[string][]byte{
+		"main.thrift": []byte(`
+enum Gender {
+  MALE = 1
+}
+
+enum Color {
+  RED = 1
+}
+
+const i8 SMALL = 300
+const i32 NAME = "abc"
+const map<string, i32> COUNTS = [1, 2]
+const Gender WRONG_ENUM = Color.RED
+const Gender MISSING_VALUE = 5
+const i32 UNKNOWN = Missing.VALUE
+const i32 CYCLE_A = CYCLE_B
+const i32 CYCLE_B = CYCLE_A
+
+struct Point {
+  1: i32 x
+}
+
+struct Query {
+  1: i32 limit = "ten"
+  2: Point origin = {"x": 1, "z": 2}
+  3: i32 ok = 1
+}
+`),
+	})
+
+	report, err := Evaluate(schema)
+	require.Error(t, err)
+	assert.Same(t, report, err)
+
+	problems := make(map[string]string)
+	for _, p := range report.Problems {
+		assert.NotNil(t, p.Location, p.FQN)
+		if _, exists := problems[p.FQN]; !exists {
+			problems[p.FQN] = p.Message
+		}
+	}
+	assert.Equal(t, "value 300 overflows i8", problems["main.thrift#SMALL"])
+	assert.Equal(t, `cannot use string "abc" as i32`, problems["main.thrift#NAME"])
+	assert.Equal(t, "cannot use list as map<string,i32>", problems["main.thrift#COUNTS"])
+	assert.Equal(t, "cannot use Color.RED as Gender", problems["main.thrift#WRONG_ENUM"])
+	assert.Equal(t, "5 is not a value of enum Gender", problems["main.thrift#MISSING_VALUE"])
+	assert.Equal(t, "unresolved identifier Missing.VALUE", problems["main.thrift#UNKNOWN"])
+	assert.Contains(t, problems["main.thrift#CYCLE_B"], "reference cycle")
+	assert.Equal(t, `cannot use string "ten" as i32`, problems["main.thrift#Query.limit"])
+	assert.Equal(t, `struct Point has no field "z"`, problems["main.thrift#Query.origin"])
+
+	res, ok := report.Lookup("main.thrift#Query.ok")
+	require.True(t, ok)
+	assert.Equal(t, int64(1), res.Value)
+	assert.Contains(t, report.Error(), "main.thrift#SMALL")
+}
@@ -0,0 +1,80 @@
+package idleval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+// Result 是一个常量或字段默认值求值后的有效值。
+//
+// Value 的 Go 类型由声明的类型（展开 typedef 之后）决定：
+//   - bool: bool
+//   - byte、i8、i16、i32、i64 和 enum: int64
+//   - double: float64
+//   - string、binary: string（已去掉引号并处理转义）
+//   - list、set: []any
+//   - map: []MapEntry，保持源码中的顺序
+//   - struct、union、exception: map[string]any，键为字段名
+type Result struct {
+	// FQN 是常量或字段的完全限定名，例如 "main.thrift#LIMIT"、"main.thrift#Query.limit"
+	// 或 "main.thrift#Svc.search.limit"。
+	FQN      string            `json:"fqn"`
+	File     string            `json:"file"`
+	Location *idl_ast.Location `json:"location,omitempty"`
+	Type     *idl_ast.Type     `json:"type"`
+	Value    any               `json:"value"`
+}
+
+// MapEntry 是 map 常量中的一个键值对。
+type MapEntry struct {
+	Key   any `json:"key"`
+	Value any `json:"value"`
+}
+
+// Problem 描述求值过程中发现的一个问题，例如类型不匹配、整数越界或无法解析的标识符。
+type Problem struct {
+	FQN      string            `json:"fqn"`
+	File     string            `json:"file"`
+	Location *idl_ast.Location `json:"location,omitempty"`
+	Message  string            `json:"message"`
+}
+
+func (p Problem) String() string {
+	pos := p.File
+	if p.Location != nil {
+		pos = fmt.Sprintf("%s:%d:%d", p.File, p.Location.Start.Line, p.Location.Start.Column)
+	}
+	return fmt.Sprintf("%s %s: %s", pos, p.FQN, p.Message)
+}
+
+// Report 是一次求值的结果。存在 Problem 时，Evaluate 会同时把它作为 error 返回。
+type Report struct {
+	// Results 按文件、定义和字段的顺序列出所有求值成功的常量和默认值。
+	Results  []Result  `json:"results"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// Error 列出所有问题。
+func (r *Report) Error() string {
+	if len(r.Problems) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("constant evaluation found %d problem(s):\n", len(r.Problems)))
+	for _, p := range r.Problems {
+		sb.WriteString(fmt.Sprintf(" - %s\n", p))
+	}
+	return sb.String()
+}
+
+// Lookup 返回 fqn 对应的求值结果。
+func (r *Report) Lookup(fqn string) (Result, bool) {
+	for _, res := range r.Results {
+		if res.FQN == fqn {
+			return res, true
+		}
+	}
+	return Result{}, false
+}
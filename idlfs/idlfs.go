@@ -0,0 +1,164 @@
+package idlfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReadThriftFiles 遍历 fsys 中 root 目录下的所有 .thrift 文件，返回以相对 root 的 slash 路径为键的文件内容，
+// 可以直接交给 thriftparser.NewParserFromMap。root 为 "." 时遍历整个 fsys。
+func ReadThriftFiles(fsys fs.FS, root string) (map[string][]byte, error) {
+	root = path.Clean(root)
+	files := make(map[string][]byte)
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".thrift") {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files[relativeTo(root, p)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk dir '%s' fail: %w", root, err)
+	}
+	return files, nil
+}
+
+// ReadRootedThriftFiles 与 ReadThriftFiles 相同，但把文件挂到逻辑根目录 "/<root>" 下：
+// 返回结果的键形如 "/<root>/<相对路径>"，可以交给要求绝对路径的 thriftanalyzer 和 thriftcheck。
+// includePaths 中的相对目录会被原地改写为逻辑根目录下的路径，返回值 logicalRoot 供调用方解析其他相对路径。
+func ReadRootedThriftFiles(fsys fs.FS, root string, includePaths []string) (files map[string][]byte, logicalRoot string, err error) {
+	relFiles, err := ReadThriftFiles(fsys, root)
+	if err != nil {
+		return nil, "", err
+	}
+	logicalRoot = filepath.Join("/", root)
+	files = make(map[string][]byte, len(relFiles))
+	for rel, content := range relFiles {
+		files[filepath.Join(logicalRoot, rel)] = content
+	}
+	for i, dir := range includePaths {
+		if !filepath.IsAbs(dir) {
+			includePaths[i] = filepath.Join(logicalRoot, dir)
+		}
+	}
+	return files, logicalRoot, nil
+}
+
+func relativeTo(root, p string) string {
+	if root == "." {
+		return p
+	}
+	return strings.TrimPrefix(p, root+"/")
+}
+
+// Overlay 返回一个以 files 覆盖 base 的只读文件系统：files 中的文件（键为 slash 分隔的相对路径）
+// 优先于 base 中的同名文件，目录的内容是两者的合并。base 为 nil 时只包含 files。
+// 适合在磁盘上的 IDL 之上叠加编辑器中尚未保存的修改，例如 Overlay(os.DirFS("idl"), edits)。
+func Overlay(base fs.FS, files map[string][]byte) fs.FS {
+	mem := make(memFS, len(files))
+	for name, content := range files {
+		mem[path.Clean(strings.TrimPrefix(name, "/"))] = content
+	}
+	if base == nil {
+		return mem
+	}
+	return &overlayFS{base: base, mem: mem}
+}
+
+// FromTarGz 把一个 .tar.gz 格式的 IDL 包读入内存，返回其中所有普通文件组成的文件系统。
+// .zip 包不需要转换：archive/zip.Reader 本身就实现了 fs.FS。
+func FromTarGz(r io.Reader) (fs.FS, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	mem := make(memFS)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar entry: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("invalid tar entry name %q", hdr.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read tar entry %s: %w", hdr.Name, err)
+		}
+		mem[name] = content
+	}
+	return mem, nil
+}
+
+// overlayFS 实现 Overlay。
+type overlayFS struct {
+	base fs.FS
+	mem  memFS
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, ok := o.mem[name]; ok {
+		return o.mem.Open(name)
+	}
+	if _, ok := o.mem.readDir(name); ok {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &memDir{info: dirInfo(name), entries: entries}, nil
+	}
+	return o.base.Open(name)
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	memEntries, memOK := o.mem.readDir(name)
+	baseEntries, err := fs.ReadDir(o.base, name)
+	if err != nil && !memOK {
+		return nil, err
+	}
+	merged := make(map[string]fs.DirEntry, len(baseEntries)+len(memEntries))
+	for _, e := range baseEntries {
+		merged[e.Name()] = e
+	}
+	for _, e := range memEntries {
+		merged[e.Name()] = e
+	}
+	return sortedEntries(merged), nil
+}
+
+func sortedEntries(m map[string]fs.DirEntry) []fs.DirEntry {
+	res := make([]fs.DirEntry, 0, len(m))
+	for _, e := range m {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res
+}
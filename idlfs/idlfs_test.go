@@ -0,0 +1,135 @@
+package idlfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadThriftFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"idl/main.thrift":        {Data: []byte("include \"common/base.thrift\"")},
+		"idl/common/base.thrift": {Data: []byte("struct Base {}")},
+		"idl/readme.md":          {Data: []byte("# idl")},
+		"other/x.thrift":         {Data: []byte("struct X {}")},
+	}
+
+	files, err := ReadThriftFiles(fsys, "idl")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"main.thrift":        []byte("include \"common/base.thrift\""),
+		"common/base.thrift": []byte("struct Base {}"),
+	}, files)
+
+	files, err = ReadThriftFiles(fsys, ".")
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+	assert.Contains(t, files, "other/x.thrift")
+
+	_, err = ReadThriftFiles(fsys, "missing")
+	assert.Error(t, err)
+}
+
+func TestReadRootedThriftFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"idl/main.thrift":        {Data: []byte("include \"base.thrift\"")},
+		"idl/common/base.thrift": {Data: []byte("struct Base {}")},
+	}
+
+	includePaths := []string{"common", "/abs"}
+	files, logicalRoot, err := ReadRootedThriftFiles(fsys, "idl", includePaths)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/idl"), logicalRoot)
+	assert.Equal(t, map[string][]byte{
+		filepath.FromSlash("/idl/main.thrift"):        []byte("include \"base.thrift\""),
+		filepath.FromSlash("/idl/common/base.thrift"): []byte("struct Base {}"),
+	}, files)
+	assert.Equal(t, []string{filepath.FromSlash("/idl/common"), "/abs"}, includePaths)
+
+	_, _, err = ReadRootedThriftFiles(fsys, "missing", nil)
+	assert.Error(t, err)
+}
+
+func TestOverlay(t *testing.T) {
+	base := fstest.MapFS{
+		"idl/main.thrift":        {Data: []byte("old")},
+		"idl/common/base.thrift": {Data: []byte("base")},
+	}
+	fsys := Overlay(base, map[string][]byte{
+		"idl/main.thrift":      []byte("new"),
+		"idl/extra/new.thrift": []byte("extra"),
+		"/idl/common/x.thrift": []byte("x"),
+	})
+	require.NoError(t, fstest.TestFS(fsys, "idl/main.thrift", "idl/common/base.thrift", "idl/common/x.thrift", "idl/extra/new.thrift"))
+
+	files, err := ReadThriftFiles(fsys, "idl")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"main.thrift":        []byte("new"),
+		"common/base.thrift": []byte("base"),
+		"common/x.thrift":    []byte("x"),
+		"extra/new.thrift":   []byte("extra"),
+	}, files)
+
+	memOnly := Overlay(nil, map[string][]byte{"a/b.thrift": []byte("b")})
+	require.NoError(t, fstest.TestFS(memOnly, "a/b.thrift"))
+	_, err = fs.Stat(memOnly, "missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestFromTarGz(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./idl/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	for name, content := range map[string]string{
+		"./idl/main.thrift":        "include \"common/base.thrift\"",
+		"./idl/common/base.thrift": "struct Base {}",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	fsys, err := FromTarGz(&buf)
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(fsys, "idl/main.thrift", "idl/common/base.thrift"))
+
+	files, err := ReadThriftFiles(fsys, "idl")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("struct Base {}"), files["common/base.thrift"])
+
+	_, err = FromTarGz(bytes.NewReader([]byte("not gzip")))
+	assert.Error(t, err)
+}
+
+func TestZipReader(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("idl/main.thrift")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("struct Main {}"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files, err := ReadThriftFiles(zr, "idl")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"main.thrift": []byte("struct Main {}")}, files)
+
+	// 磁盘目录同样可以通过 os.DirFS 读取。
+	_, err = ReadThriftFiles(os.DirFS("."), ".")
+	assert.NoError(t, err)
+}
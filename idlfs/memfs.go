@@ -0,0 +1,119 @@
+package idlfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// memFS 是只读的内存文件系统，键为 slash 分隔的相对路径，目录由文件路径隐式构成。
+type memFS map[string][]byte
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if content, ok := m[name]; ok {
+		return &memFile{info: fileInfo{name: path.Base(name), size: int64(len(content))}, Reader: bytes.NewReader(content)}, nil
+	}
+	entries, ok := m.readDir(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memDir{info: dirInfo(name), entries: entries}, nil
+}
+
+func (m memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, ok := m.readDir(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// readDir 列出目录 name 的直接子项。目录不存在（没有任何文件位于其下）时返回 false，根目录 "." 总是存在。
+func (m memFS) readDir(name string) ([]fs.DirEntry, bool) {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	found := name == "."
+	entries := make(map[string]fs.DirEntry)
+	for p, content := range m {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		found = true
+		rest := strings.TrimPrefix(p, prefix)
+		if child, _, isDir := strings.Cut(rest, "/"); isDir {
+			entries[child] = fs.FileInfoToDirEntry(dirInfo(child))
+		} else {
+			entries[child] = fs.FileInfoToDirEntry(fileInfo{name: child, size: int64(len(content))})
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	return sortedEntries(entries), true
+}
+
+type fileInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func dirInfo(name string) fileInfo {
+	return fileInfo{name: path.Base(name), isDir: true}
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.isDir }
+func (fi fileInfo) Sys() any           { return nil }
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.isDir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+type memFile struct {
+	info fileInfo
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,21 @@
+// Package idltest 提供各个包的测试共用的辅助函数。
+package idltest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/Skyenought/idlanalyzer/thriftparser"
+)
+
+// ParseThrift 把内存中的 Thrift 文件解析为 IDLSchema，键是相对于项目根目录的路径。解析失败时终止测试。
+func ParseThrift(t testing.TB, files map[string][]byte) *idl_ast.IDLSchema {
+	t.Helper()
+	p, err := thriftparser.NewParserFromMap("project", files)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	return schema
+}
@@ -0,0 +1,266 @@
+// Package thriftsrc 用 thrift-ls 解析 Thrift 源码，并修正 thrift-ls 的语法不接受、但 Apache Thrift 编译器接受的写法。
+// 修正只作用于交给 thrift-ls 的副本，返回的 AST 中的位置都指向原始源码。
+package thriftsrc
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joyme123/thrift-ls/parser"
+)
+
+const (
+	maxParseRetries = 5
+)
+
+var (
+	// 从错误信息中提取行号: `:(\d+):\d+`
+	lineFromErrorRegex = regexp.MustCompile(`:(\d+):\d+`)
+	// 查找并修复容器类型关键词，(?i)表示不区分大小写, \b是单词边界
+	containerTypeRegex = regexp.MustCompile(`\b(?i)(map|list|set)\b`)
+	// 匹配注解列表中的一项：名称、可选的 `= 字面量` 以及分隔符
+	annotationItemRegex = regexp.MustCompile(`^\s*([A-Za-z_][\w.]*)(\s*=\s*(?:"(?:\\.|[^"\\])*"|'(?:\\.|[^'\\])*'))?\s*(?:[,;]|$)`)
+)
+
+// bareAnnotationValue 是为没有值的注解补上的值。它只存在于交给 thrift-ls 的副本中，
+// 映射回原文后宽度为 0，见 IsBareAnnotation。
+const bareAnnotationValue = ` = ""`
+
+// insertion 记录一次修正在副本中插入的文本：at 是插入前文本中的偏移量，n 是插入的字节数。
+type insertion struct {
+	at, n int
+}
+
+// Parse 解析 content。thrift-ls 无法解析时，在 content 的副本上修正以下写法后重试：
+//   - 没有值的注解，例如 `(cpp.bare)`；
+//   - 大小写不规范的容器类型关键词，例如 `Map<string,string>`。
+//
+// 返回的 Document 中所有节点的位置（行、列和偏移量）都指向 content 本身，
+// 因此可以直接用它们截取 content。patched 是最终交给 thrift-ls 的文本，没有修正时就是 content。
+func Parse(filename string, content []byte) (doc *parser.Document, patched []byte, err error) {
+	currentContent := content
+	var lastErr error
+	// passes 按顺序记录每次插入文本的修正，用于把副本中的位置映射回 content。
+	var passes [][]insertion
+
+	for i := 0; i < maxParseRetries; i++ {
+		ast, parseErr := parser.Parse(filename, currentContent)
+		if parseErr == nil {
+			doc = ast.(*parser.Document)
+			if len(passes) > 0 {
+				remapPositions(doc, content, passes)
+			}
+			return doc, currentContent, nil
+		}
+
+		lastErr = parseErr
+		errorString := parseErr.Error()
+
+		// 修复策略：为没有值的注解补上值
+		if fixedContent, inserted := fixBareAnnotations(currentContent); len(inserted) > 0 {
+			currentContent = fixedContent
+			passes = append(passes, inserted)
+			continue
+		}
+
+		isFixableError := strings.Contains(errorString, "rule ErrStructField") || strings.Contains(errorString, "expecting")
+		if !isFixableError {
+			break
+		}
+		matches := lineFromErrorRegex.FindStringSubmatch(errorString)
+		if len(matches) < 2 {
+			break
+		}
+		lineNum, _ := strconv.Atoi(matches[1])
+
+		lines := strings.Split(string(currentContent), "\n")
+		if lineNum <= 0 || lineNum > len(lines) {
+			break
+		}
+
+		// 应用修复策略：将 Map/List/Set 纠正为小写。长度不变，位置无需映射。
+		problematicLine := lines[lineNum-1]
+		fixedLine := containerTypeRegex.ReplaceAllStringFunc(problematicLine, strings.ToLower)
+
+		if problematicLine == fixedLine {
+			break
+		}
+
+		lines[lineNum-1] = fixedLine
+		currentContent = []byte(strings.Join(lines, "\n"))
+	}
+
+	return nil, nil, fmt.Errorf("failed to parse after %d retries, last error: %w", maxParseRetries, lastErr)
+}
+
+// IsBareAnnotation 判断 a 在原始源码中是否没有值，例如 `(cpp.bare)`。
+// Parse 为这种注解补上的 `=` 在原文中不占任何位置。
+func IsBareAnnotation(a *parser.Annotation) bool {
+	if a == nil || a.EqualKeyword == nil {
+		return false
+	}
+	return a.EqualKeyword.Pos().Offset == a.EqualKeyword.End().Offset
+}
+
+// fixBareAnnotations 为没有值的注解补上 bareAnnotationValue，返回修正后的文本和插入记录。
+// 注释和字符串中的括号会被跳过，只有整个括号内都是注解列表时才会改写，因此参数列表和 throws 不受影响。
+func fixBareAnnotations(content []byte) ([]byte, []insertion) {
+	src := string(content)
+	var sb strings.Builder
+	var inserted []insertion
+	last := 0
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '"' || src[i] == '\'':
+			quote := src[i]
+			for i++; i < len(src) && src[i] != quote; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case src[i] == '#' || strings.HasPrefix(src[i:], "//"):
+			if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(src)
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			if end := strings.Index(src[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(src)
+			}
+		case src[i] == '(':
+			end := strings.IndexAny(src[i+1:], "()")
+			if end < 0 || src[i+1+end] != ')' {
+				continue
+			}
+			offsets := bareAnnotationOffsets(src[i+1 : i+1+end])
+			for _, off := range offsets {
+				at := i + 1 + off
+				sb.WriteString(src[last:at])
+				sb.WriteString(bareAnnotationValue)
+				inserted = append(inserted, insertion{at: at, n: len(bareAnnotationValue)})
+				last = at
+			}
+			if len(offsets) > 0 {
+				i += end + 1
+			}
+		}
+	}
+	if len(inserted) == 0 {
+		return content, nil
+	}
+	sb.WriteString(src[last:])
+	return []byte(sb.String()), inserted
+}
+
+// bareAnnotationOffsets 在 group 是注解列表时，返回其中没有值的注解名称的结束位置。
+func bareAnnotationOffsets(group string) []int {
+	var offsets []int
+	for pos := 0; strings.TrimSpace(group[pos:]) != ""; {
+		m := annotationItemRegex.FindStringSubmatchIndex(group[pos:])
+		if m == nil {
+			return nil
+		}
+		if m[4] < 0 {
+			offsets = append(offsets, pos+m[3])
+		}
+		pos += m[1]
+	}
+	return offsets
+}
+
+// mapOffset 把一次修正之后的偏移量映射回修正之前的文本。落在插入文本内部的偏移量映射到插入点。
+func mapOffset(inserted []insertion, off int) int {
+	shift := 0
+	for _, in := range inserted {
+		start := in.at + shift
+		if off <= start {
+			break
+		}
+		if off < start+in.n {
+			return in.at
+		}
+		shift += in.n
+	}
+	return off - shift
+}
+
+var positionType = reflect.TypeOf(parser.Position{})
+
+// remapPositions 把 doc 中所有的位置从修正后的副本映射回 original，并按 original 重新计算行和列。
+// Document 的 Nodes 与各类定义列表共享节点，每个节点只映射一次。
+func remapPositions(doc *parser.Document, original []byte, passes [][]insertion) {
+	var lineStarts []int
+	lineStarts = append(lineStarts, 0)
+	for i, b := range original {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	remap := func(pos *parser.Position) {
+		if pos.Offset < 0 {
+			return
+		}
+		off := pos.Offset
+		for i := len(passes) - 1; i >= 0; i-- {
+			off = mapOffset(passes[i], off)
+		}
+		if off > len(original) {
+			off = len(original)
+		}
+		line := sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > off }) - 1
+		pos.Offset = off
+		pos.Line = line + 1
+		pos.Col = utf8.RuneCount(original[lineStarts[line]:off]) + 1
+	}
+
+	// 指向结构体和指向它第一个字段的指针地址相同，因此同时用类型区分。
+	type visitKey struct {
+		t reflect.Type
+		p uintptr
+	}
+	visited := make(map[visitKey]bool)
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Pointer:
+			if v.IsNil() {
+				return
+			}
+			key := visitKey{v.Type(), v.Pointer()}
+			if visited[key] {
+				return
+			}
+			visited[key] = true
+			walk(v.Elem())
+		case reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		case reflect.Struct:
+			if v.Type() == positionType {
+				if v.CanAddr() {
+					remap(v.Addr().Interface().(*parser.Position))
+				}
+				return
+			}
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					walk(v.Field(i))
+				}
+			}
+		}
+	}
+	walk(reflect.ValueOf(doc))
+}
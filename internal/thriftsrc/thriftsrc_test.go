@@ -0,0 +1,68 @@
+package thriftsrc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_KeepsOriginalPositions(t *testing.T) {
+	source := `struct A {
+  1: string a (api.none)
+  2: Map<string,string> m (bare, go.tag = "m")
+}
+
+// 中文注释
+struct C {
+  1: string c (x.y)
+}
+`
+	doc, patched, err := Parse("/a.thrift", []byte(source))
+	require.NoError(t, err)
+	assert.NotEqual(t, source, string(patched))
+
+	require.Len(t, doc.Structs, 2)
+	a, c := doc.Structs[0], doc.Structs[1]
+	name := c.Identifier.Name.Pos()
+	assert.Equal(t, strings.Index(source, "C {"), name.Offset)
+	assert.Equal(t, 7, name.Line)
+	assert.Equal(t, 8, name.Col)
+	assert.Equal(t, strings.Index(source, "}\n\n")+1, a.RCurKeyword.End().Offset)
+
+	annos := a.Fields[1].Annotations.Annotations
+	require.Len(t, annos, 2)
+	assert.True(t, IsBareAnnotation(a.Fields[0].Annotations.Annotations[0]))
+	assert.True(t, IsBareAnnotation(annos[0]))
+	assert.False(t, IsBareAnnotation(annos[1]))
+	assert.Equal(t, `"m"`, source[annos[1].Value.Pos().Offset:annos[1].Value.Pos().Offset+3])
+	assert.True(t, IsBareAnnotation(c.Fields[0].Annotations.Annotations[0]))
+
+	// 行和列按原文重新计算，列按 rune 计数。
+	fieldName := c.Fields[0].Identifier.Name.Pos()
+	assert.Equal(t, 8, fieldName.Line)
+	assert.Equal(t, 13, fieldName.Col)
+	comment := c.Comments[0].Pos()
+	assert.Equal(t, 6, comment.Line)
+	assert.Equal(t, "// 中文注释", source[comment.Offset:c.Comments[0].End().Offset])
+}
+
+func TestParse_Unchanged(t *testing.T) {
+	source := []byte(`struct A { 1: string a (api.doc = "") }`)
+	doc, patched, err := Parse("/a.thrift", source)
+	require.NoError(t, err)
+	assert.Equal(t, source, patched)
+	assert.False(t, IsBareAnnotation(doc.Structs[0].Fields[0].Annotations.Annotations[0]))
+
+	_, _, err = Parse("/b.thrift", []byte(`struct {`))
+	assert.Error(t, err)
+}
+
+func TestMapOffset(t *testing.T) {
+	inserted := []insertion{{at: 2, n: 3}, {at: 5, n: 3}}
+	// 原文 "abcdefg" 修正为 "ab+++cde+++fg"
+	for patched, want := range map[int]int{0: 0, 2: 2, 3: 2, 5: 2, 6: 3, 8: 5, 9: 5, 11: 5, 12: 6} {
+		assert.Equal(t, want, mapOffset(inserted, patched), "offset %d", patched)
+	}
+}
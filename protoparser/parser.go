@@ -0,0 +1,153 @@
+package protoparser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+type Options struct {
+	NoLocation bool
+	NoComments bool
+}
+
+type Option func(*Options)
+
+func WithNoLocation(noLocation bool) Option {
+	return func(o *Options) {
+		o.NoLocation = noLocation
+	}
+}
+
+func WithNoComments(noComments bool) Option {
+	return func(o *Options) {
+		o.NoComments = noComments
+	}
+}
+
+type ProtoParser struct {
+	rootDir string
+	opts    *Options
+	sources map[string][]byte
+	protos  []*descriptorpb.FileDescriptorProto
+	schema  *idl_ast.IDLSchema
+}
+
+// NewParser 从 rootDir 目录中发现并解析所有 .proto 文件。
+// import 语句中的路径被视为相对于 rootDir 的路径。
+func NewParser(rootDir string, opts ...Option) (pp *ProtoParser, err error) {
+	if !filepath.IsAbs(rootDir) {
+		rootDir, err = filepath.Abs(rootDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fileMap := make(map[string][]byte)
+	err = filepath.WalkDir(rootDir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".proto") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		fileMap[relativePath] = content
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk dir '%s' fail: %w", rootDir, err)
+	}
+
+	return newParser(rootDir, fileMap, opts...)
+}
+
+// NewParserFromMap 从内存中的文件 map 解析 .proto 文件，map 的键是相对于 rootDir 的路径。
+func NewParserFromMap(rootDir string, fileMap map[string][]byte, opts ...Option) (*ProtoParser, error) {
+	if !filepath.IsAbs(rootDir) {
+		rootDir = "/" + rootDir
+	}
+	rootDir = filepath.Clean(rootDir)
+	return newParser(rootDir, fileMap, opts...)
+}
+
+func newParser(rootDir string, fileMap map[string][]byte, opts ...Option) (*ProtoParser, error) {
+	defaultOptions := &Options{
+		NoLocation: false,
+		NoComments: false,
+	}
+	for _, opt := range opts {
+		opt(defaultOptions)
+	}
+
+	p := &ProtoParser{
+		rootDir: rootDir,
+		opts:    defaultOptions,
+		sources: make(map[string][]byte),
+	}
+
+	contents := make(map[string]string)
+	var names []string
+	for relativePath, content := range fileMap {
+		if !strings.HasSuffix(relativePath, ".proto") {
+			continue
+		}
+		name := filepath.ToSlash(filepath.Clean(relativePath))
+		p.sources[name] = content
+		contents[name] = string(content)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// 只解析而不链接：链接需要所有 import（包括 google/api 这类第三方文件）都存在，
+	// 而且会把自定义选项解释掉。类型引用在 transform 阶段按 protobuf 的作用域规则自行解析。
+	protoParser := protoparse.Parser{
+		Accessor:              protoparse.FileContentsFromMap(contents),
+		IncludeSourceCodeInfo: true,
+	}
+	protos, err := protoParser.ParseFilesButDoNotLink(names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proto files: %w", err)
+	}
+	p.protos = protos
+
+	return p, nil
+}
+
+func (p *ProtoParser) ParseIDLs() (*idl_ast.IDLSchema, error) {
+	if p.schema != nil {
+		return p.schema, nil
+	}
+
+	schema := &idl_ast.IDLSchema{
+		SchemaVersion: "1.0",
+		IDLType:       "protobuf",
+		Files:         make([]idl_ast.File, 0, len(p.protos)),
+	}
+
+	symbols := buildSymbolTable(p.protos)
+	for _, fd := range p.protos {
+		idlFile, err := transform(fd, p.sources[fd.GetName()], symbols, p.opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transform ast for %s: %w", fd.GetName(), err)
+		}
+		schema.Files = append(schema.Files, *idlFile)
+	}
+
+	p.schema = schema
+	return p.schema, nil
+}
@@ -0,0 +1,223 @@
+package protoparser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+var testFiles = map[string][]byte{
+	"common/base.proto": []byte(`syntax = "proto3";
+
+package example.common;
+
+// Status 表示处理结果
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_OK = 1;
+}
+
+message Entity {
+  string id = 1;
+}
+`),
+	"user/user.proto": []byte(`syntax = "proto3";
+
+package example.user;
+
+import "common/base.proto";
+import "google/api/annotations.proto";
+
+option go_package = "example.com/gen/user";
+
+// User 是用户信息
+message User {
+  string name = 1 [(api.query) = "name", deprecated = true]; // 用户名
+  example.common.Status status = 2;
+  repeated Address addresses = 3;
+  map<string, common.Entity> entities = 4;
+  oneof contact {
+    string email = 5;
+    string phone = 6;
+  }
+
+  message Address {
+    string city = 1;
+    Kind kind = 2;
+
+    enum Kind {
+      KIND_UNSPECIFIED = 0;
+      KIND_HOME = 1;
+    }
+  }
+}
+
+message GetUserRequest {
+  string name = 1;
+}
+
+service UserService {
+  // GetUser 查询用户
+  rpc GetUser(GetUserRequest) returns (User) {
+    option (google.api.http) = {
+      get: "/v1/users/{name}"
+    };
+  }
+  rpc Watch(GetUserRequest) returns (stream User);
+}
+`),
+}
+
+func TestProtoParser_ParseIDLs(t *testing.T) {
+	p, err := NewParserFromMap("project", testFiles)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	assert.Equal(t, "protobuf", schema.IDLType)
+	require.Len(t, schema.Files, 2)
+
+	base := schema.Files[0]
+	assert.Equal(t, "common/base.proto", base.Path)
+	assert.Equal(t, "proto3", base.Syntax)
+	assert.Equal(t, []idl_ast.Namespace{{Scope: "*", Name: "example.common", Location: base.Namespaces[0].Location}}, base.Namespaces)
+
+	status := schema.FindEnumsByFQN("common/base.proto#Status")
+	require.Len(t, status, 1)
+	assert.Len(t, status[0].Values, 2)
+	require.Len(t, status[0].Comments, 1)
+	assert.Equal(t, "// Status 表示处理结果", status[0].Comments[0].Text)
+
+	user := schema.Files[1]
+	require.Len(t, user.Imports, 2)
+	assert.Equal(t, `"common/base.proto"`, user.Imports[0].Value)
+	assert.Equal(t, "common/base.proto", user.Imports[0].Path)
+	require.Len(t, user.Options, 1)
+	assert.Equal(t, "go_package", user.Options[0].Name)
+	assert.Equal(t, `"example.com/gen/user"`, user.Options[0].Value.Value)
+
+	users := schema.FindStructsByFQN("user/user.proto#User")
+	require.Len(t, users, 1)
+	u := users[0]
+	assert.Equal(t, "User", u.Name)
+	assert.Contains(t, u.Content, "message User {")
+	require.NotNil(t, u.Location)
+	assert.Equal(t, 11, u.Location.Start.Line)
+	require.Len(t, u.Fields, 6)
+
+	name := u.Fields[0]
+	assert.Equal(t, "string", name.Type.Name)
+	assert.True(t, name.Type.IsPrimitive)
+	assert.Equal(t, []idl_ast.Annotation{
+		{Name: "(api.query)", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"name"`}},
+		{Name: "deprecated", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantBool, Value: true}},
+	}, name.Annotations)
+	require.Len(t, name.Comments, 1)
+	assert.Equal(t, "// 用户名", name.Comments[0].Text)
+
+	// 跨文件的类型引用，包括相对 package 的写法
+	assert.Equal(t, "common/base.proto#Status", u.Fields[1].Type.FullyQualifiedName)
+
+	addresses := u.Fields[2].Type
+	assert.Equal(t, "list", addresses.Name)
+	require.NotNil(t, addresses.ValueType)
+	assert.Equal(t, "user/user.proto#User.Address", addresses.ValueType.FullyQualifiedName)
+
+	entities := u.Fields[3].Type
+	assert.Equal(t, "map", entities.Name)
+	assert.Equal(t, "string", entities.KeyType.Name)
+	assert.Equal(t, "common/base.proto#Entity", entities.ValueType.FullyQualifiedName)
+
+	assert.Equal(t, []idl_ast.Annotation{{Name: "oneof", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"contact"`}}}, u.Fields[4].Annotations)
+
+	// 嵌套类型被展开为 Outer.Inner
+	address := schema.FindStructsByFQN("user/user.proto#User.Address")
+	require.Len(t, address, 1)
+	assert.Equal(t, "user/user.proto#User.Address.Kind", address[0].Fields[1].Type.FullyQualifiedName)
+	assert.Len(t, schema.FindEnumsByFQN("user/user.proto#User.Address.Kind"), 1)
+
+	getUser := schema.FindFunctionsByFQN("user/user.proto#UserService.GetUser")
+	require.Len(t, getUser, 1)
+	fn := getUser[0]
+	assert.Equal(t, "rpc GetUser(GetUserRequest) returns (User)", fn.Signature)
+	assert.Equal(t, "user/user.proto#User", fn.ReturnType.FullyQualifiedName)
+	require.Len(t, fn.Parameters, 1)
+	assert.Equal(t, "user/user.proto#GetUserRequest", fn.Parameters[0].Type.FullyQualifiedName)
+	require.Len(t, fn.Annotations, 1)
+	assert.Equal(t, "(google.api.http)", fn.Annotations[0].Name)
+	assert.Equal(t, []*idl_ast.ConstantMapEntry{{
+		Key:   &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "get"},
+		Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"/v1/users/{name}"`},
+	}}, fn.Annotations[0].Value.Value)
+
+	watch := schema.FindFunctionsByFQN("user/user.proto#UserService.Watch")
+	require.Len(t, watch, 1)
+	assert.Equal(t, "server_streaming", watch[0].Annotations[0].Name)
+}
+
+func TestNewParser(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range testFiles {
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, content, 0o644))
+	}
+
+	p, err := NewParser(dir, WithNoLocation(true), WithNoComments(true))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	require.Len(t, schema.Files, 2)
+
+	idl_ast.Inspect(schema, func(node any) bool {
+		switch n := node.(type) {
+		case *idl_ast.Message:
+			assert.Nil(t, n.Location)
+			assert.Empty(t, n.Comments)
+		case *idl_ast.Field:
+			assert.Nil(t, n.Location)
+		}
+		return true
+	})
+}
+
+func TestNewParserFromMap_SyntaxError(t *testing.T) {
+	_, err := NewParserFromMap("project", map[string][]byte{
+		"bad.proto": []byte(`syntax = "proto3"; message {`),
+	})
+	assert.Error(t, err)
+}
+
+func TestScalarValue(t *testing.T) {
+	tests := []struct {
+		token string
+		want  *idl_ast.ConstantValue
+	}{
+		{`"a"`, &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"a"`}},
+		{"true", &idl_ast.ConstantValue{Kind: idl_ast.ConstantBool, Value: true}},
+		{"42", &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: int64(42)}},
+		{"-7", &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: int64(-7)}},
+		{"0x1F", &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: int64(31)}},
+		{"017", &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: int64(15)}},
+		{"1.5", &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: 1.5}},
+		{".5e1", &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: 5.0}},
+		{"2f", &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: 2.0}},
+		{"1e3", &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: 1000.0}},
+		// 枚举值和 Go 特有的数字写法都是标识符。
+		{"NAN", &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "NAN"}},
+		{"INF", &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "INF"}},
+		{"Infinity", &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "Infinity"}},
+		{"1_000", &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "1_000"}},
+		{"0b101", &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "0b101"}},
+		{"0x1p4", &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "0x1p4"}},
+		{"2ff", &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: "2ff"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, scalarValue(tt.token), tt.token)
+	}
+}
@@ -0,0 +1,420 @@
+package protoparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+// descriptor.proto 中各字段的编号，用于在 SourceCodeInfo 中定位元素。
+const (
+	filePackageTag    = 2
+	fileDependencyTag = 3
+	fileMessageTag    = 4
+	fileEnumTag       = 5
+	fileServiceTag    = 6
+	fileOptionsTag    = 8
+
+	messageFieldTag   = 2
+	messageNestedTag  = 3
+	messageEnumTag    = 4
+	messageOptionsTag = 7
+
+	fieldTypeTag     = 5
+	fieldTypeNameTag = 6
+	fieldOptionsTag  = 8
+
+	enumValueTag        = 2
+	enumOptionsTag      = 3
+	enumValueOptionsTag = 3
+
+	serviceMethodTag  = 2
+	serviceOptionsTag = 3
+	methodOptionsTag  = 4
+)
+
+// symbol 是一个 message 或 enum 定义在整个项目中的位置。
+type symbol struct {
+	file string
+	// name 是去掉 package 之后的名称，嵌套类型用 "." 连接，例如 "Outer.Inner"。
+	name string
+	// mapEntry 不为空时，symbol 是编译器为 map 字段生成的 XxxEntry 消息。
+	mapEntry *descriptorpb.DescriptorProto
+}
+
+// symbolTable 以 protobuf 全名（例如 "foo.bar.Outer.Inner"）为键。
+type symbolTable map[string]*symbol
+
+func buildSymbolTable(protos []*descriptorpb.FileDescriptorProto) symbolTable {
+	st := make(symbolTable)
+	for _, fd := range protos {
+		var addMessage func(scope, parent string, msg *descriptorpb.DescriptorProto)
+		addMessage = func(scope, parent string, msg *descriptorpb.DescriptorProto) {
+			full, name := joinName(scope, msg.GetName()), joinName(parent, msg.GetName())
+			sym := &symbol{file: fd.GetName(), name: name}
+			if msg.GetOptions().GetMapEntry() {
+				sym.mapEntry = msg
+			}
+			st[full] = sym
+			for _, nested := range msg.GetNestedType() {
+				addMessage(full, name, nested)
+			}
+			for _, enum := range msg.GetEnumType() {
+				st[joinName(full, enum.GetName())] = &symbol{file: fd.GetName(), name: joinName(name, enum.GetName())}
+			}
+		}
+		for _, msg := range fd.GetMessageType() {
+			addMessage(fd.GetPackage(), "", msg)
+		}
+		for _, enum := range fd.GetEnumType() {
+			st[joinName(fd.GetPackage(), enum.GetName())] = &symbol{file: fd.GetName(), name: enum.GetName()}
+		}
+	}
+	return st
+}
+
+// resolve 按照 protobuf 的作用域规则，从 scope 开始由内向外查找 name。
+// 以 "." 开头的 name 是完整的全名。
+func (st symbolTable) resolve(scope, name string) (*symbol, bool) {
+	if strings.HasPrefix(name, ".") {
+		sym, ok := st[name[1:]]
+		return sym, ok
+	}
+	for {
+		if sym, ok := st[joinName(scope, name)]; ok {
+			return sym, true
+		}
+		if scope == "" {
+			return nil, false
+		}
+		if i := strings.LastIndex(scope, "."); i != -1 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+func joinName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// transformContext 用于在转换函数之间传递共享状态和信息
+type transformContext struct {
+	fd         *descriptorpb.FileDescriptorProto
+	source     []byte
+	lines      []int
+	symbols    symbolTable
+	opts       *Options
+	sourceInfo map[string]*descriptorpb.SourceCodeInfo_Location
+	messages   []idl_ast.Message
+	enums      []idl_ast.Enum
+}
+
+// transform 是主转换函数，将一个未链接的 FileDescriptorProto 转换为我们的 idl_ast.File
+func transform(fd *descriptorpb.FileDescriptorProto, source []byte, symbols symbolTable, opts *Options) (*idl_ast.File, error) {
+	ctx := &transformContext{
+		fd:         fd,
+		source:     source,
+		lines:      lineOffsets(source),
+		symbols:    symbols,
+		opts:       opts,
+		sourceInfo: make(map[string]*descriptorpb.SourceCodeInfo_Location),
+	}
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		key := pathKey(loc.GetPath())
+		// 同一路径可能出现多次（例如多条 option 语句），保留第一次出现的位置。
+		if _, ok := ctx.sourceInfo[key]; !ok {
+			ctx.sourceInfo[key] = loc
+		}
+	}
+
+	syntax := fd.GetSyntax()
+	if syntax == "" {
+		syntax = "proto2"
+	}
+
+	for i, msg := range fd.GetMessageType() {
+		transformMessage(ctx, msg, fd.GetPackage(), "", []int32{fileMessageTag, int32(i)})
+	}
+	for i, enum := range fd.GetEnumType() {
+		transformEnum(ctx, enum, "", []int32{fileEnumTag, int32(i)})
+	}
+
+	idlFile := &idl_ast.File{
+		Path:       fd.GetName(),
+		Location:   ctx.fileLocation(),
+		Content:    string(source),
+		Imports:    transformImports(ctx),
+		Syntax:     syntax,
+		Namespaces: transformNamespaces(ctx),
+		Definitions: idl_ast.Definitions{
+			Services: transformServices(ctx),
+			Messages: ctx.messages,
+			Enums:    ctx.enums,
+		},
+		Options: transformOptions(fd.GetOptions().GetUninterpretedOption()),
+	}
+	idlFile.Definitions.OrderByLocation()
+	if err := idlFile.UpdateChecksums(); err != nil {
+		return nil, err
+	}
+	return idlFile, nil
+}
+
+func transformImports(ctx *transformContext) []idl_ast.Import {
+	deps := ctx.fd.GetDependency()
+	if len(deps) == 0 {
+		return nil
+	}
+	res := make([]idl_ast.Import, len(deps))
+	for i, dep := range deps {
+		path := []int32{fileDependencyTag, int32(i)}
+		res[i] = idl_ast.Import{
+			Comments: ctx.comments(path),
+			Location: ctx.location(path),
+			Value:    strconv.Quote(dep),
+			Path:     dep,
+		}
+	}
+	return res
+}
+
+// transformNamespaces 把 package 声明转换为 scope 为 "*" 的 namespace，
+// 与 Thrift 中对所有语言生效的 `namespace * xxx` 含义一致。
+func transformNamespaces(ctx *transformContext) []idl_ast.Namespace {
+	if ctx.fd.GetPackage() == "" {
+		return nil
+	}
+	path := []int32{filePackageTag}
+	return []idl_ast.Namespace{{
+		Comments: ctx.comments(path),
+		Location: ctx.location(path),
+		Scope:    "*",
+		Name:     ctx.fd.GetPackage(),
+	}}
+}
+
+// transformMessage 转换一个 message 及其嵌套的 message 和 enum。
+// 嵌套类型被展开为顶层定义，名称使用 "Outer.Inner" 的形式。
+func transformMessage(ctx *transformContext, msg *descriptorpb.DescriptorProto, scope, parent string, path []int32) {
+	if msg.GetOptions().GetMapEntry() {
+		return
+	}
+	full, name := joinName(scope, msg.GetName()), joinName(parent, msg.GetName())
+
+	fields := make([]idl_ast.Field, len(msg.GetField()))
+	for i, f := range msg.GetField() {
+		fields[i] = transformField(ctx, f, msg, full, appendPath(path, messageFieldTag, int32(i)))
+	}
+
+	ctx.messages = append(ctx.messages, idl_ast.Message{
+		Comments:           ctx.comments(path),
+		Location:           ctx.location(path),
+		Content:            ctx.content(path),
+		Name:               name,
+		FullyQualifiedName: fmt.Sprintf("%s#%s", ctx.fd.GetName(), name),
+		Type:               "struct",
+		Fields:             fields,
+		Annotations:        transformOptions(msg.GetOptions().GetUninterpretedOption()),
+	})
+
+	for i, nested := range msg.GetNestedType() {
+		transformMessage(ctx, nested, full, name, appendPath(path, messageNestedTag, int32(i)))
+	}
+	for i, enum := range msg.GetEnumType() {
+		transformEnum(ctx, enum, name, appendPath(path, messageEnumTag, int32(i)))
+	}
+}
+
+func transformField(ctx *transformContext, f *descriptorpb.FieldDescriptorProto, msg *descriptorpb.DescriptorProto, scope string, path []int32) idl_ast.Field {
+	required := "optional"
+	if f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+		required = "required"
+	}
+
+	annotations := transformOptions(f.GetOptions().GetUninterpretedOption())
+	if f.OneofIndex != nil && !f.GetProto3Optional() && int(f.GetOneofIndex()) < len(msg.GetOneofDecl()) {
+		annotations = append(annotations, idl_ast.Annotation{
+			Name:  "oneof",
+			Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: strconv.Quote(msg.GetOneofDecl()[f.GetOneofIndex()].GetName())},
+		})
+	}
+
+	return idl_ast.Field{
+		Comments:     ctx.comments(path),
+		Location:     ctx.location(path),
+		ID:           int(f.GetNumber()),
+		Name:         f.GetName(),
+		Type:         transformFieldType(ctx, f, scope, path),
+		Required:     required,
+		DefaultValue: transformDefaultValue(f),
+		Annotations:  annotations,
+	}
+}
+
+// transformFieldType 转换字段的类型。repeated 字段变为 list，map 字段变为 map。
+func transformFieldType(ctx *transformContext, f *descriptorpb.FieldDescriptorProto, scope string, path []int32) idl_ast.Type {
+	loc := ctx.location(appendPath(path, fieldTypeNameTag))
+	if loc == nil {
+		loc = ctx.location(appendPath(path, fieldTypeTag))
+	}
+
+	if f.GetTypeName() != "" {
+		if sym, ok := ctx.symbols.resolve(scope, f.GetTypeName()); ok && sym.mapEntry != nil && len(sym.mapEntry.GetField()) == 2 {
+			entryScope := joinName(scope, sym.mapEntry.GetName())
+			keyType := elementType(ctx, sym.mapEntry.GetField()[0], entryScope)
+			valueType := elementType(ctx, sym.mapEntry.GetField()[1], entryScope)
+			return idl_ast.Type{Location: loc, Name: "map", KeyType: &keyType, ValueType: &valueType}
+		}
+	}
+
+	t := elementType(ctx, f, scope)
+	t.Location = loc
+	if f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		elem := t
+		elem.Location = nil
+		return idl_ast.Type{Location: loc, Name: "list", ValueType: &elem}
+	}
+	return t
+}
+
+// elementType 转换不考虑 repeated 的单个类型。
+func elementType(ctx *transformContext, f *descriptorpb.FieldDescriptorProto, scope string) idl_ast.Type {
+	if f.GetTypeName() == "" {
+		return idl_ast.Type{Name: scalarTypeName(f.GetType()), IsPrimitive: true}
+	}
+	return namedType(ctx, f.GetTypeName(), scope)
+}
+
+func namedType(ctx *transformContext, typeName, scope string) idl_ast.Type {
+	t := idl_ast.Type{Name: strings.TrimPrefix(typeName, ".")}
+	if sym, ok := ctx.symbols.resolve(scope, typeName); ok {
+		t.FullyQualifiedName = fmt.Sprintf("%s#%s", sym.file, sym.name)
+	}
+	return t
+}
+
+func scalarTypeName(t descriptorpb.FieldDescriptorProto_Type) string {
+	return strings.ToLower(strings.TrimPrefix(t.String(), "TYPE_"))
+}
+
+// transformDefaultValue 转换 proto2 的 [default = ...] 伪选项。
+func transformDefaultValue(f *descriptorpb.FieldDescriptorProto) *idl_ast.ConstantValue {
+	if f.DefaultValue == nil {
+		return nil
+	}
+	raw := f.GetDefaultValue()
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: strconv.Quote(raw)}
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantBool, Value: raw == "true"}
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		v, _ := strconv.ParseFloat(raw, 64)
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: v}
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: raw}
+	default:
+		v, _ := strconv.ParseInt(raw, 10, 64)
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: v}
+	}
+}
+
+func transformEnum(ctx *transformContext, enum *descriptorpb.EnumDescriptorProto, parent string, path []int32) {
+	name := joinName(parent, enum.GetName())
+	values := make([]idl_ast.EnumValue, len(enum.GetValue()))
+	for i, v := range enum.GetValue() {
+		valuePath := appendPath(path, enumValueTag, int32(i))
+		values[i] = idl_ast.EnumValue{
+			Comments:    ctx.comments(valuePath),
+			Location:    ctx.location(valuePath),
+			Name:        v.GetName(),
+			Value:       int(v.GetNumber()),
+			Annotations: transformOptions(v.GetOptions().GetUninterpretedOption()),
+		}
+	}
+	ctx.enums = append(ctx.enums, idl_ast.Enum{
+		Comments:           ctx.comments(path),
+		Location:           ctx.location(path),
+		Content:            ctx.content(path),
+		Name:               name,
+		FullyQualifiedName: fmt.Sprintf("%s#%s", ctx.fd.GetName(), name),
+		Values:             values,
+		Annotations:        transformOptions(enum.GetOptions().GetUninterpretedOption()),
+	})
+}
+
+func transformServices(ctx *transformContext) []idl_ast.Service {
+	services := ctx.fd.GetService()
+	res := make([]idl_ast.Service, len(services))
+	for i, s := range services {
+		path := []int32{fileServiceTag, int32(i)}
+		name := s.GetName()
+		res[i] = idl_ast.Service{
+			Comments:           ctx.comments(path),
+			Location:           ctx.location(path),
+			Content:            ctx.content(path),
+			Name:               name,
+			FullyQualifiedName: fmt.Sprintf("%s#%s", ctx.fd.GetName(), name),
+			Functions:          transformMethods(ctx, s, path),
+			Annotations:        transformOptions(s.GetOptions().GetUninterpretedOption()),
+		}
+	}
+	return res
+}
+
+// transformMethods 把 rpc 方法转换为 Function：请求消息成为 ID 为 1、名为 request 的参数，
+// 流式方法额外带有 client_streaming / server_streaming 注解。
+func transformMethods(ctx *transformContext, s *descriptorpb.ServiceDescriptorProto, servicePath []int32) []idl_ast.Function {
+	scope := joinName(ctx.fd.GetPackage(), s.GetName())
+	res := make([]idl_ast.Function, len(s.GetMethod()))
+	for i, m := range s.GetMethod() {
+		path := appendPath(servicePath, serviceMethodTag, int32(i))
+		annotations := transformOptions(m.GetOptions().GetUninterpretedOption())
+		if m.GetClientStreaming() {
+			annotations = append(annotations, idl_ast.Annotation{Name: "client_streaming", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantBool, Value: true}})
+		}
+		if m.GetServerStreaming() {
+			annotations = append(annotations, idl_ast.Annotation{Name: "server_streaming", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantBool, Value: true}})
+		}
+
+		res[i] = idl_ast.Function{
+			Comments:           ctx.comments(path),
+			Location:           ctx.location(path),
+			Signature:          signature(ctx.content(path)),
+			Name:               m.GetName(),
+			FullyQualifiedName: fmt.Sprintf("%s#%s.%s", ctx.fd.GetName(), s.GetName(), m.GetName()),
+			ReturnType:         namedType(ctx, m.GetOutputType(), scope),
+			Parameters: []idl_ast.Field{{
+				ID:       1,
+				Name:     "request",
+				Type:     namedType(ctx, m.GetInputType(), scope),
+				Required: "optional",
+			}},
+			Annotations: annotations,
+		}
+	}
+	return res
+}
+
+// signature 返回 rpc 声明中选项块之前的部分，例如 "rpc Get(GetRequest) returns (GetResponse)"。
+func signature(content string) string {
+	if i := strings.IndexAny(content, "{;"); i != -1 {
+		content = content[:i]
+	}
+	return strings.TrimSpace(content)
+}
+
+func appendPath(path []int32, elems ...int32) []int32 {
+	res := make([]int32, 0, len(path)+len(elems))
+	res = append(res, path...)
+	return append(res, elems...)
+}
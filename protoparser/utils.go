@@ -0,0 +1,319 @@
+package protoparser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+func pathKey(path []int32) string {
+	var sb strings.Builder
+	for i, p := range path {
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(strconv.Itoa(int(p)))
+	}
+	return sb.String()
+}
+
+// lineOffsets 返回每一行起始位置的字节偏移。
+func lineOffsets(source []byte) []int {
+	offsets := []int{0}
+	for i, c := range source {
+		if c == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// offsetOf 把 SourceCodeInfo 中从 0 开始的行号和列号转换为字节偏移。
+// 与 protocompile 一致，列号按 8 列对齐展开制表符，多字节字符只算一列。
+func (ctx *transformContext) offsetOf(line, col int) int {
+	if line < 0 || line >= len(ctx.lines) {
+		return len(ctx.source)
+	}
+	offset, c := ctx.lines[line], 0
+	for offset < len(ctx.source) && c < col && ctx.source[offset] != '\n' {
+		if ctx.source[offset] == '\t' {
+			c += 8 - c%8
+			offset++
+			continue
+		}
+		_, size := utf8.DecodeRune(ctx.source[offset:])
+		offset += size
+		c++
+	}
+	return offset
+}
+
+// span 返回 path 对应元素的起止偏移，元素不存在时返回 false。
+func (ctx *transformContext) span(path []int32) (start, end idl_ast.Position, ok bool) {
+	loc, ok := ctx.sourceInfo[pathKey(path)]
+	if !ok || len(loc.GetSpan()) < 3 {
+		return start, end, false
+	}
+	s := loc.GetSpan()
+	startLine, startCol := int(s[0]), int(s[1])
+	endLine, endCol := startLine, int(s[2])
+	if len(s) == 4 {
+		endLine, endCol = int(s[2]), int(s[3])
+	}
+	start = idl_ast.Position{Line: startLine + 1, Column: startCol + 1, Offset: ctx.offsetOf(startLine, startCol)}
+	end = idl_ast.Position{Line: endLine + 1, Column: endCol + 1, Offset: ctx.offsetOf(endLine, endCol)}
+	return start, end, true
+}
+
+func (ctx *transformContext) location(path []int32) *idl_ast.Location {
+	if ctx.opts.NoLocation {
+		return nil
+	}
+	start, end, ok := ctx.span(path)
+	if !ok {
+		return nil
+	}
+	return &idl_ast.Location{Start: start, End: end}
+}
+
+func (ctx *transformContext) fileLocation() *idl_ast.Location {
+	if ctx.opts.NoLocation {
+		return nil
+	}
+	last := len(ctx.lines) - 1
+	return &idl_ast.Location{
+		Start: idl_ast.Position{Line: 1, Column: 1, Offset: 0},
+		End:   idl_ast.Position{Line: last + 1, Column: len(ctx.source) - ctx.lines[last] + 1, Offset: len(ctx.source)},
+	}
+}
+
+// content 返回 path 对应元素的源代码文本。
+func (ctx *transformContext) content(path []int32) string {
+	start, end, ok := ctx.span(path)
+	if !ok || start.Offset > end.Offset || end.Offset > len(ctx.source) {
+		return ""
+	}
+	return string(ctx.source[start.Offset:end.Offset])
+}
+
+// comments 返回 path 对应元素的前置注释和行尾注释。SourceCodeInfo 只保留注释的文本，
+// 因此每一行都还原为 "//" 形式的单行注释，并且没有位置信息。
+func (ctx *transformContext) comments(path []int32) []idl_ast.Comment {
+	if ctx.opts.NoComments {
+		return nil
+	}
+	loc, ok := ctx.sourceInfo[pathKey(path)]
+	if !ok {
+		return nil
+	}
+	var res []idl_ast.Comment
+	add := func(text string) {
+		for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+			res = append(res, idl_ast.Comment{Text: "//" + line})
+		}
+	}
+	for _, detached := range loc.GetLeadingDetachedComments() {
+		add(detached)
+	}
+	if loc.LeadingComments != nil {
+		add(loc.GetLeadingComments())
+	}
+	if loc.TrailingComments != nil {
+		add(loc.GetTrailingComments())
+	}
+	return res
+}
+
+// transformOptions 把未解释的 option 转换为注解。扩展选项的名称保留括号，
+// 例如 `option (google.api.http).get = "/v1/users"` 的名称是 "(google.api.http).get"。
+func transformOptions(options []*descriptorpb.UninterpretedOption) []idl_ast.Annotation {
+	if len(options) == 0 {
+		return nil
+	}
+	res := make([]idl_ast.Annotation, len(options))
+	for i, opt := range options {
+		parts := make([]string, len(opt.GetName()))
+		for j, part := range opt.GetName() {
+			if part.GetIsExtension() {
+				parts[j] = "(" + part.GetNamePart() + ")"
+			} else {
+				parts[j] = part.GetNamePart()
+			}
+		}
+		res[i] = idl_ast.Annotation{
+			Name:  strings.Join(parts, "."),
+			Value: optionValue(opt),
+		}
+	}
+	return res
+}
+
+func optionValue(opt *descriptorpb.UninterpretedOption) *idl_ast.ConstantValue {
+	switch {
+	case opt.IdentifierValue != nil:
+		return scalarValue(opt.GetIdentifierValue())
+	case opt.PositiveIntValue != nil:
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: int64(opt.GetPositiveIntValue())}
+	case opt.NegativeIntValue != nil:
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: opt.GetNegativeIntValue()}
+	case opt.DoubleValue != nil:
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: opt.GetDoubleValue()}
+	case opt.StringValue != nil:
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: strconv.Quote(string(opt.GetStringValue()))}
+	case opt.AggregateValue != nil:
+		p := &aggregateParser{tokens: tokenizeAggregate(opt.GetAggregateValue())}
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantMap, Value: p.parseEntries("")}
+	}
+	return nil
+}
+
+var (
+	// 文本格式中的整数：十进制、以 0 开头的八进制和以 0x 开头的十六进制。
+	textIntRegex = regexp.MustCompile(`^-?(0[xX][0-9a-fA-F]+|0[0-7]*|[1-9][0-9]*)$`)
+	// 文本格式中的浮点数，可以带 f/F 后缀。
+	textFloatRegex = regexp.MustCompile(`^-?((([0-9]+\.[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?|[0-9]+[eE][+-]?[0-9]+)[fF]?|[0-9]+[fF])$`)
+)
+
+// scalarValue 转换文本格式中的单个值：字符串保留引号，true/false 转为 bool，
+// 数字转为 int64 或 float64，其余视为标识符（例如枚举值）。只接受 protobuf 文本格式的数字写法，
+// 因此 NAN、INF 这样的枚举值以及 Go 特有的 1_000、0b1 不会被当作数字。
+func scalarValue(token string) *idl_ast.ConstantValue {
+	if token == "" {
+		return nil
+	}
+	if token[0] == '"' || token[0] == '\'' {
+		if s, err := strconv.Unquote(token); err == nil {
+			return &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: strconv.Quote(s)}
+		}
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: token}
+	}
+	if token == "true" || token == "false" {
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantBool, Value: token == "true"}
+	}
+	if textIntRegex.MatchString(token) {
+		if i, err := strconv.ParseInt(token, 0, 64); err == nil {
+			return &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: i}
+		}
+	}
+	if textFloatRegex.MatchString(token) {
+		if f, err := strconv.ParseFloat(strings.TrimRight(token, "fF"), 64); err == nil {
+			return &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: f}
+		}
+	}
+	return &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: token}
+}
+
+// tokenizeAggregate 把 aggregate option 的文本格式（例如 `get: "/v1/users" body: "*"`）切分为词法单元。
+func tokenizeAggregate(text string) []string {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.IndexByte("{}[]<>:,;", c) != -1:
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(text) && text[j] != c {
+				if text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(text) {
+				j++
+			}
+			if j > len(text) {
+				j = len(text)
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(text) && strings.IndexByte(" \t\n\r{}[]<>:,;\"'", text[j]) == -1 {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// aggregateParser 把 aggregate option 解析为 []*idl_ast.ConstantMapEntry，
+// 键是字段名（标识符），值可以是标量、嵌套消息或列表。
+type aggregateParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *aggregateParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *aggregateParser) next() string {
+	tok := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *aggregateParser) parseEntries(closing string) []*idl_ast.ConstantMapEntry {
+	var entries []*idl_ast.ConstantMapEntry
+	for p.pos < len(p.tokens) && p.peek() != closing {
+		key := p.next()
+		if key == "[" {
+			// 扩展字段名，例如 [foo.bar]
+			var name []string
+			for p.pos < len(p.tokens) && p.peek() != "]" {
+				name = append(name, p.next())
+			}
+			p.next()
+			key = "[" + strings.Join(name, "") + "]"
+		}
+		if p.peek() == ":" {
+			p.next()
+		}
+		entries = append(entries, &idl_ast.ConstantMapEntry{
+			Key:   &idl_ast.ConstantValue{Kind: idl_ast.ConstantIdentifier, Value: key},
+			Value: p.parseValue(),
+		})
+		if p.peek() == "," || p.peek() == ";" {
+			p.next()
+		}
+	}
+	p.next()
+	return entries
+}
+
+func (p *aggregateParser) parseValue() *idl_ast.ConstantValue {
+	switch tok := p.next(); tok {
+	case "{":
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantMap, Value: p.parseEntries("}")}
+	case "<":
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantMap, Value: p.parseEntries(">")}
+	case "[":
+		var items []*idl_ast.ConstantValue
+		for p.pos < len(p.tokens) && p.peek() != "]" {
+			items = append(items, p.parseValue())
+			if p.peek() == "," {
+				p.next()
+			}
+		}
+		p.next()
+		return &idl_ast.ConstantValue{Kind: idl_ast.ConstantList, Value: items}
+	default:
+		return scalarValue(tok)
+	}
+}
@@ -0,0 +1,799 @@
+package protowriter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+type Options struct {
+	NoComments bool
+	// GoPackagePrefix 会被加在由 Thrift `namespace go` 推导出的 go_package 前面，
+	// 例如前缀 "example.com/kitex_gen" 与 namespace "user.v1" 得到 "example.com/kitex_gen/user/v1"。
+	GoPackagePrefix string
+}
+
+type Option func(o *Options)
+
+func WithNoComments(noComments bool) Option {
+	return func(o *Options) {
+		o.NoComments = noComments
+	}
+}
+
+func WithGoPackagePrefix(prefix string) Option {
+	return func(o *Options) {
+		o.GoPackagePrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+const (
+	emptyImport       = "google/protobuf/empty.proto"
+	annotationsImport = "google/api/annotations.proto"
+	emptyType         = "google.protobuf.Empty"
+)
+
+// Generate 把 schema 转换为 proto3 源文件，返回 `文件路径 -> 内容` 的映射。
+// 文件路径中的 .thrift 扩展名会被替换为 .proto。主要的映射规则：
+//   - typedef 被展开为它的原始类型；
+//   - union 变为只包含一个 oneof 的 message，exception 变为普通 message；
+//   - list / set 变为 repeated，嵌套容器和 oneof 中的容器会生成包装 message；
+//   - namespace 变为 package 和 go_package；
+//   - api.get / api.post 等注解变为 google.api.http 选项；
+//   - 多参数函数生成 XxxRequest，无参数和 void 返回值变为 google.protobuf.Empty；
+//   - service 的 extends 被展开，继承的函数直接写入派生 service。
+//
+// const 在 proto3 中没有对应的语法，会被忽略；throws 子句同样被忽略。
+func Generate(schema *idl_ast.IDLSchema, opts ...Option) (map[string][]byte, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("input schema cannot be nil")
+	}
+	options := &Options{
+		NoComments: false,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	packages := make(map[string]string, len(schema.Files))
+	for i := range schema.Files {
+		packages[schema.Files[i].Path] = packageName(&schema.Files[i])
+	}
+
+	outputFiles := make(map[string][]byte)
+	for i := range schema.Files {
+		file := &schema.Files[i]
+		writer := &protoWriter{
+			b:           &strings.Builder{},
+			indentStr:   "  ",
+			opts:        options,
+			schema:      schema,
+			file:        file,
+			packages:    packages,
+			fromProto:   schema.IDLType == "protobuf",
+			imports:     make(map[string]struct{}),
+			generated:   make(map[string]struct{}),
+			definedName: make(map[string]struct{}),
+		}
+		writer.writeFileContent()
+		if writer.err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.Path, writer.err)
+		}
+		outputFiles[protoPath(file.Path)] = []byte(writer.b.String())
+	}
+	return outputFiles, nil
+}
+
+// protoPath 把 Thrift 文件路径转换为对应的 .proto 路径。
+func protoPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".proto"
+}
+
+// packageName 按 "*"、"proto"、"go"、"java" 的优先级从 namespace 中选出 proto package。
+func packageName(file *idl_ast.File) string {
+	for _, scope := range []string{"*", "proto", "go", "java"} {
+		for _, ns := range file.Namespaces {
+			if ns.Scope == scope {
+				return strings.NewReplacer("/", ".", "-", "_").Replace(ns.Name)
+			}
+		}
+	}
+	return ""
+}
+
+type protoWriter struct {
+	b                *strings.Builder
+	indentationLevel int
+	indentStr        string
+	opts             *Options
+	schema           *idl_ast.IDLSchema
+	file             *idl_ast.File
+	packages         map[string]string
+	// fromProto 为 true 时，schema 由 protoparser 生成，注解本身就是 proto 选项，可以原样写回。
+	fromProto bool
+
+	imports map[string]struct{}
+	// messages 是为容器、请求和响应生成的额外 message，写在文件中所有定义之后。
+	messages    []generatedMessage
+	generated   map[string]struct{}
+	definedName map[string]struct{}
+	// err 记录生成过程中遇到的第一个错误。
+	err error
+}
+
+type generatedMessage struct {
+	name   string
+	fields []generatedField
+}
+
+type generatedField struct {
+	typ  string
+	name string
+	id   int
+}
+
+func (w *protoWriter) writeFileContent() {
+	defs := &w.file.Definitions
+	for _, m := range defs.Messages {
+		w.definedName[m.Name] = struct{}{}
+	}
+	for _, e := range defs.Enums {
+		w.definedName[e.Name] = struct{}{}
+	}
+
+	// 先渲染定义，以便收集需要的 import。
+	body := &strings.Builder{}
+	header := w.b
+	w.b = body
+	w.writeDefinitions(defs)
+	w.b = header
+
+	w.writeLine(`syntax = "proto3";`)
+	w.writeLine("")
+	if pkg := w.packages[w.file.Path]; pkg != "" {
+		w.writeLinef("package %s;", pkg)
+		w.writeLine("")
+	}
+	w.writeImports()
+	w.writeFileOptions()
+	w.b.WriteString(body.String())
+}
+
+func (w *protoWriter) writeImports() {
+	if len(w.imports) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(w.imports))
+	for path := range w.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		w.writeLinef("import %q;", path)
+	}
+	w.writeLine("")
+}
+
+func (w *protoWriter) writeFileOptions() {
+	var lines []string
+	hasGoPackage := false
+	if w.fromProto {
+		for _, opt := range w.file.Options {
+			hasGoPackage = hasGoPackage || opt.Name == "go_package"
+			lines = append(lines, fmt.Sprintf("option %s = %s;", opt.Name, w.formatOptionValue(opt.Value)))
+		}
+	}
+	if !hasGoPackage {
+		for _, ns := range w.file.Namespaces {
+			if ns.Scope == "go" {
+				goPackage := strings.ReplaceAll(ns.Name, ".", "/")
+				if w.opts.GoPackagePrefix != "" {
+					goPackage = w.opts.GoPackagePrefix + "/" + goPackage
+				}
+				lines = append(lines, fmt.Sprintf("option go_package = %q;", goPackage))
+				break
+			}
+		}
+	}
+	for _, line := range lines {
+		w.writeLine(line)
+	}
+	if len(lines) > 0 {
+		w.writeLine("")
+	}
+}
+
+func (w *protoWriter) writeDefinitions(defs *idl_ast.Definitions) {
+	// protoparser 把嵌套类型展开为 "Outer.Inner"，这里把它们重新写回父 message 中。
+	for i := range defs.Enums {
+		if !strings.Contains(defs.Enums[i].Name, ".") {
+			w.writeEnum(&defs.Enums[i])
+			w.writeLine("")
+		}
+	}
+	for i := range defs.Messages {
+		if !strings.Contains(defs.Messages[i].Name, ".") {
+			w.writeMessage(&defs.Messages[i])
+			w.writeLine("")
+		}
+	}
+	for i := range defs.Services {
+		w.writeService(&defs.Services[i])
+		w.writeLine("")
+	}
+	// 包装 message 在渲染过程中可能继续生成新的包装 message。
+	for i := 0; i < len(w.messages); i++ {
+		w.writeGeneratedMessage(&w.messages[i])
+		w.writeLine("")
+	}
+}
+
+func (w *protoWriter) writeComments(comments []idl_ast.Comment) {
+	if w.opts.NoComments {
+		return
+	}
+	for _, comment := range comments {
+		text := strings.TrimRight(comment.Text, "\r\n")
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "#") {
+				line = "//" + strings.TrimPrefix(line, "#")
+			}
+			w.writeLine(line)
+		}
+	}
+}
+
+// enumValueNames 返回 enum 各成员在 proto 中的名称。proto 的枚举成员与枚举本身处于同一作用域，
+// 当同一文件中的多个枚举有同名成员时，给这些枚举的成员加上枚举名前缀。
+func (w *protoWriter) enumValueNames(e *idl_ast.Enum) []string {
+	counts := make(map[string]int)
+	for _, other := range w.file.Definitions.Enums {
+		if parentName(other.Name) != parentName(e.Name) {
+			continue
+		}
+		for _, v := range other.Values {
+			counts[v.Name]++
+		}
+	}
+	prefix := ""
+	for _, v := range e.Values {
+		if counts[v.Name] > 1 {
+			prefix = upperSnake(localName(e.Name)) + "_"
+			break
+		}
+	}
+	names := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		names[i] = v.Name
+		if prefix != "" && !strings.HasPrefix(v.Name, prefix) {
+			names[i] = prefix + v.Name
+		}
+	}
+	return names
+}
+
+func (w *protoWriter) writeEnum(e *idl_ast.Enum) {
+	w.writeComments(e.Comments)
+	w.writeLinef("enum %s {", localName(e.Name))
+	w.indent()
+	w.writeOptionStatements(e.Annotations)
+
+	// proto3 要求枚举的第一个成员为 0：已有值为 0 的成员时把它移到最前面，否则补一个 XXX_UNSPECIFIED。
+	order := make([]int, 0, len(e.Values))
+	for i, v := range e.Values {
+		if v.Value == 0 {
+			order = append(order, i)
+			break
+		}
+	}
+	if len(order) == 0 {
+		w.writeLinef("%s_UNSPECIFIED = 0;", upperSnake(localName(e.Name)))
+	}
+	for i := range e.Values {
+		if len(order) == 0 || i != order[0] {
+			order = append(order, i)
+		}
+	}
+	names := w.enumValueNames(e)
+	for _, i := range order {
+		v := &e.Values[i]
+		w.writeComments(v.Comments)
+		w.writeLinef("%s = %d%s;", names[i], v.Value, w.formatFieldOptions(v.Annotations))
+	}
+	w.unindent()
+	w.writeLine("}")
+}
+
+func (w *protoWriter) writeMessage(m *idl_ast.Message) {
+	w.writeComments(m.Comments)
+	w.writeLinef("message %s {", localName(m.Name))
+	w.indent()
+	w.writeOptionStatements(m.Annotations)
+
+	if m.Type == "union" {
+		w.writeLinef("oneof %s {", lowerSnake(localName(m.Name)))
+		w.indent()
+		for i := range m.Fields {
+			w.writeField(&m.Fields[i], true)
+		}
+		w.unindent()
+		w.writeLine("}")
+	} else {
+		for i := 0; i < len(m.Fields); {
+			oneof := oneofName(&m.Fields[i])
+			if oneof == "" {
+				w.writeField(&m.Fields[i], false)
+				i++
+				continue
+			}
+			w.writeLinef("oneof %s {", oneof)
+			w.indent()
+			for i < len(m.Fields) && oneofName(&m.Fields[i]) == oneof {
+				w.writeField(&m.Fields[i], true)
+				i++
+			}
+			w.unindent()
+			w.writeLine("}")
+		}
+	}
+
+	for i := range w.file.Definitions.Enums {
+		if e := &w.file.Definitions.Enums[i]; parentName(e.Name) == m.Name {
+			w.writeEnum(e)
+		}
+	}
+	for i := range w.file.Definitions.Messages {
+		if nested := &w.file.Definitions.Messages[i]; parentName(nested.Name) == m.Name {
+			w.writeMessage(nested)
+		}
+	}
+	w.unindent()
+	w.writeLine("}")
+}
+
+// oneofName 返回 protoparser 在 oneof 成员上记录的 oneof 名称。
+func oneofName(f *idl_ast.Field) string {
+	for _, anno := range f.Annotations {
+		if anno.Name == "oneof" {
+			if name, err := anno.Value.StringValue(); err == nil {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func (w *protoWriter) writeField(f *idl_ast.Field, inOneof bool) {
+	w.writeComments(f.Comments)
+	var typ string
+	if inOneof {
+		// oneof 中不能直接使用 repeated 和 map。
+		typ = w.elementType(&f.Type)
+	} else {
+		typ = w.fieldType(&f.Type)
+	}
+	w.writeLinef("%s %s = %d%s;", typ, f.Name, f.ID, w.formatFieldOptions(f.Annotations))
+}
+
+func (w *protoWriter) writeGeneratedMessage(m *generatedMessage) {
+	w.writeLinef("message %s {", m.name)
+	w.indent()
+	for _, f := range m.fields {
+		w.writeLinef("%s %s = %d;", f.typ, f.name, f.id)
+	}
+	w.unindent()
+	w.writeLine("}")
+}
+
+// writeService 输出 service 的有效方法集。proto 的 service 不支持继承，
+// 通过 extends 继承的函数会按 idl_ast.ResolveService 的顺序直接写入派生 service。
+func (w *protoWriter) writeService(s *idl_ast.Service) {
+	functions := make([]*idl_ast.Function, 0, len(s.Functions))
+	if s.Extends == "" {
+		for i := range s.Functions {
+			functions = append(functions, &s.Functions[i])
+		}
+	} else {
+		resolved, err := w.schema.ResolveService(s.FullyQualifiedName)
+		if err != nil {
+			if w.err == nil {
+				w.err = err
+			}
+			return
+		}
+		for _, inherited := range resolved.Functions {
+			functions = append(functions, inherited.Function)
+		}
+	}
+
+	w.writeComments(s.Comments)
+	w.writeLinef("service %s {", s.Name)
+	w.indent()
+	w.writeOptionStatements(s.Annotations)
+	for _, f := range functions {
+		w.writeFunction(s, f)
+	}
+	w.unindent()
+	w.writeLine("}")
+}
+
+func (w *protoWriter) writeFunction(s *idl_ast.Service, f *idl_ast.Function) {
+	w.writeComments(f.Comments)
+
+	request := w.requestType(s, f)
+	response := w.responseType(s, f)
+	if hasFlag(f.Annotations, "client_streaming") {
+		request = "stream " + request
+	}
+	if hasFlag(f.Annotations, "server_streaming") {
+		response = "stream " + response
+	}
+
+	var options []string
+	if w.fromProto {
+		for _, anno := range f.Annotations {
+			if anno.Name != "client_streaming" && anno.Name != "server_streaming" {
+				options = append(options, fmt.Sprintf("option %s = %s;", anno.Name, w.formatOptionValue(anno.Value)))
+			}
+		}
+	} else if rule := httpRule(f.Annotations); rule != "" {
+		w.imports[annotationsImport] = struct{}{}
+		options = append(options, fmt.Sprintf("option (google.api.http) = {%s};", rule))
+	}
+
+	signature := fmt.Sprintf("rpc %s(%s) returns (%s)", f.Name, request, response)
+	if len(options) == 0 {
+		w.writeLine(signature + ";")
+		return
+	}
+	w.writeLine(signature + " {")
+	w.indent()
+	for _, opt := range options {
+		w.writeLine(opt)
+	}
+	w.unindent()
+	w.writeLine("}")
+}
+
+func hasFlag(annos []idl_ast.Annotation, name string) bool {
+	for _, anno := range annos {
+		if anno.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// requestType 返回 rpc 的请求类型。没有参数时使用 google.protobuf.Empty，
+// 只有一个 message 类型的参数时直接使用它，否则生成一个包含所有参数的 XxxRequest。
+func (w *protoWriter) requestType(s *idl_ast.Service, f *idl_ast.Function) string {
+	if len(f.Parameters) == 0 {
+		w.imports[emptyImport] = struct{}{}
+		return emptyType
+	}
+	if len(f.Parameters) == 1 {
+		if t := w.resolve(&f.Parameters[0].Type); w.isMessage(t) {
+			return w.scalarOrRef(t)
+		}
+	}
+	name := w.uniqueName(s.Name, pascal(f.Name)+"Request")
+	fields := make([]generatedField, len(f.Parameters))
+	for i := range f.Parameters {
+		p := &f.Parameters[i]
+		fields[i] = generatedField{typ: w.fieldType(&p.Type), name: p.Name, id: p.ID}
+	}
+	w.addMessage(name, fields)
+	return name
+}
+
+// responseType 返回 rpc 的响应类型。void 变为 google.protobuf.Empty，
+// 非 message 的返回值被包装进 XxxResponse 的 value 字段。
+func (w *protoWriter) responseType(s *idl_ast.Service, f *idl_ast.Function) string {
+	t := w.resolve(&f.ReturnType)
+	if t.Name == "void" || t.Name == "" {
+		w.imports[emptyImport] = struct{}{}
+		return emptyType
+	}
+	if w.isMessage(t) {
+		return w.scalarOrRef(t)
+	}
+	name := w.uniqueName(s.Name, pascal(f.Name)+"Response")
+	w.addMessage(name, []generatedField{{typ: w.fieldType(t), name: "value", id: 1}})
+	return name
+}
+
+func (w *protoWriter) uniqueName(serviceName, name string) string {
+	if _, ok := w.definedName[name]; ok {
+		name = serviceName + name
+	}
+	w.definedName[name] = struct{}{}
+	return name
+}
+
+func (w *protoWriter) addMessage(name string, fields []generatedField) {
+	if _, ok := w.generated[name]; ok {
+		return
+	}
+	w.generated[name] = struct{}{}
+	w.messages = append(w.messages, generatedMessage{name: name, fields: fields})
+}
+
+// httpRule 把 api.get / api.post 等注解转换为 google.api.http 规则的内容。
+func httpRule(annos []idl_ast.Annotation) string {
+	for _, anno := range annos {
+		method, ok := strings.CutPrefix(anno.Name, "api.")
+		if !ok {
+			continue
+		}
+		switch method {
+		case "get", "post", "put", "patch", "delete":
+		default:
+			continue
+		}
+		path, err := anno.Value.StringValue()
+		if err != nil {
+			continue
+		}
+		rule := fmt.Sprintf("%s: %q", method, convertPathParams(path))
+		if method == "post" || method == "put" || method == "patch" {
+			rule += ` body: "*"`
+		}
+		return rule
+	}
+	return ""
+}
+
+// convertPathParams 把 Hertz 风格的路径参数 "/users/:id" 转换为 "/users/{id}"。
+func convertPathParams(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (w *protoWriter) writeOptionStatements(annos []idl_ast.Annotation) {
+	if !w.fromProto {
+		return
+	}
+	for _, anno := range annos {
+		w.writeLinef("option %s = %s;", anno.Name, w.formatOptionValue(anno.Value))
+	}
+}
+
+func (w *protoWriter) formatFieldOptions(annos []idl_ast.Annotation) string {
+	if !w.fromProto {
+		return ""
+	}
+	var parts []string
+	for _, anno := range annos {
+		if anno.Name == "oneof" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s = %s", anno.Name, w.formatOptionValue(anno.Value)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [%s]", strings.Join(parts, ", "))
+}
+
+func (w *protoWriter) formatOptionValue(cv *idl_ast.ConstantValue) string {
+	if cv == nil || cv.Value == nil {
+		return `""`
+	}
+	switch v := cv.Value.(type) {
+	case []*idl_ast.ConstantValue:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = w.formatOptionValue(item)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+	case []*idl_ast.ConstantMapEntry:
+		entries := make([]string, len(v))
+		for i, entry := range v {
+			entries[i] = fmt.Sprintf("%s: %s", w.formatOptionValue(entry.Key), w.formatOptionValue(entry.Value))
+		}
+		return fmt.Sprintf("{%s}", strings.Join(entries, " "))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// resolve 展开 typedef，返回最终的类型。
+func (w *protoWriter) resolve(t *idl_ast.Type) *idl_ast.Type {
+	if t == nil {
+		return nil
+	}
+	if resolved, err := w.schema.ResolveType(t); err == nil {
+		return resolved.Type
+	}
+	return t
+}
+
+func (w *protoWriter) isMessage(t *idl_ast.Type) bool {
+	return t != nil && t.FullyQualifiedName != "" && len(w.schema.FindMessagesByFQN(t.FullyQualifiedName)) > 0
+}
+
+// fieldType 返回字段类型在 proto 中的写法，例如 "string"、"repeated Entity" 或 "map<string, int64>"。
+func (w *protoWriter) fieldType(t *idl_ast.Type) string {
+	t = w.resolve(t)
+	if t == nil {
+		return ""
+	}
+	switch t.Name {
+	case "list", "set":
+		return "repeated " + w.elementType(t.ValueType)
+	case "map":
+		key := w.resolve(t.KeyType)
+		if isValidMapKey(key) {
+			return fmt.Sprintf("map<%s, %s>", w.scalarOrRef(key), w.elementType(t.ValueType))
+		}
+		// 不能作为 map 键的类型，退化为键值对列表。
+		entry := typeTitle(key) + typeTitle(w.resolve(t.ValueType)) + "Entry"
+		w.addMessage(entry, []generatedField{
+			{typ: w.elementType(key), name: "key", id: 1},
+			{typ: w.elementType(t.ValueType), name: "value", id: 2},
+		})
+		return "repeated " + entry
+	default:
+		return w.scalarOrRef(t)
+	}
+}
+
+// elementType 返回容器元素的类型。容器不能直接嵌套，嵌套的容器会被包装进一个 message。
+func (w *protoWriter) elementType(t *idl_ast.Type) string {
+	t = w.resolve(t)
+	if t == nil {
+		return ""
+	}
+	switch t.Name {
+	case "list", "set", "map":
+		name := typeTitle(t)
+		w.addMessage(name, []generatedField{{typ: w.fieldType(t), name: "items", id: 1}})
+		return name
+	default:
+		return w.scalarOrRef(t)
+	}
+}
+
+func (w *protoWriter) scalarOrRef(t *idl_ast.Type) string {
+	if scalar, ok := scalarTypes[t.Name]; ok && t.FullyQualifiedName == "" {
+		return scalar
+	}
+	if t.FullyQualifiedName == "" {
+		return t.Name
+	}
+	path, name, ok := idl_ast.SplitFQN(t.FullyQualifiedName)
+	if !ok || path == w.file.Path {
+		return name
+	}
+	w.imports[protoPath(path)] = struct{}{}
+	if pkg := w.packages[path]; pkg != "" && pkg != w.packages[w.file.Path] {
+		return pkg + "." + name
+	}
+	return name
+}
+
+// scalarTypes 把 Thrift 和 Protobuf 的基本类型映射到 proto3 的标量类型。
+var scalarTypes = map[string]string{
+	"bool": "bool", "byte": "int32", "i8": "int32", "i16": "int32", "i32": "int32", "i64": "int64",
+	"double": "double", "string": "string", "binary": "bytes",
+
+	"float": "float", "int32": "int32", "int64": "int64", "uint32": "uint32", "uint64": "uint64",
+	"sint32": "sint32", "sint64": "sint64", "fixed32": "fixed32", "fixed64": "fixed64",
+	"sfixed32": "sfixed32", "sfixed64": "sfixed64", "bytes": "bytes",
+}
+
+func isValidMapKey(t *idl_ast.Type) bool {
+	if t == nil || t.FullyQualifiedName != "" {
+		return false
+	}
+	switch scalarTypes[t.Name] {
+	case "", "double", "float", "bytes":
+		return false
+	}
+	return true
+}
+
+// typeTitle 为包装 message 生成名称，例如 list<string> -> "StringList"，map<string, i64> -> "StringInt64Map"。
+func typeTitle(t *idl_ast.Type) string {
+	if t == nil {
+		return ""
+	}
+	switch t.Name {
+	case "list", "set":
+		return typeTitle(t.ValueType) + "List"
+	case "map":
+		return typeTitle(t.KeyType) + typeTitle(t.ValueType) + "Map"
+	}
+	if scalar, ok := scalarTypes[t.Name]; ok && t.FullyQualifiedName == "" {
+		return pascal(scalar)
+	}
+	name := t.Name
+	if _, defName, ok := idl_ast.SplitFQN(t.FullyQualifiedName); ok {
+		name = defName
+	}
+	return strings.ReplaceAll(name, ".", "")
+}
+
+// localName 返回嵌套类型 "Outer.Inner" 的最后一段。
+func localName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// parentName 返回嵌套类型 "Outer.Inner" 的父类型名称，顶层类型返回空字符串。
+func parentName(name string) string {
+	if i := strings.LastIndex(name, "."); i != -1 {
+		return name[:i]
+	}
+	return ""
+}
+
+func pascal(s string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range s {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func upperSnake(s string) string {
+	return strings.ToUpper(lowerSnake(s))
+}
+
+func lowerSnake(s string) string {
+	var sb strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) && runes[i-1] != '_' {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (w *protoWriter) indent() {
+	w.indentationLevel++
+}
+
+func (w *protoWriter) unindent() {
+	if w.indentationLevel > 0 {
+		w.indentationLevel--
+	}
+}
+
+func (w *protoWriter) getIndent() string {
+	return strings.Repeat(w.indentStr, w.indentationLevel)
+}
+
+func (w *protoWriter) writeLine(s string) {
+	if s != "" {
+		w.b.WriteString(w.getIndent())
+		w.b.WriteString(s)
+	}
+	w.b.WriteString("\n")
+}
+
+func (w *protoWriter) writeLinef(format string, a ...any) {
+	w.writeLine(fmt.Sprintf(format, a...))
+}
@@ -0,0 +1,197 @@
+package protowriter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/protoparser"
+	"github.com/Skyenought/idlanalyzer/thriftparser"
+)
+
+var testFiles = map[string][]byte{
+	"common/base.thrift": []byte(`
+namespace go example.common
+
+typedef string ID
+
+enum Status {
+  OK = 1,
+  ERROR = 2
+}
+
+struct Entity {
+  1: ID id
+}
+`),
+	"user.thrift": []byte(`
+namespace go example.user
+
+include "common/base.thrift"
+
+enum Kind {
+  UNKNOWN = 0,
+  OK = 1
+}
+
+enum Priority {
+  HIGH = 1,
+  NONE = 0
+}
+
+// User 是用户信息
+struct User {
+  1: base.ID id
+  2: base.Status status
+  3: set<string> tags
+  4: map<string, list<base.Entity>> groups
+  5: map<base.Entity, i64> scores
+}
+
+union Contact {
+  1: string email
+  2: list<string> phones
+}
+
+exception NotFound {
+  1: string message
+}
+
+service UserService {
+  User GetUser(1: base.ID id) (api.get = "/users/:id")
+  User UpdateUser(1: User user) throws (1: NotFound e) (api.put = "/users/:id")
+  void Ping()
+  i64 Count(1: string prefix, 2: i32 limit)
+}
+`),
+}
+
+func TestGenerate(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", testFiles)
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	files, err := Generate(schema, WithGoPackagePrefix("example.com/gen"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	base := string(files["common/base.proto"])
+	assert.Contains(t, base, "package example.common;")
+	assert.Contains(t, base, `option go_package = "example.com/gen/example/common";`)
+	assert.Contains(t, base, "STATUS_UNSPECIFIED = 0;")
+	assert.Contains(t, base, "string id = 1;")
+	assert.NotContains(t, base, "ID")
+
+	user := string(files["user.proto"])
+	assert.Contains(t, user, `import "common/base.proto";`)
+	assert.Contains(t, user, `import "google/api/annotations.proto";`)
+	assert.Contains(t, user, `import "google/protobuf/empty.proto";`)
+	assert.Contains(t, user, "// User 是用户信息")
+	assert.Contains(t, user, "string id = 1;")
+	assert.Contains(t, user, "example.common.Status status = 2;")
+	assert.Contains(t, user, "repeated string tags = 3;")
+	assert.Contains(t, user, "map<string, EntityList> groups = 4;")
+	assert.Contains(t, user, "repeated EntityInt64Entry scores = 5;")
+	assert.Contains(t, user, "oneof contact {")
+	assert.Contains(t, user, "StringList phones = 2;")
+	assert.Contains(t, user, "message NotFound {")
+	// 与 base.Status 不冲突，但同一文件内的 Kind.OK 不加前缀
+	assert.Contains(t, user, "OK = 1;")
+	// 值为 0 的成员被移到最前面，不再补 PRIORITY_UNSPECIFIED。
+	assert.Contains(t, user, "enum Priority {\n  NONE = 0;\n  HIGH = 1;\n}")
+
+	assert.Contains(t, user, "rpc GetUser(GetUserRequest) returns (User) {")
+	assert.Contains(t, user, `option (google.api.http) = {get: "/users/{id}"};`)
+	assert.Contains(t, user, "rpc UpdateUser(User) returns (User) {")
+	assert.Contains(t, user, `option (google.api.http) = {put: "/users/{id}" body: "*"};`)
+	assert.Contains(t, user, "rpc Ping(google.protobuf.Empty) returns (google.protobuf.Empty);")
+	assert.Contains(t, user, "rpc Count(CountRequest) returns (CountResponse);")
+	assert.Contains(t, user, "int64 value = 1;")
+
+	// 生成的文件应当能被 protoparser 重新解析
+	_, err = protoparser.NewParserFromMap("project", files)
+	require.NoError(t, err)
+}
+
+func TestGenerate_Extends(t *testing.T) {
+	files := map[string][]byte{
+		"common/base.thrift": []byte(`
+service BaseService {
+  void Ping()
+  string Echo(1: string msg)
+}
+`),
+		"user.thrift": []byte(`
+include "common/base.thrift"
+
+service UserService extends base.BaseService {
+  string Echo(1: string msg, 2: i32 times)
+  i64 Count()
+}
+`),
+	}
+	parser, err := thriftparser.NewParserFromMap("project", files)
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	out, err := Generate(schema)
+	require.NoError(t, err)
+	user := string(out["user.proto"])
+	assert.Contains(t, user, `service UserService {
+  rpc Ping(google.protobuf.Empty) returns (google.protobuf.Empty);
+  rpc Echo(EchoRequest) returns (EchoResponse);
+  rpc Count(google.protobuf.Empty) returns (CountResponse);
+}`)
+	assert.Contains(t, user, "int32 times = 2;")
+
+	// extends 无法解析时返回错误，而不是悄悄丢掉继承的函数。
+	services := schema.FindServicesByFQN("user.thrift#UserService")
+	require.Len(t, services, 1)
+	services[0].Extends = "base.Missing"
+	_, err = Generate(schema)
+	assert.ErrorContains(t, err, `cannot resolve extends "base.Missing"`)
+}
+
+func TestGenerate_Proto(t *testing.T) {
+	files := map[string][]byte{
+		"user.proto": []byte(`syntax = "proto3";
+
+package example.user;
+
+option go_package = "example.com/gen/user";
+
+message User {
+  string name = 1 [deprecated = true];
+  oneof contact {
+    string email = 2;
+    string phone = 3;
+  }
+
+  message Address {
+    string city = 1;
+  }
+  Address address = 4;
+}
+
+service UserService {
+  rpc Watch(User) returns (stream User);
+}
+`),
+	}
+	parser, err := protoparser.NewParserFromMap("project", files)
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	out, err := Generate(schema)
+	require.NoError(t, err)
+	user := string(out["user.proto"])
+	assert.Contains(t, user, `option go_package = "example.com/gen/user";`)
+	assert.Contains(t, user, "string name = 1 [deprecated = true];")
+	assert.Contains(t, user, "oneof contact {")
+	assert.Contains(t, user, "  message Address {")
+	assert.Contains(t, user, "rpc Watch(User) returns (stream User);")
+}
@@ -57,25 +57,12 @@ func (c *Converter) calculateImports(currentFilename string, defs *idl_ast.Defin
 	neededNamespaces := make(map[string]struct{})
 	currentFileNamespace := strings.TrimSuffix(filepath.Base(currentFilename), ".thrift")
 
-	for _, s := range defs.Services {
-		for _, f := range s.Functions {
-			c.collectNamespacesFromType(&f.ReturnType, currentFileNamespace, neededNamespaces)
-			for i := range f.Parameters {
-				c.collectNamespacesFromType(&f.Parameters[i].Type, currentFileNamespace, neededNamespaces)
-			}
-			for i := range f.Throws {
-				c.collectNamespacesFromType(&f.Throws[i].Type, currentFileNamespace, neededNamespaces)
-			}
+	idl_ast.Inspect(defs, func(node any) bool {
+		if t, ok := node.(*idl_ast.Type); ok {
+			c.collectNamespaceFromType(t, currentFileNamespace, neededNamespaces)
 		}
-	}
-	for _, m := range defs.Messages {
-		for i := range m.Fields {
-			c.collectNamespacesFromType(&m.Fields[i].Type, currentFileNamespace, neededNamespaces)
-		}
-	}
-	for _, td := range defs.Typedefs {
-		c.collectNamespacesFromType(&td.Type, currentFileNamespace, neededNamespaces)
-	}
+		return true
+	})
 
 	var imports []idl_ast.Import
 	if len(neededNamespaces) > 0 {
@@ -95,20 +82,9 @@ func (c *Converter) calculateImports(currentFilename string, defs *idl_ast.Defin
 	return imports
 }
 
-func (c *Converter) collectNamespacesFromType(t *idl_ast.Type, currentFileNamespace string, needed map[string]struct{}) {
-	if t == nil {
-		return
-	}
-
+func (c *Converter) collectNamespaceFromType(t *idl_ast.Type, currentFileNamespace string, needed map[string]struct{}) {
 	ns, _ := splitDefinitionName(t.Name)
 	if ns != "main" && ns != currentFileNamespace && ns != "" {
 		needed[ns] = struct{}{}
 	}
-
-	if t.KeyType != nil {
-		c.collectNamespacesFromType(t.KeyType, currentFileNamespace, needed)
-	}
-	if t.ValueType != nil {
-		c.collectNamespacesFromType(t.ValueType, currentFileNamespace, needed)
-	}
 }
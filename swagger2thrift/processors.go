@@ -102,9 +102,10 @@ func (c *Converter) processSchemas(schemas map[string]*Schema) {
 
 		} else if isTypedefCandidate(schema) {
 			typedef := idl_ast.Typedef{
-				Alias:    shortName,
-				Type:     *c.convertSchemaToType(schema, namespace, "", ""),
-				Comments: descriptionToComments(schema.Description),
+				Alias:              shortName,
+				FullyQualifiedName: fqn,
+				Type:               *c.convertSchemaToType(schema, namespace, "", ""),
+				Comments:           descriptionToComments(schema.Description),
 			}
 			defs.Typedefs = append(defs.Typedefs, typedef)
 
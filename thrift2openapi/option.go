@@ -0,0 +1,32 @@
+package thrift2openapi
+
+// convertOptions holds the internal configuration for the converter.
+type convertOptions struct {
+	title   string
+	version string
+}
+
+// Option is the functional option type.
+type Option func(*convertOptions)
+
+// newDefaultOptions creates the default internal configuration.
+func newDefaultOptions() *convertOptions {
+	return &convertOptions{
+		title:   "API",
+		version: "1.0.0",
+	}
+}
+
+// WithTitle sets info.title of the generated document. The default is "API".
+func WithTitle(title string) Option {
+	return func(opts *convertOptions) {
+		opts.title = title
+	}
+}
+
+// WithVersion sets info.version of the generated document. The default is "1.0.0".
+func WithVersion(version string) Option {
+	return func(opts *convertOptions) {
+		opts.version = version
+	}
+}
@@ -0,0 +1,479 @@
+package thrift2openapi
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+const jsonContentType = "application/json"
+
+// httpMethods 是支持的 api.xxx 函数注解，顺序决定了同一函数上多个注解时的优先级。
+var httpMethods = []string{"get", "post", "put", "delete", "patch", "head", "options"}
+
+// errorCodeRegex 匹配 swagger2thrift 为错误响应生成的 throws 字段名，例如 error404。
+var errorCodeRegex = regexp.MustCompile(`^error([1-5]\d\d)$`)
+
+// Convert 把 schema 中带有 api.get / api.post 等注解的函数转换为 OpenAPI 3 文档：
+//   - 注解中的路径成为 paths，Hertz 风格的 :id 和 *path 被转换为 {id} 和 {path}；
+//   - 请求 struct 中带有 api.query / api.path / api.header / api.cookie 的字段成为参数，
+//     带有 api.body / api.form / api.raw_body 的字段成为请求体；
+//   - 返回值成为 200 响应，throws 中的每个字段成为一个错误响应；
+//   - 被引用到的 struct、union、exception 和 enum 成为 components.schemas。
+//   - service 通过 extends 继承的函数也会被转换，并带上该 service 的 tag。
+//
+// 没有 api 注解的字段，在 GET、DELETE、HEAD 请求中被视为 query 参数，否则被视为 JSON 请求体的属性。
+// 同一路径和方法被多个函数使用时返回错误。
+func Convert(schema *idl_ast.IDLSchema, opts ...Option) (*Document, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("input schema cannot be nil")
+	}
+	options := newDefaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	c := &converter{
+		schema:     schema,
+		doc:        &Document{OpenAPI: "3.0.3", Info: Info{Title: options.title, Version: options.version}, Paths: make(map[string]*PathItem)},
+		schemas:    make(map[string]*Schema),
+		names:      componentNames(schema),
+		operations: make(map[string]*operation),
+		opIDs:      make(map[string]int),
+	}
+	for i := range schema.Files {
+		file := &schema.Files[i]
+		for j := range file.Definitions.Services {
+			if err := c.convertService(&file.Definitions.Services[j]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(c.schemas) > 0 {
+		c.doc.Components = &Components{Schemas: c.schemas}
+	}
+	return c.doc, nil
+}
+
+type converter struct {
+	schema  *idl_ast.IDLSchema
+	doc     *Document
+	schemas map[string]*Schema
+	// names 是定义 FQN 到组件名称的映射。
+	names map[string]string
+	// operations 记录 "METHOD path" 对应的函数和生成的操作，用于检测冲突。
+	operations map[string]*operation
+	opIDs      map[string]int
+}
+
+type operation struct {
+	fqn string
+	op  *Operation
+}
+
+// componentNames 为所有 message 和 enum 分配组件名称。名称在整个 schema 中唯一时直接使用定义名，
+// 否则加上文件名前缀，例如 "base.Entity"。
+func componentNames(schema *idl_ast.IDLSchema) map[string]string {
+	counts := make(map[string]int)
+	var fqns []string
+	for i := range schema.Files {
+		defs := &schema.Files[i].Definitions
+		for _, m := range defs.Messages {
+			counts[m.Name]++
+			fqns = append(fqns, m.FullyQualifiedName)
+		}
+		for _, e := range defs.Enums {
+			counts[e.Name]++
+			fqns = append(fqns, e.FullyQualifiedName)
+		}
+	}
+	names := make(map[string]string, len(fqns))
+	for _, fqn := range fqns {
+		path, name, ok := idl_ast.SplitFQN(fqn)
+		if !ok {
+			continue
+		}
+		if counts[name] > 1 {
+			base := filepath.Base(path)
+			name = strings.TrimSuffix(base, filepath.Ext(base)) + "." + name
+		}
+		names[fqn] = name
+	}
+	return names
+}
+
+// convertService 转换 service 有效方法集中的所有函数，包括通过 extends 继承的函数。
+// 继承来的函数在祖先 service 中已经生成操作时，只把当前 service 追加到该操作的 tags 中。
+func (c *converter) convertService(svc *idl_ast.Service) error {
+	resolved, err := c.schema.ResolveService(svc.FullyQualifiedName)
+	if err != nil {
+		return err
+	}
+	tagged := false
+	for _, inherited := range resolved.Functions {
+		fn := inherited.Function
+		method, path, ok := httpRoute(fn.Annotations)
+		if !ok {
+			continue
+		}
+		key := strings.ToUpper(method) + " " + path
+		if existing, ok := c.operations[key]; ok {
+			if existing.fqn != fn.FullyQualifiedName {
+				return fmt.Errorf("operation %s is defined by both %s and %s", key, existing.fqn, fn.FullyQualifiedName)
+			}
+			existing.op.Tags = append(existing.op.Tags, svc.Name)
+			tagged = true
+			continue
+		}
+
+		op := c.convertFunction(svc, fn, method, path)
+		c.operations[key] = &operation{fqn: fn.FullyQualifiedName, op: op}
+		item := c.doc.Paths[path]
+		if item == nil {
+			item = &PathItem{}
+			c.doc.Paths[path] = item
+		}
+		item.set(method, op)
+		tagged = true
+	}
+	if tagged {
+		c.doc.Tags = append(c.doc.Tags, &Tag{Name: svc.Name, Description: commentText(svc.Comments)})
+	}
+	return nil
+}
+
+func (p *PathItem) set(method string, op *Operation) {
+	switch method {
+	case "get":
+		p.Get = op
+	case "post":
+		p.Post = op
+	case "put":
+		p.Put = op
+	case "delete":
+		p.Delete = op
+	case "patch":
+		p.Patch = op
+	case "head":
+		p.Head = op
+	case "options":
+		p.Options = op
+	}
+}
+
+// httpRoute 返回函数上第一个 api.xxx 注解的 HTTP 方法和 OpenAPI 形式的路径。
+func httpRoute(annos []idl_ast.Annotation) (method, path string, ok bool) {
+	for _, m := range httpMethods {
+		for _, anno := range annos {
+			if anno.Name != "api."+m {
+				continue
+			}
+			if route, err := anno.Value.StringValue(); err == nil && route != "" {
+				return m, convertPath(route), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// convertPath 把 Hertz 路由中的 :name 和 *name 转换为 OpenAPI 的 {name}。
+func convertPath(route string) string {
+	segments := strings.Split(route, "/")
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (c *converter) convertFunction(svc *idl_ast.Service, fn *idl_ast.Function, method, path string) *Operation {
+	op := &Operation{
+		Tags:        []string{svc.Name},
+		OperationID: c.operationID(svc, fn),
+		Responses:   make(map[string]*Response),
+	}
+	if desc := commentText(fn.Comments); desc != "" {
+		op.Summary, op.Description, _ = strings.Cut(desc, "\n")
+		op.Description = strings.TrimSpace(op.Description)
+	}
+
+	body := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	form := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	var rawBody *Schema
+	pathParams := make(map[string]bool)
+	addField := func(f *idl_ast.Field) {
+		in, name := fieldLocation(f, method)
+		required := f.Required == "required"
+		switch in {
+		case "query", "path", "header", "cookie":
+			param := &Parameter{
+				Name:        name,
+				In:          in,
+				Description: commentText(f.Comments),
+				Required:    required || in == "path",
+				Schema:      c.typeSchema(&f.Type),
+			}
+			if in == "path" {
+				pathParams[name] = true
+			}
+			op.Parameters = append(op.Parameters, param)
+		case "raw_body":
+			rawBody = &Schema{Type: "string", Format: "binary"}
+		case "form":
+			addProperty(form, name, c.fieldSchema(f), required)
+		default:
+			addProperty(body, name, c.fieldSchema(f), required)
+		}
+	}
+	for i := range fn.Parameters {
+		param := &fn.Parameters[i]
+		if msg := c.findMessage(&param.Type); msg != nil {
+			for j := range msg.Fields {
+				addField(&msg.Fields[j])
+			}
+		} else {
+			addField(param)
+		}
+	}
+
+	// 路由中出现但请求里没有声明的路径参数也要补上，否则文档不合法。
+	for _, seg := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(seg, "{"); ok {
+			name = strings.TrimSuffix(name, "}")
+			if !pathParams[name] {
+				op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+			}
+		}
+	}
+
+	content := make(map[string]*MediaType)
+	if len(body.Properties) > 0 {
+		content[jsonContentType] = &MediaType{Schema: body}
+	}
+	if len(form.Properties) > 0 {
+		content["application/x-www-form-urlencoded"] = &MediaType{Schema: form}
+	}
+	if rawBody != nil {
+		content["application/octet-stream"] = &MediaType{Schema: rawBody}
+	}
+	if len(content) > 0 {
+		op.RequestBody = &RequestBody{Content: content, Required: len(body.Required) > 0 || len(form.Required) > 0}
+	}
+
+	op.Responses["200"] = c.response("OK", &fn.ReturnType)
+	var defaults []*Schema
+	for i := range fn.Throws {
+		t := &fn.Throws[i]
+		if m := errorCodeRegex.FindStringSubmatch(t.Name); m != nil {
+			op.Responses[m[1]] = c.response(orDefault(commentText(t.Comments), t.Name), &t.Type)
+			continue
+		}
+		defaults = append(defaults, c.typeSchema(&t.Type))
+	}
+	switch len(defaults) {
+	case 0:
+	case 1:
+		op.Responses["default"] = &Response{Description: "Error", Content: map[string]*MediaType{jsonContentType: {Schema: defaults[0]}}}
+	default:
+		op.Responses["default"] = &Response{Description: "Error", Content: map[string]*MediaType{jsonContentType: {Schema: &Schema{OneOf: defaults}}}}
+	}
+	return op
+}
+
+func addProperty(obj *Schema, name string, prop *Schema, required bool) {
+	obj.Properties[name] = prop
+	if required {
+		obj.Required = append(obj.Required, name)
+	}
+}
+
+// operationID 默认使用函数名，多个 service 中有同名函数时加上 service 名前缀。
+func (c *converter) operationID(svc *idl_ast.Service, fn *idl_ast.Function) string {
+	id := fn.Name
+	if c.opIDs[id] > 0 {
+		id = svc.Name + "_" + fn.Name
+	}
+	c.opIDs[id]++
+	return id
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func (c *converter) response(description string, t *idl_ast.Type) *Response {
+	resp := &Response{Description: description}
+	if t.Name != "void" && t.Name != "" {
+		resp.Content = map[string]*MediaType{jsonContentType: {Schema: c.typeSchema(t)}}
+	}
+	return resp
+}
+
+// fieldLocation 根据字段的 api.xxx 注解返回它在 HTTP 请求中的位置和名称。
+func fieldLocation(f *idl_ast.Field, method string) (in, name string) {
+	for _, anno := range f.Annotations {
+		loc, ok := strings.CutPrefix(anno.Name, "api.")
+		if !ok {
+			continue
+		}
+		switch loc {
+		case "query", "path", "header", "cookie", "body", "form", "raw_body":
+			name, _ := anno.Value.StringValue()
+			return loc, orDefault(name, f.Name)
+		}
+	}
+	switch method {
+	case "get", "delete", "head":
+		return "query", f.Name
+	default:
+		return "body", f.Name
+	}
+}
+
+// propertyName 返回字段在 JSON 中的名称，api.body 注解可以重命名字段。
+func propertyName(f *idl_ast.Field) string {
+	for _, anno := range f.Annotations {
+		if anno.Name == "api.body" {
+			if name, err := anno.Value.StringValue(); err == nil && name != "" {
+				return name
+			}
+		}
+	}
+	return f.Name
+}
+
+func (c *converter) fieldSchema(f *idl_ast.Field) *Schema {
+	s := c.typeSchema(&f.Type)
+	if desc := commentText(f.Comments); desc != "" {
+		if s.Ref != "" {
+			// 在 OpenAPI 3.0 中 $ref 的兄弟属性会被忽略。
+			return s
+		}
+		s.Description = desc
+	}
+	return s
+}
+
+// resolve 展开 typedef，返回最终的类型。
+func (c *converter) resolve(t *idl_ast.Type) *idl_ast.Type {
+	if resolved, err := c.schema.ResolveType(t); err == nil {
+		return resolved.Type
+	}
+	return t
+}
+
+func (c *converter) findMessage(t *idl_ast.Type) *idl_ast.Message {
+	t = c.resolve(t)
+	if t.FullyQualifiedName == "" {
+		return nil
+	}
+	if messages := c.schema.FindMessagesByFQN(t.FullyQualifiedName); len(messages) > 0 {
+		return messages[0]
+	}
+	return nil
+}
+
+func (c *converter) typeSchema(t *idl_ast.Type) *Schema {
+	t = c.resolve(t)
+	switch t.Name {
+	case "bool":
+		return &Schema{Type: "boolean"}
+	case "byte", "i8", "i16", "i32", "int32", "sint32", "sfixed32":
+		return &Schema{Type: "integer", Format: "int32"}
+	case "i64", "int64", "sint64", "sfixed64":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "uint32", "fixed32", "uint64", "fixed64":
+		return &Schema{Type: "integer"}
+	case "double":
+		return &Schema{Type: "number", Format: "double"}
+	case "float":
+		return &Schema{Type: "number", Format: "float"}
+	case "string":
+		return &Schema{Type: "string"}
+	case "binary", "bytes":
+		return &Schema{Type: "string", Format: "byte"}
+	case "list", "set":
+		return &Schema{Type: "array", Items: c.typeSchema(t.ValueType), UniqueItems: t.Name == "set"}
+	case "map":
+		return &Schema{Type: "object", AdditionalProperties: c.typeSchema(t.ValueType)}
+	}
+	if t.FullyQualifiedName == "" {
+		return &Schema{}
+	}
+	if name, ok := c.component(t.FullyQualifiedName); ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// component 返回 fqn 对应的组件名称，并在第一次遇到时生成组件 schema。
+func (c *converter) component(fqn string) (string, bool) {
+	name, ok := c.names[fqn]
+	if !ok {
+		return "", false
+	}
+	if _, done := c.schemas[name]; done {
+		return name, true
+	}
+	// 先占位，防止递归引用导致死循环。
+	s := &Schema{}
+	c.schemas[name] = s
+	if enums := c.schema.FindEnumsByFQN(fqn); len(enums) > 0 {
+		*s = *enumSchema(enums[0])
+	} else if messages := c.schema.FindMessagesByFQN(fqn); len(messages) > 0 {
+		*s = *c.messageSchema(messages[0])
+	}
+	return name, true
+}
+
+func enumSchema(e *idl_ast.Enum) *Schema {
+	s := &Schema{Type: "integer", Format: "int32", Description: commentText(e.Comments)}
+	for _, v := range e.Values {
+		s.Enum = append(s.Enum, v.Value)
+		s.XEnumVarNames = append(s.XEnumVarNames, v.Name)
+	}
+	return s
+}
+
+func (c *converter) messageSchema(m *idl_ast.Message) *Schema {
+	s := &Schema{Type: "object", Description: commentText(m.Comments), Properties: make(map[string]*Schema)}
+	for i := range m.Fields {
+		f := &m.Fields[i]
+		addProperty(s, propertyName(f), c.fieldSchema(f), f.Required == "required")
+	}
+	if m.Type == "union" {
+		one := 1
+		s.MaxProperties = &one
+	}
+	return s
+}
+
+// commentText 去掉注释标记，返回注释的正文。
+func commentText(comments []idl_ast.Comment) string {
+	var lines []string
+	for _, comment := range comments {
+		text := strings.TrimSpace(comment.Text)
+		switch {
+		case strings.HasPrefix(text, "/*"):
+			text = strings.TrimSuffix(strings.TrimLeft(text, "/*"), "*/")
+			for _, line := range strings.Split(text, "\n") {
+				line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+				if line != "" {
+					lines = append(lines, line)
+				}
+			}
+		case strings.HasPrefix(text, "//"):
+			lines = append(lines, strings.TrimSpace(strings.TrimLeft(text, "/")))
+		case strings.HasPrefix(text, "#"):
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(text, "#")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
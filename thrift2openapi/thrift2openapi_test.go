@@ -0,0 +1,165 @@
+package thrift2openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/thriftparser"
+)
+
+var testFiles = map[string][]byte{
+	"common/base.thrift": []byte(`
+// Status 表示用户状态
+enum Status {
+  ACTIVE = 1,
+  DISABLED = 2
+}
+
+exception NotFound {
+  1: string message
+}
+`),
+	"user.thrift": []byte(`
+include "common/base.thrift"
+
+typedef i64 UserID
+
+struct User {
+  1: required UserID id
+  // 用户名
+  2: string name (api.body = "user_name")
+  3: base.Status status
+  4: list<User> friends
+}
+
+struct GetUserRequest {
+  1: required UserID id (api.path = "id")
+  2: string fields (api.query = "fields")
+  3: string token (api.header = "X-Token")
+}
+
+struct UpdateUserRequest {
+  1: required UserID id (api.path = "id")
+  2: required string name (api.body = "name")
+  3: base.Status status
+}
+
+// UserService 管理用户
+service UserService {
+  // 获取用户
+  // 根据 ID 查询
+  User GetUser(1: GetUserRequest req) throws (1: base.NotFound error404) (api.get = "/users/:id")
+  User UpdateUser(1: UpdateUserRequest req) throws (1: base.NotFound e) (api.put = "/users/:id")
+  void DeleteUser(1: UserID id) (api.delete = "/users/:id")
+  void Internal()
+}
+`),
+}
+
+func TestConvert(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", testFiles)
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	doc, err := Convert(schema, WithTitle("User API"), WithVersion("2.0.0"))
+	require.NoError(t, err)
+	assert.Equal(t, "User API", doc.Info.Title)
+	require.Len(t, doc.Paths, 1)
+	require.Len(t, doc.Tags, 1)
+	assert.Equal(t, "UserService 管理用户", doc.Tags[0].Description)
+
+	item := doc.Paths["/users/{id}"]
+	require.NotNil(t, item)
+
+	get := item.Get
+	require.NotNil(t, get)
+	assert.Equal(t, "GetUser", get.OperationID)
+	assert.Equal(t, "获取用户", get.Summary)
+	assert.Equal(t, "根据 ID 查询", get.Description)
+	require.Len(t, get.Parameters, 3)
+	assert.Equal(t, &Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}}, get.Parameters[0])
+	assert.Equal(t, "query", get.Parameters[1].In)
+	assert.Equal(t, "X-Token", get.Parameters[2].Name)
+	assert.Nil(t, get.RequestBody)
+	assert.Equal(t, "#/components/schemas/User", get.Responses["200"].Content[jsonContentType].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/NotFound", get.Responses["404"].Content[jsonContentType].Schema.Ref)
+
+	put := item.Put
+	require.NotNil(t, put)
+	require.NotNil(t, put.RequestBody)
+	body := put.RequestBody.Content[jsonContentType].Schema
+	assert.Equal(t, []string{"name"}, body.Required)
+	assert.Equal(t, "#/components/schemas/Status", body.Properties["status"].Ref)
+	assert.Contains(t, put.Responses, "default")
+
+	del := item.Delete
+	require.NotNil(t, del)
+	assert.Equal(t, "id", del.Parameters[0].Name)
+	assert.Nil(t, del.Responses["200"].Content)
+
+	user := doc.Components.Schemas["User"]
+	require.NotNil(t, user)
+	assert.Equal(t, []string{"id"}, user.Required)
+	assert.Equal(t, "用户名", user.Properties["user_name"].Description)
+	assert.Equal(t, "#/components/schemas/User", user.Properties["friends"].Items.Ref)
+
+	status := doc.Components.Schemas["Status"]
+	require.NotNil(t, status)
+	assert.Equal(t, []any{1, 2}, status.Enum)
+	assert.Equal(t, []string{"ACTIVE", "DISABLED"}, status.XEnumVarNames)
+	assert.NotContains(t, doc.Components.Schemas, "GetUserRequest")
+
+	out, err := doc.YAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "openapi: 3.0.3")
+	_, err = doc.JSON()
+	require.NoError(t, err)
+}
+
+func TestConvert_DuplicateRoute(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"a.thrift": []byte(`
+service A {
+  void Ping() (api.get = "/ping")
+  void Ping2() (api.get = "/ping")
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	_, err = Convert(schema)
+	assert.EqualError(t, err, "operation GET /ping is defined by both a.thrift#A.Ping and a.thrift#A.Ping2")
+}
+
+func TestConvert_InheritedFunctions(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"common/base.thrift": []byte(`
+service BaseService {
+  string Ping() (api.get = "/ping")
+}
+`),
+		"main.thrift": []byte(`
+include "common/base.thrift"
+
+service UserService extends base.BaseService {
+  string GetUser() (api.get = "/user")
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	doc, err := Convert(schema)
+	require.NoError(t, err)
+	require.Contains(t, doc.Paths, "/ping")
+	require.Contains(t, doc.Paths, "/user")
+	assert.ElementsMatch(t, []string{"BaseService", "UserService"}, doc.Paths["/ping"].Get.Tags)
+	assert.Equal(t, []string{"UserService"}, doc.Paths["/user"].Get.Tags)
+	assert.Len(t, doc.Tags, 2)
+}
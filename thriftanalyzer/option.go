@@ -3,7 +3,8 @@ package thriftanalyzer
 // analysisOptions holds the internal configuration for the analyzer.
 // It's not exported to keep it private to the package.
 type analysisOptions struct {
-	scopes []string
+	scopes       []string
+	includePaths []string
 }
 
 // Option is the functional option type.
@@ -38,3 +39,12 @@ func WithAllScopes() Option {
 		opts.scopes = []string{"*"}
 	}
 }
+
+// WithIncludePaths sets the include search paths, like `thrift -I idl/ -I third_party/`.
+// An include is first resolved relative to the including file and then against
+// each directory in order. Directories use the same form as the keys of the files map.
+func WithIncludePaths(dirs ...string) Option {
+	return func(opts *analysisOptions) {
+		opts.includePaths = append(opts.includePaths, dirs...)
+	}
+}
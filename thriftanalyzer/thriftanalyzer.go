@@ -2,11 +2,15 @@ package thriftanalyzer
 
 import (
 	"fmt"
+	"io/fs"
 	"path/filepath"
 
 	"github.com/joyme123/thrift-ls/lsp/lsputils"
 	"github.com/joyme123/thrift-ls/parser"
 	"go.lsp.dev/uri"
+
+	"github.com/Skyenought/idlanalyzer/idlfs"
+	"github.com/Skyenought/idlanalyzer/thriftparser"
 )
 
 // AnalyzeThriftDependencies 分析 Thrift 文件的依赖关系，并返回一个包含丰富信息的图。
@@ -16,6 +20,28 @@ func AnalyzeThriftDependencies(mainIdlPath string, files map[string][]byte, opti
 	for _, option := range options {
 		option(opts)
 	}
+	return analyze(mainIdlPath, files, opts)
+}
+
+// AnalyzeThriftDependenciesFS 与 AnalyzeThriftDependencies 相同，但从 fsys 的 root 目录读取所有 .thrift 文件，
+// fsys 可以是 embed.FS、zip.Reader 或 idlfs 提供的文件系统。图中的路径形如 "/<root>/<相对路径>"，
+// mainIdlPath 和 WithIncludePaths 中的相对路径都相对于 root。
+func AnalyzeThriftDependenciesFS(fsys fs.FS, root, mainIdlPath string, options ...Option) (*RichDependencyGraph, error) {
+	opts := newDefaultOptions()
+	for _, option := range options {
+		option(opts)
+	}
+	files, logicalRoot, err := idlfs.ReadRootedThriftFiles(fsys, root, opts.includePaths)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(mainIdlPath) {
+		mainIdlPath = filepath.Join(logicalRoot, mainIdlPath)
+	}
+	return analyze(mainIdlPath, files, opts)
+}
+
+func analyze(mainIdlPath string, files map[string][]byte, opts *analysisOptions) (*RichDependencyGraph, error) {
 	pegParser := &parser.PEGParser{}
 	asts := make(map[uri.URI]*parser.Document)
 
@@ -24,6 +50,10 @@ func AnalyzeThriftDependencies(mainIdlPath string, files map[string][]byte, opti
 		cleanedFiles[filepath.Clean(path)] = content
 	}
 	mainIdlPath = filepath.Clean(mainIdlPath)
+	includePaths := make([]string, len(opts.includePaths))
+	for i, dir := range opts.includePaths {
+		includePaths[i] = filepath.Clean(dir)
+	}
 
 	graph := &RichDependencyGraph{
 		Nodes:          make(map[string]*FileNode),
@@ -67,8 +97,9 @@ func AnalyzeThriftDependencies(mainIdlPath string, files map[string][]byte, opti
 			}
 
 			rawPath := include.Path.Value.Text
-			targetURI := lsputils.IncludeURI(sourceURI, rawPath)
-			targetPath := targetURI.Filename()
+			targetPath, _ := thriftparser.ResolveInclude(sourcePath, rawPath, includePaths, func(path string) bool {
+				return graph.Nodes[path] != nil
+			})
 
 			edge := &DependencyEdge{
 				SourcePath:     sourcePath,
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -325,3 +326,51 @@ func ExampleAnalyzeThriftDependencies_withOptions() {
 	// types.thrift 节点收集到的命名空间数量: 2
 	// 第一个命名空间的 Scope: java
 }
+
+func TestAnalyzeThriftDependencies_IncludePaths(t *testing.T) {
+	mainPath := filepath.Clean("/repo/idl/service/main.thrift")
+	basePath := filepath.Clean("/repo/third_party/base.thrift")
+	localPath := filepath.Clean("/repo/idl/service/local.thrift")
+	files := map[string][]byte{
+		mainPath: []byte(`
+include "base.thrift"
+include "local.thrift"
+include "missing.thrift"
+`),
+		basePath:  []byte(`namespace go third_party.base`),
+		localPath: []byte(`namespace go service.local`),
+	}
+
+	graph, err := AnalyzeThriftDependencies(mainPath, files, WithIncludePaths("/repo/idl", "/repo/third_party"))
+	require.NoError(t, err)
+
+	edges := graph.Nodes[mainPath].Includes
+	require.Len(t, edges, 3)
+	assert.Equal(t, basePath, edges[0].TargetPath)
+	assert.False(t, edges[0].IsBroken)
+	assert.Equal(t, localPath, edges[1].TargetPath)
+	assert.False(t, edges[1].IsBroken)
+	assert.Equal(t, filepath.Clean("/repo/idl/service/missing.thrift"), edges[2].TargetPath)
+	assert.True(t, edges[2].IsBroken)
+	require.Len(t, graph.Nodes[basePath].IncludedBy, 1)
+
+	// 不设置 include 路径时，base.thrift 无法找到。
+	graph, err = AnalyzeThriftDependencies(mainPath, files)
+	require.NoError(t, err)
+	assert.True(t, graph.Nodes[mainPath].Includes[0].IsBroken)
+}
+
+func TestAnalyzeThriftDependenciesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bundle/idl/main.thrift":         {Data: []byte(`include "base.thrift"`)},
+		"bundle/third_party/base.thrift": {Data: []byte(`namespace go third_party.base`)},
+	}
+
+	graph, err := AnalyzeThriftDependenciesFS(fsys, "bundle", "idl/main.thrift", WithIncludePaths("third_party"))
+	require.NoError(t, err)
+	mainPath := filepath.Clean("/bundle/idl/main.thrift")
+	assert.Equal(t, mainPath, graph.EntryPointPath)
+	require.Len(t, graph.Nodes[mainPath].Includes, 1)
+	assert.Equal(t, filepath.Clean("/bundle/third_party/base.thrift"), graph.Nodes[mainPath].Includes[0].TargetPath)
+	assert.False(t, graph.Nodes[mainPath].Includes[0].IsBroken)
+}
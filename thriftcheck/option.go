@@ -0,0 +1,23 @@
+package thriftcheck
+
+// checkOptions holds the internal configuration for the checker.
+type checkOptions struct {
+	includePaths []string
+}
+
+// Option is the functional option type.
+type Option func(*checkOptions)
+
+// newDefaultOptions creates the default internal configuration.
+func newDefaultOptions() *checkOptions {
+	return &checkOptions{}
+}
+
+// WithIncludePaths sets the include search paths, like `thrift -I idl/ -I third_party/`.
+// An include is first resolved relative to the including file and then against
+// each directory in order. Directories use the same form as the keys of the sources map.
+func WithIncludePaths(dirs ...string) Option {
+	return func(opts *checkOptions) {
+		opts.includePaths = append(opts.includePaths, dirs...)
+	}
+}
@@ -4,23 +4,55 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/joyme123/protocol"
 	"github.com/joyme123/thrift-ls/lsp/cache"
 	"github.com/joyme123/thrift-ls/lsp/diagnostic"
+	"github.com/joyme123/thrift-ls/lsp/lsputils"
+	"github.com/joyme123/thrift-ls/parser"
 	"go.lsp.dev/uri"
+
+	"github.com/Skyenought/idlanalyzer/idlfs"
+	"github.com/Skyenought/idlanalyzer/thriftparser"
 )
 
-func ThriftSyntaxCheck(ctx context.Context, sources map[string][]byte) (map[string][]protocol.Diagnostic, error) {
+func ThriftSyntaxCheck(ctx context.Context, sources map[string][]byte, options ...Option) (map[string][]protocol.Diagnostic, error) {
+	opts := newDefaultOptions()
+	for _, option := range options {
+		option(opts)
+	}
+	return check(ctx, sources, opts)
+}
+
+// ThriftSyntaxCheckFS 与 ThriftSyntaxCheck 相同，但检查 fsys 中 root 目录下的所有 .thrift 文件，
+// fsys 可以是 embed.FS、zip.Reader 或 idlfs 提供的文件系统。返回结果的键形如 "/<root>/<相对路径>"，
+// WithIncludePaths 中的相对路径相对于 root。
+func ThriftSyntaxCheckFS(ctx context.Context, fsys fs.FS, root string, options ...Option) (map[string][]protocol.Diagnostic, error) {
+	opts := newDefaultOptions()
+	for _, option := range options {
+		option(opts)
+	}
+	sources, _, err := idlfs.ReadRootedThriftFiles(fsys, root, opts.includePaths)
+	if err != nil {
+		return nil, err
+	}
+	return check(ctx, sources, opts)
+}
+
+func check(ctx context.Context, sources map[string][]byte, opts *checkOptions) (map[string][]protocol.Diagnostic, error) {
 	if len(sources) == 0 {
 		return make(map[string][]protocol.Diagnostic), nil
 	}
 
+	contents, rewrites := resolveIncludes(sources, opts.includePaths)
 	fileChanges := make([]*cache.FileChange, 0, len(sources))
 	fileURIs := make([]uri.URI, 0, len(sources))
 
-	for filename, content := range sources {
+	for filename, content := range contents {
 		fileURI := uri.File(filename)
 		change := &cache.FileChange{
 			URI:     fileURI,
@@ -52,6 +84,9 @@ func ThriftSyntaxCheck(ctx context.Context, sources map[string][]byte) (map[stri
 
 		for u, diags := range result {
 			filename := u.Filename()
+			for i := range diags {
+				diags[i].Range = rewrites[filename].restore(diags[i].Range)
+			}
 			allDiagnostics[filename] = append(allDiagnostics[filename], diags...)
 		}
 	}
@@ -63,3 +98,104 @@ func ThriftSyntaxCheck(ctx context.Context, sources map[string][]byte) (map[stri
 
 	return allDiagnostics, finalErr
 }
+
+// includeRewrite 记录一处被改写的 include 路径，位置与 protocol.Position 一样从 0 开始、按 rune 计数。
+type includeRewrite struct {
+	line     uint32
+	start    uint32 // 路径第一个字符所在的列（不含引号）
+	oldWidth uint32
+	newWidth uint32
+}
+
+type includeRewrites []includeRewrite
+
+// restore 把改写后源码中的范围映射回原始源码：改写行上路径之后的列按长度差平移，落在路径内的列截断到原路径末尾。
+func (rs includeRewrites) restore(r protocol.Range) protocol.Range {
+	r.Start = rs.restorePosition(r.Start)
+	r.End = rs.restorePosition(r.End)
+	return r
+}
+
+func (rs includeRewrites) restorePosition(pos protocol.Position) protocol.Position {
+	for _, rw := range rs {
+		if pos.Line != rw.line || pos.Character <= rw.start {
+			continue
+		}
+		switch {
+		case pos.Character >= rw.start+rw.newWidth:
+			pos.Character = pos.Character - rw.newWidth + rw.oldWidth
+		case pos.Character > rw.start+rw.oldWidth:
+			pos.Character = rw.start + rw.oldWidth
+		}
+	}
+	return pos
+}
+
+// resolveIncludes 按 include 路径预先解析 include。thrift-ls 的检查器只会相对于当前文件查找 include，
+// 因此对于需要通过 include 路径才能找到的 include，把交给检查器的源码副本中的路径改写为
+// 从当前文件到目标文件的相对路径，查找规则与 thriftparser.ResolveInclude 一致。
+// 改写不会增加文件，检查器看到的是真实的 include 图；返回的 includeRewrites 用于把诊断位置映射回原始源码。
+func resolveIncludes(sources map[string][]byte, includePaths []string) (map[string][]byte, map[string]includeRewrites) {
+	contents := make(map[string][]byte, len(sources))
+	rewrites := make(map[string]includeRewrites)
+	if len(includePaths) == 0 {
+		for filename, content := range sources {
+			contents[uri.File(filename).Filename()] = content
+		}
+		return contents, rewrites
+	}
+	files := make(map[string][]byte, len(sources))
+	for filename, content := range sources {
+		files[uri.File(filename).Filename()] = content
+	}
+	dirs := make([]string, len(includePaths))
+	for i, dir := range includePaths {
+		dirs[i] = uri.File(dir).Filename()
+	}
+	exists := func(path string) bool {
+		_, ok := files[path]
+		return ok
+	}
+
+	pegParser := &parser.PEGParser{}
+	for filename, content := range files {
+		contents[filename] = content
+		doc, _ := pegParser.Parse(filename, content)
+		if doc == nil {
+			continue
+		}
+		var patched []byte
+		offset := 0
+		for _, inc := range doc.Includes {
+			if inc.Path == nil || inc.Path.BadNode || inc.Path.Value == nil {
+				continue
+			}
+			value := inc.Path.Value
+			if exists(lsputils.IncludeURI(uri.File(filename), value.Text).Filename()) {
+				continue
+			}
+			target, ok := thriftparser.ResolveInclude(filename, value.Text, dirs, exists)
+			if !ok {
+				continue
+			}
+			rel, err := filepath.Rel(filepath.Dir(filename), target)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			patched = append(patched, content[offset:value.StartPos.Offset]...)
+			patched = append(patched, rel...)
+			offset = value.EndPos.Offset
+			rewrites[filename] = append(rewrites[filename], includeRewrite{
+				line:     uint32(value.StartPos.Line - 1),
+				start:    uint32(value.StartPos.Col - 1),
+				oldWidth: uint32(value.EndPos.Col - value.StartPos.Col),
+				newWidth: uint32(utf8.RuneCountInString(rel)),
+			})
+		}
+		if offset > 0 {
+			contents[filename] = append(patched, content[offset:]...)
+		}
+	}
+	return contents, rewrites
+}
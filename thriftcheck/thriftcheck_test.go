@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func Test_Check(t *testing.T) {
@@ -90,3 +91,135 @@ func loadThriftFilesFromDir(rootDir string) (map[string][]byte, error) {
 
 	return files, nil
 }
+
+func TestThriftSyntaxCheck_IncludePaths(t *testing.T) {
+	mainPath := filepath.Clean("/repo/idl/service/main.thrift")
+	basePath := filepath.Clean("/repo/third_party/base.thrift")
+	sources := map[string][]byte{
+		mainPath: []byte(`include "base.thrift"
+
+struct User {
+  1: base.Base base
+}
+`),
+		basePath: []byte(`include "common/types.thrift"
+
+struct Base {
+  1: types.ID id
+}
+`),
+		filepath.Clean("/repo/third_party/common/types.thrift"): []byte(`typedef i64 ID
+`),
+	}
+
+	diagnostics, err := ThriftSyntaxCheck(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("ThriftSyntaxCheck() error = %v", err)
+	}
+	if len(diagnostics[mainPath]) == 0 {
+		t.Fatalf("expected diagnostics for unresolved include in %s", mainPath)
+	}
+
+	diagnostics, err = ThriftSyntaxCheck(context.Background(), sources, WithIncludePaths("/repo/idl", "/repo/third_party"))
+	if err != nil {
+		t.Fatalf("ThriftSyntaxCheck() error = %v", err)
+	}
+	for filename, diags := range diagnostics {
+		if _, ok := sources[filename]; !ok {
+			t.Errorf("unexpected diagnostics for unknown file %s", filename)
+		}
+		for _, diag := range diags {
+			t.Errorf("%s: unexpected diagnostic %q", filename, diag.Message)
+		}
+	}
+}
+
+func TestThriftSyntaxCheck_IncludePathsSiblingInclude(t *testing.T) {
+	mainPath := filepath.Clean("/repo/idl/main.thrift")
+	sources := map[string][]byte{
+		mainPath: []byte(`include "x/base.thrift"
+
+struct User {
+  1: base.Base base
+}
+`),
+		// common.thrift 与 base.thrift 位于同一目录，该目录本身不是 include 路径。
+		filepath.Clean("/repo/third_party/x/base.thrift"): []byte(`include "common.thrift"
+
+struct Base {
+  1: common.ID id
+}
+`),
+		filepath.Clean("/repo/third_party/x/common.thrift"): []byte(`typedef i64 ID
+`),
+	}
+
+	diagnostics, err := ThriftSyntaxCheck(context.Background(), sources, WithIncludePaths("/repo/third_party"))
+	if err != nil {
+		t.Fatalf("ThriftSyntaxCheck() error = %v", err)
+	}
+	for filename, diags := range diagnostics {
+		for _, diag := range diags {
+			t.Errorf("%s: unexpected diagnostic %q", filename, diag.Message)
+		}
+	}
+}
+
+func TestThriftSyntaxCheck_IncludePathsCycle(t *testing.T) {
+	mainPath := filepath.Clean("/repo/idl/main.thrift")
+	basePath := filepath.Clean("/repo/third_party/base.thrift")
+	sources := map[string][]byte{
+		mainPath: []byte(`include "base.thrift" // via -I
+`),
+		basePath: []byte(`include "main.thrift"
+`),
+	}
+
+	diagnostics, err := ThriftSyntaxCheck(context.Background(), sources, WithIncludePaths("/repo/idl", "/repo/third_party"))
+	if err != nil {
+		t.Fatalf("ThriftSyntaxCheck() error = %v", err)
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected cycle diagnostics for both files, got %v", diagnostics)
+	}
+	// 诊断范围对应原始源码中的 include 语句（包括其后的空白），而不是改写后的路径。
+	for filename, want := range map[string]uint32{mainPath: 22, basePath: 21} {
+		diags := diagnostics[filename]
+		if len(diags) == 0 {
+			t.Fatalf("%s: expected cycle diagnostics", filename)
+		}
+		for _, diag := range diags {
+			if r := diag.Range; r.Start.Line != 0 || r.Start.Character != 0 || r.End.Line != 0 || r.End.Character != want {
+				t.Errorf("%s: diagnostic range = %+v, want 0:0-0:%d", filename, r, want)
+			}
+		}
+	}
+}
+
+func TestThriftSyntaxCheckFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bundle/idl/main.thrift": {Data: []byte(`include "base.thrift"
+
+struct User {
+  1: base.Base base
+}
+`)},
+		"bundle/third_party/base.thrift": {Data: []byte(`struct Base {}
+`)},
+		"bundle/bad.thrift": {Data: []byte(`struct Bad {
+  1: Missing m
+}
+`)},
+	}
+
+	diagnostics, err := ThriftSyntaxCheckFS(context.Background(), fsys, "bundle", WithIncludePaths("third_party"))
+	if err != nil {
+		t.Fatalf("ThriftSyntaxCheckFS() error = %v", err)
+	}
+	if diags := diagnostics[filepath.Clean("/bundle/idl/main.thrift")]; len(diags) > 0 {
+		t.Errorf("unexpected diagnostics for main.thrift: %v", diags)
+	}
+	if len(diagnostics[filepath.Clean("/bundle/bad.thrift")]) == 0 {
+		t.Errorf("expected diagnostics for bad.thrift")
+	}
+}
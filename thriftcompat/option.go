@@ -0,0 +1,36 @@
+package thriftcompat
+
+// compareOptions holds the internal configuration for Compare.
+type compareOptions struct {
+	failOn  Severity
+	ignored map[ChangeKind]struct{}
+}
+
+// Option is the functional option type.
+type Option func(*compareOptions)
+
+// newDefaultOptions creates the default internal configuration.
+func newDefaultOptions() *compareOptions {
+	return &compareOptions{
+		failOn:  SeverityError, // Only wire-incompatible changes fail the check by default.
+		ignored: make(map[ChangeKind]struct{}),
+	}
+}
+
+// WithFailOn sets the minimum severity that makes Compare return the report as an error.
+// The default is SeverityError. Use SeverityWarning for a stricter gate.
+func WithFailOn(severity Severity) Option {
+	return func(opts *compareOptions) {
+		opts.failOn = severity
+	}
+}
+
+// WithIgnoredKinds drops the given kinds of changes from the report entirely.
+// Example: thriftcompat.WithIgnoredKinds(thriftcompat.FieldRenamed)
+func WithIgnoredKinds(kinds ...ChangeKind) Option {
+	return func(opts *compareOptions) {
+		for _, kind := range kinds {
+			opts.ignored[kind] = struct{}{}
+		}
+	}
+}
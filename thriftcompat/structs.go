@@ -0,0 +1,124 @@
+package thriftcompat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+// Severity 表示一项变更对兼容性的影响程度。
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // 线上不兼容，新旧两端互通时会出错
+	SeverityWarning Severity = "warning" // 线上兼容，但可能破坏源码兼容性或业务语义
+	SeverityInfo    Severity = "info"    // 兼容的变更，例如新增可选字段
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ChangeKind 标识检测到的变更种类。
+type ChangeKind string
+
+const (
+	DefinitionAdded       ChangeKind = "definition-added"
+	DefinitionRemoved     ChangeKind = "definition-removed"
+	DefinitionKindChanged ChangeKind = "definition-kind-changed" // 例如 union 变成 struct，或 struct 变成 enum
+
+	FieldAdded               ChangeKind = "field-added"
+	FieldRemoved             ChangeKind = "field-removed"
+	FieldTypeChanged         ChangeKind = "field-type-changed"
+	FieldRequirednessChanged ChangeKind = "field-requiredness-changed"
+	FieldRenamed             ChangeKind = "field-renamed"
+	FieldDefaultChanged      ChangeKind = "field-default-changed"
+
+	EnumValueAdded   ChangeKind = "enum-value-added"
+	EnumValueRemoved ChangeKind = "enum-value-removed"
+	EnumValueChanged ChangeKind = "enum-value-changed" // 同名成员的数值发生变化
+	EnumValueRenamed ChangeKind = "enum-value-renamed" // 同一个数值换了名字
+
+	FunctionAdded             ChangeKind = "function-added"
+	FunctionRemoved           ChangeKind = "function-removed"
+	FunctionReturnTypeChanged ChangeKind = "function-return-type-changed"
+	FunctionOnewayChanged     ChangeKind = "function-oneway-changed"
+	ServiceExtendsChanged     ChangeKind = "service-extends-changed"
+
+	TypedefTypeChanged   ChangeKind = "typedef-type-changed"
+	ConstantTypeChanged  ChangeKind = "constant-type-changed"
+	ConstantValueChanged ChangeKind = "constant-value-changed"
+)
+
+// Change 描述两个 schema 版本之间的一项变更。
+type Change struct {
+	Severity Severity   `json:"severity"`
+	Kind     ChangeKind `json:"kind"`
+	// FQN 是发生变更的元素，例如 "main.thrift#User"、"main.thrift#User.name"
+	// 或 "main.thrift#UserService.getUser.req"。
+	FQN     string `json:"fqn"`
+	Message string `json:"message"`
+	// File 和 Location 指向新版本中的元素；元素被删除时指向旧版本中的位置。
+	File     string            `json:"file"`
+	Location *idl_ast.Location `json:"location,omitempty"`
+}
+
+func (c Change) String() string {
+	pos := c.File
+	if c.Location != nil {
+		pos = fmt.Sprintf("%s:%d:%d", c.File, c.Location.Start.Line, c.Location.Start.Column)
+	}
+	return fmt.Sprintf("[%s] %s %s: %s", c.Severity, pos, c.FQN, c.Message)
+}
+
+// Report 是一次兼容性检查的结果。当存在达到失败阈值的变更时，
+// Compare 会同时把它作为 error 返回。
+type Report struct {
+	Changes []Change `json:"changes"`
+
+	failOn Severity
+}
+
+// Error 列出所有达到失败阈值的变更。
+func (r *Report) Error() string {
+	failing := r.Filter(r.failOn)
+	if len(failing) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Thrift compatibility check found %d breaking change(s):\n", len(failing)))
+	for _, c := range failing {
+		sb.WriteString(fmt.Sprintf(" - %s\n", c))
+	}
+	return sb.String()
+}
+
+// Filter 返回严重程度不低于 min 的变更。
+func (r *Report) Filter(min Severity) []Change {
+	var res []Change
+	for _, c := range r.Changes {
+		if c.Severity.rank() >= min.rank() {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// HasBreakingChanges 检查结果中是否包含 SeverityError 级别的变更。
+func (r *Report) HasBreakingChanges() bool {
+	return len(r.Filter(SeverityError)) > 0
+}
+
+// IsEmpty 检查两个版本之间是否没有任何变更。
+func (r *Report) IsEmpty() bool {
+	return len(r.Changes) == 0
+}
@@ -0,0 +1,396 @@
+package thriftcompat
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+// Compare 比较同一个 Thrift 项目的两个版本，按 FQN 匹配定义、按 ID 匹配字段，
+// 返回所有检测到的变更。当存在严重程度达到失败阈值（默认为 SeverityError）的变更时，
+// 返回的 error 就是 *Report 本身，方便直接作为 CI 检查的结果。
+func Compare(oldSchema, newSchema *idl_ast.IDLSchema, options ...Option) (*Report, error) {
+	if oldSchema == nil || newSchema == nil {
+		return nil, errors.New("both schemas must be non-nil")
+	}
+	opts := newDefaultOptions()
+	for _, option := range options {
+		option(opts)
+	}
+
+	c := &comparer{oldSchema: oldSchema, newSchema: newSchema, opts: opts}
+	c.compareDefinitions()
+
+	report := &Report{Changes: c.changes, failOn: opts.failOn}
+	if len(report.Filter(opts.failOn)) > 0 {
+		return report, report
+	}
+	return report, nil
+}
+
+type comparer struct {
+	oldSchema *idl_ast.IDLSchema
+	newSchema *idl_ast.IDLSchema
+	opts      *compareOptions
+	changes   []Change
+}
+
+func (c *comparer) add(severity Severity, kind ChangeKind, fqn, file string, loc *idl_ast.Location, format string, args ...any) {
+	if _, ok := c.opts.ignored[kind]; ok {
+		return
+	}
+	c.changes = append(c.changes, Change{
+		Severity: severity,
+		Kind:     kind,
+		FQN:      fqn,
+		Message:  fmt.Sprintf(format, args...),
+		File:     file,
+		Location: loc,
+	})
+}
+
+// definition 是某个文件中的一个顶层定义。
+type definition struct {
+	fqn  string
+	file string
+	node any
+}
+
+func (d definition) kind() string {
+	switch n := d.node.(type) {
+	case *idl_ast.Service:
+		return "service"
+	case *idl_ast.Message:
+		return n.Type
+	case *idl_ast.Enum:
+		return "enum"
+	case *idl_ast.Constant:
+		return "const"
+	case *idl_ast.Typedef:
+		return "typedef"
+	}
+	return ""
+}
+
+func (d definition) location() *idl_ast.Location {
+	switch n := d.node.(type) {
+	case *idl_ast.Service:
+		return n.Location
+	case *idl_ast.Message:
+		return n.Location
+	case *idl_ast.Enum:
+		return n.Location
+	case *idl_ast.Constant:
+		return n.Location
+	case *idl_ast.Typedef:
+		return n.Location
+	}
+	return nil
+}
+
+// collectDefinitions 按文件和声明种类的顺序列出 schema 中的所有顶层定义。
+func collectDefinitions(schema *idl_ast.IDLSchema) []definition {
+	var defs []definition
+	for i := range schema.Files {
+		file := &schema.Files[i]
+		d := &file.Definitions
+		for j := range d.Services {
+			defs = append(defs, definition{d.Services[j].FullyQualifiedName, file.Path, &d.Services[j]})
+		}
+		for j := range d.Messages {
+			defs = append(defs, definition{d.Messages[j].FullyQualifiedName, file.Path, &d.Messages[j]})
+		}
+		for j := range d.Enums {
+			defs = append(defs, definition{d.Enums[j].FullyQualifiedName, file.Path, &d.Enums[j]})
+		}
+		for j := range d.Constants {
+			defs = append(defs, definition{d.Constants[j].FullyQualifiedName, file.Path, &d.Constants[j]})
+		}
+		for j := range d.Typedefs {
+			defs = append(defs, definition{d.Typedefs[j].FullyQualifiedName, file.Path, &d.Typedefs[j]})
+		}
+	}
+	return defs
+}
+
+func (c *comparer) compareDefinitions() {
+	oldDefs := collectDefinitions(c.oldSchema)
+	newDefs := collectDefinitions(c.newSchema)
+	newByFQN := make(map[string]definition, len(newDefs))
+	for _, d := range newDefs {
+		newByFQN[d.fqn] = d
+	}
+	oldByFQN := make(map[string]definition, len(oldDefs))
+
+	for _, od := range oldDefs {
+		oldByFQN[od.fqn] = od
+		nd, ok := newByFQN[od.fqn]
+		if !ok {
+			severity := SeverityWarning
+			if od.kind() == "service" {
+				severity = SeverityError
+			}
+			c.add(severity, DefinitionRemoved, od.fqn, od.file, od.location(), "%s removed", od.kind())
+			continue
+		}
+		if od.kind() != nd.kind() {
+			c.add(SeverityError, DefinitionKindChanged, nd.fqn, nd.file, nd.location(), "changed from %s to %s", od.kind(), nd.kind())
+			continue
+		}
+
+		switch o := od.node.(type) {
+		case *idl_ast.Service:
+			c.compareService(o, nd.node.(*idl_ast.Service), nd.file)
+		case *idl_ast.Message:
+			c.compareFields(o.FullyQualifiedName, nd.file, fieldOfMessage, o.Fields, nd.node.(*idl_ast.Message).Fields)
+		case *idl_ast.Enum:
+			c.compareEnum(o, nd.node.(*idl_ast.Enum), nd.file)
+		case *idl_ast.Typedef:
+			n := nd.node.(*idl_ast.Typedef)
+			if severity, changed := c.compareTypes(&o.Type, &n.Type); changed {
+				c.add(severity, TypedefTypeChanged, nd.fqn, nd.file, n.Location, "changed from %s to %s", o.Type.String(), n.Type.String())
+			}
+		case *idl_ast.Constant:
+			n := nd.node.(*idl_ast.Constant)
+			if _, changed := c.compareTypes(&o.Type, &n.Type); changed {
+				c.add(SeverityWarning, ConstantTypeChanged, nd.fqn, nd.file, n.Location, "type changed from %s to %s", o.Type.String(), n.Type.String())
+			}
+			if ov, nv := o.Value.String(), n.Value.String(); ov != nv {
+				c.add(SeverityInfo, ConstantValueChanged, nd.fqn, nd.file, n.Location, "value changed from %s to %s", ov, nv)
+			}
+		}
+	}
+
+	for _, nd := range newDefs {
+		if _, ok := oldByFQN[nd.fqn]; !ok {
+			c.add(SeverityInfo, DefinitionAdded, nd.fqn, nd.file, nd.location(), "%s added", nd.kind())
+		}
+	}
+}
+
+func (c *comparer) compareService(o, n *idl_ast.Service, file string) {
+	if o.Extends != n.Extends {
+		c.add(SeverityError, ServiceExtendsChanged, n.FullyQualifiedName, file, n.Location,
+			"extends changed from %q to %q", o.Extends, n.Extends)
+	}
+
+	newFuncs := make(map[string]*idl_ast.Function, len(n.Functions))
+	for i := range n.Functions {
+		newFuncs[n.Functions[i].Name] = &n.Functions[i]
+	}
+	oldFuncs := make(map[string]struct{}, len(o.Functions))
+	for i := range o.Functions {
+		of := &o.Functions[i]
+		oldFuncs[of.Name] = struct{}{}
+		nf, ok := newFuncs[of.Name]
+		if !ok {
+			c.add(SeverityError, FunctionRemoved, of.FullyQualifiedName, file, of.Location, "function removed")
+			continue
+		}
+		if severity, changed := c.compareTypes(&of.ReturnType, &nf.ReturnType); changed {
+			c.add(severity, FunctionReturnTypeChanged, nf.FullyQualifiedName, file, nf.Location,
+				"return type changed from %s to %s", of.ReturnType.String(), nf.ReturnType.String())
+		}
+		if of.Oneway != nf.Oneway {
+			// oneway 函数的调用方不等待响应，两端不一致会导致调用方挂起或读到多余的响应。
+			c.add(SeverityError, FunctionOnewayChanged, nf.FullyQualifiedName, file, nf.Location,
+				"oneway changed from %t to %t", of.Oneway, nf.Oneway)
+		}
+		c.compareFields(nf.FullyQualifiedName, file, fieldOfParameter, of.Parameters, nf.Parameters)
+		c.compareFields(nf.FullyQualifiedName, file, fieldOfThrows, of.Throws, nf.Throws)
+	}
+	for i := range n.Functions {
+		nf := &n.Functions[i]
+		if _, ok := oldFuncs[nf.Name]; !ok {
+			c.add(SeverityInfo, FunctionAdded, nf.FullyQualifiedName, file, nf.Location, "function added")
+		}
+	}
+}
+
+// fieldContext 区分 Message 字段、函数参数和 throws 子句，它们的兼容性规则略有不同。
+type fieldContext int
+
+const (
+	fieldOfMessage fieldContext = iota
+	fieldOfParameter
+	fieldOfThrows
+)
+
+func (fc fieldContext) String() string {
+	switch fc {
+	case fieldOfParameter:
+		return "parameter"
+	case fieldOfThrows:
+		return "exception"
+	default:
+		return "field"
+	}
+}
+
+func (c *comparer) compareFields(ownerFQN, file string, fc fieldContext, oldFields, newFields []idl_ast.Field) {
+	newByID := make(map[int]*idl_ast.Field, len(newFields))
+	for i := range newFields {
+		newByID[newFields[i].ID] = &newFields[i]
+	}
+	oldIDs := make(map[int]struct{}, len(oldFields))
+
+	for i := range oldFields {
+		of := &oldFields[i]
+		oldIDs[of.ID] = struct{}{}
+		fqn := ownerFQN + "." + of.Name
+		nf, ok := newByID[of.ID]
+		if !ok {
+			severity := SeverityWarning
+			if fc == fieldOfMessage && of.Required == "required" {
+				severity = SeverityError
+			}
+			c.add(severity, FieldRemoved, fqn, file, of.Location, "%s %d (%s) removed", fc, of.ID, of.Name)
+			continue
+		}
+		fqn = ownerFQN + "." + nf.Name
+
+		if severity, changed := c.compareTypes(&of.Type, &nf.Type); changed {
+			c.add(severity, FieldTypeChanged, fqn, file, nf.Location,
+				"%s %d changed type from %s to %s", fc, nf.ID, of.Type.String(), nf.Type.String())
+		}
+		if of.Name != nf.Name {
+			c.add(SeverityWarning, FieldRenamed, fqn, file, nf.Location,
+				"%s %d renamed from %s to %s", fc, nf.ID, of.Name, nf.Name)
+		}
+		if fc == fieldOfMessage && of.Required != nf.Required {
+			severity := SeverityInfo
+			switch {
+			case nf.Required == "required":
+				severity = SeverityError
+			case of.Required == "required":
+				severity = SeverityWarning
+			}
+			c.add(severity, FieldRequirednessChanged, fqn, file, nf.Location,
+				"%s %d changed from %s to %s", fc, nf.ID, requiredness(of.Required), requiredness(nf.Required))
+		}
+		if ov, nv := of.DefaultValue.String(), nf.DefaultValue.String(); ov != nv {
+			c.add(SeverityWarning, FieldDefaultChanged, fqn, file, nf.Location,
+				"%s %d default value changed from %s to %s", fc, nf.ID, orNone(ov), orNone(nv))
+		}
+	}
+
+	for i := range newFields {
+		nf := &newFields[i]
+		if _, ok := oldIDs[nf.ID]; ok {
+			continue
+		}
+		severity := SeverityInfo
+		switch {
+		case fc == fieldOfMessage && nf.Required == "required":
+			severity = SeverityError
+		case fc == fieldOfThrows:
+			// 旧版本的客户端无法识别新增的异常。
+			severity = SeverityWarning
+		}
+		c.add(severity, FieldAdded, ownerFQN+"."+nf.Name, file, nf.Location, "%s %d (%s) added", fc, nf.ID, nf.Name)
+	}
+}
+
+func (c *comparer) compareEnum(o, n *idl_ast.Enum, file string) {
+	newByName := make(map[string]*idl_ast.EnumValue, len(n.Values))
+	for i := range n.Values {
+		newByName[n.Values[i].Name] = &n.Values[i]
+	}
+	oldByName := make(map[string]*idl_ast.EnumValue, len(o.Values))
+	var removed []*idl_ast.EnumValue
+	for i := range o.Values {
+		ov := &o.Values[i]
+		oldByName[ov.Name] = ov
+		nv, ok := newByName[ov.Name]
+		if !ok {
+			removed = append(removed, ov)
+			continue
+		}
+		if ov.Value != nv.Value {
+			c.add(SeverityError, EnumValueChanged, n.FullyQualifiedName+"."+nv.Name, file, nv.Location,
+				"value changed from %d to %d", ov.Value, nv.Value)
+		}
+	}
+
+	// 旧版本中被删除的成员如果与新增成员的数值相同，视为改名。
+	added := make(map[int]*idl_ast.EnumValue)
+	var addedOrder []*idl_ast.EnumValue
+	for i := range n.Values {
+		nv := &n.Values[i]
+		if _, ok := oldByName[nv.Name]; !ok {
+			added[nv.Value] = nv
+			addedOrder = append(addedOrder, nv)
+		}
+	}
+	for _, ov := range removed {
+		if nv, ok := added[ov.Value]; ok {
+			c.add(SeverityWarning, EnumValueRenamed, n.FullyQualifiedName+"."+nv.Name, file, nv.Location,
+				"value %d renamed from %s to %s", ov.Value, ov.Name, nv.Name)
+			delete(added, ov.Value)
+			continue
+		}
+		c.add(SeverityWarning, EnumValueRemoved, o.FullyQualifiedName+"."+ov.Name, file, ov.Location,
+			"value %s = %d removed", ov.Name, ov.Value)
+	}
+	for _, nv := range addedOrder {
+		if added[nv.Value] == nv {
+			c.add(SeverityInfo, EnumValueAdded, n.FullyQualifiedName+"."+nv.Name, file, nv.Location,
+				"value %s = %d added", nv.Name, nv.Value)
+		}
+	}
+}
+
+// compareTypes 比较两个类型在各自 schema 中的规范形式（展开 typedef 之后）。
+// 线上编码相同的变更（例如 enum 与 i32、binary 与 string 互换）只报告为 SeverityWarning。
+func (c *comparer) compareTypes(o, n *idl_ast.Type) (Severity, bool) {
+	if canonical(c.oldSchema, o, false) == canonical(c.newSchema, n, false) {
+		return "", false
+	}
+	if canonical(c.oldSchema, o, true) == canonical(c.newSchema, n, true) {
+		return SeverityWarning, true
+	}
+	return SeverityError, true
+}
+
+// canonical 返回类型展开 typedef 后的规范写法。wire 为 true 时，
+// 线上编码相同的类型会被视为同一种类型。
+func canonical(schema *idl_ast.IDLSchema, t *idl_ast.Type, wire bool) string {
+	if t == nil {
+		return ""
+	}
+	if resolved, err := schema.ResolveType(t); err == nil {
+		t = resolved.Type
+	}
+
+	switch t.Name {
+	case "map":
+		return fmt.Sprintf("map<%s,%s>", canonical(schema, t.KeyType, wire), canonical(schema, t.ValueType, wire))
+	case "list", "set":
+		return fmt.Sprintf("%s<%s>", t.Name, canonical(schema, t.ValueType, wire))
+	}
+	if t.FullyQualifiedName == "" {
+		if wire && t.Name == "binary" {
+			return "string"
+		}
+		return t.Name
+	}
+	if wire && len(schema.FindEnumsByFQN(t.FullyQualifiedName)) > 0 {
+		return "i32"
+	}
+	return t.FullyQualifiedName
+}
+
+func requiredness(r string) string {
+	if r == "" {
+		return "default"
+	}
+	return r
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,181 @@
+package thriftcompat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/internal/idltest"
+)
+
+func findChange(report *Report, kind ChangeKind, fqn string) *Change {
+	for i := range report.Changes {
+		if report.Changes[i].Kind == kind && report.Changes[i].FQN == fqn {
+			return &report.Changes[i]
+		}
+	}
+	return nil
+}
+
+const baseIDL = `
+typedef i64 UserID
+
+enum Gender {
+  UNKNOWN = 0,
+  MALE = 1,
+  FEMALE = 2
+}
+
+union Contact {
+  1: string email
+  2: string phone
+}
+
+struct User {
+  1: UserID id
+  2: optional string name
+  3: i32 age
+  4: Gender gender
+}
+
+service UserService {
+  User getUser(1: UserID id)
+  void deleteUser(1: UserID id)
+}
+`
+
+func TestCompare_NoChanges(t *testing.T) {
+	files := map[string][]byte{"user.thrift": []byte(baseIDL)}
+	report, err := Compare(idltest.ParseThrift(t, files), idltest.ParseThrift(t, files))
+	require.NoError(t, err)
+	assert.True(t, report.IsEmpty())
+}
+
+func TestCompare_BreakingChanges(t *testing.T) {
+	oldSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(baseIDL)})
+	newSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(`
+typedef i64 UserID
+
+enum Gender {
+  UNKNOWN = 0,
+  MALE = 10,
+  WOMAN = 2,
+  OTHER = 3
+}
+
+struct Contact {
+  1: string email
+  2: string phone
+}
+
+struct User {
+  1: i64 id
+  2: required string name
+  3: string age
+  4: Gender gender
+  5: optional string nickname
+}
+
+service UserService {
+  User getUser(1: UserID id, 2: bool verbose)
+}
+`)})
+
+	report, err := Compare(oldSchema, newSchema)
+	require.Error(t, err)
+	assert.Same(t, report, err)
+	assert.True(t, report.HasBreakingChanges())
+
+	cases := []struct {
+		kind     ChangeKind
+		fqn      string
+		severity Severity
+	}{
+		{DefinitionKindChanged, "user.thrift#Contact", SeverityError},
+		{FieldRequirednessChanged, "user.thrift#User.name", SeverityError},
+		{FieldTypeChanged, "user.thrift#User.age", SeverityError},
+		{FieldAdded, "user.thrift#User.nickname", SeverityInfo},
+		{EnumValueChanged, "user.thrift#Gender.MALE", SeverityError},
+		{EnumValueRenamed, "user.thrift#Gender.WOMAN", SeverityWarning},
+		{EnumValueAdded, "user.thrift#Gender.OTHER", SeverityInfo},
+		{FunctionRemoved, "user.thrift#UserService.deleteUser", SeverityError},
+		{FieldAdded, "user.thrift#UserService.getUser.verbose", SeverityInfo},
+	}
+	for _, tc := range cases {
+		c := findChange(report, tc.kind, tc.fqn)
+		if assert.NotNil(t, c, "missing %s on %s", tc.kind, tc.fqn) {
+			assert.Equal(t, tc.severity, c.Severity, "%s on %s", tc.kind, tc.fqn)
+			assert.NotNil(t, c.Location)
+			assert.Equal(t, "user.thrift", c.File)
+		}
+	}
+
+	// UserID -> i64 展开 typedef 后类型相同，不算变更。
+	assert.Nil(t, findChange(report, FieldTypeChanged, "user.thrift#User.id"))
+	assert.Contains(t, report.Error(), "user.thrift#UserService.deleteUser")
+}
+
+func TestCompare_Oneway(t *testing.T) {
+	oldSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(`
+service UserService {
+  oneway void notify(1: string msg)
+  void ping()
+}
+`)})
+	newSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(`
+service UserService {
+  void notify(1: string msg)
+  void ping()
+}
+`)})
+
+	report, err := Compare(oldSchema, newSchema)
+	require.Error(t, err)
+	c := findChange(report, FunctionOnewayChanged, "user.thrift#UserService.notify")
+	require.NotNil(t, c)
+	assert.Equal(t, SeverityError, c.Severity)
+	assert.Nil(t, findChange(report, FunctionOnewayChanged, "user.thrift#UserService.ping"))
+}
+
+func TestCompare_Options(t *testing.T) {
+	oldSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(baseIDL)})
+	newSchema := idltest.ParseThrift(t, map[string][]byte{"user.thrift": []byte(`
+typedef i64 UserID
+
+enum Gender {
+  UNKNOWN = 0,
+  MALE = 1,
+  FEMALE = 2
+}
+
+union Contact {
+  1: string email
+  2: string phone
+}
+
+struct User {
+  1: UserID uid
+  2: optional string name
+  3: i32 age
+  4: i32 gender
+}
+
+service UserService {
+  User getUser(1: UserID id)
+  void deleteUser(1: UserID id)
+}
+`)})
+
+	// 改名和 enum -> i32 都只是警告，默认不会失败。
+	report, err := Compare(oldSchema, newSchema)
+	require.NoError(t, err)
+	assert.Len(t, report.Filter(SeverityWarning), 2)
+
+	_, err = Compare(oldSchema, newSchema, WithFailOn(SeverityWarning))
+	assert.Error(t, err)
+
+	report, err = Compare(oldSchema, newSchema, WithFailOn(SeverityWarning), WithIgnoredKinds(FieldRenamed, FieldTypeChanged))
+	require.NoError(t, err)
+	assert.True(t, report.IsEmpty())
+}
@@ -0,0 +1,59 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+func TestChecksum(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"main.thrift": []byte(`
+const list<i32> LIMITS = [1, 2]
+
+struct User {
+  1: i64 id = 0 (api.query = "id")
+  2: optional string name
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	user := schema.FindStructsByFQN("main.thrift#User")[0]
+	require.NotEmpty(t, user.Checksum)
+	modified, err := idl_ast.IsModified(user)
+	require.NoError(t, err)
+	assert.False(t, modified)
+
+	// 位置和原文不参与计算。
+	user.Location = nil
+	user.Content = ""
+	user.Fields[0].Location = nil
+	modified, err = idl_ast.IsModified(user)
+	require.NoError(t, err)
+	assert.False(t, modified)
+
+	user.Fields[1].Required = "required"
+	modified, err = idl_ast.IsModified(user)
+	require.NoError(t, err)
+	assert.True(t, modified)
+
+	// 常量值中不受支持的 Go 类型会返回错误，而不是被当作已修改。
+	limits := schema.FindConstantsByFQN("main.thrift#LIMITS")[0]
+	limits.Value.Value = []int{1, 2}
+	_, err = idl_ast.IsModified(limits)
+	assert.Error(t, err)
+
+	_, err = idl_ast.Checksum(&idl_ast.Field{})
+	assert.Error(t, err)
+
+	// 手动构造的定义没有记录校验和，总是被视为已修改。
+	modified, err = idl_ast.IsModified(&idl_ast.Message{Name: "New"})
+	require.NoError(t, err)
+	assert.True(t, modified)
+}
@@ -0,0 +1,84 @@
+package thriftparser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/joyme123/thrift-ls/parser"
+)
+
+// Diagnostic 描述恢复模式下一个无法解析的文件中的语法错误，或者入口文件模式下一个找不到的 include。
+type Diagnostic struct {
+	// File 是无法解析的文件或者发起 include 的文件，相对于 rootDir。
+	File     string            `json:"file"`
+	Message  string            `json:"message"`
+	Location *idl_ast.Location `json:"location,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Location == nil {
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Location.Start.Line, d.Location.Start.Column, d.Message)
+}
+
+// Diagnostics 返回恢复模式下所有无法解析的文件以及找不到的 include 的诊断信息，按文件和位置排序。
+// 无法解析和找不到的文件不会出现在 ParseIDLs 返回的 schema 中，指向其中定义的类型会被标记为 Unresolved。
+func (p *ThriftParser) Diagnostics() []Diagnostic {
+	var res []Diagnostic
+	for _, diags := range p.diagnostics {
+		res = append(res, diags...)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].File != res[j].File {
+			return res[i].File < res[j].File
+		}
+		return diagnosticLine(res[i]) < diagnosticLine(res[j])
+	})
+	return res
+}
+
+func diagnosticLine(d Diagnostic) int {
+	if d.Location == nil {
+		return 0
+	}
+	return d.Location.Start.Line
+}
+
+// recordFailure 记录 absPath 无法解析的原因，替换它之前的诊断信息。
+func (p *ThriftParser) recordFailure(absPath string, err error) {
+	file := p.relativePath(absPath)
+
+	var lister parser.ErrorLister
+	if !errors.As(err, &lister) {
+		p.diagnostics[absPath] = []Diagnostic{{File: file, Message: err.Error()}}
+		return
+	}
+	var diags []Diagnostic
+	for _, e := range lister.Errors() {
+		var parseErr parser.ParserError
+		if !errors.As(e, &parseErr) {
+			diags = append(diags, Diagnostic{File: file, Message: e.Error()})
+			continue
+		}
+		line, col, offset := parseErr.Pos()
+		pos := idl_ast.Position{Line: line, Column: col, Offset: offset}
+		diags = append(diags, Diagnostic{
+			File:     file,
+			Message:  parseErr.InnerError().Error(),
+			Location: &idl_ast.Location{Start: pos, End: pos},
+		})
+	}
+	p.diagnostics[absPath] = diags
+}
+
+// recordMissingInclude 把入口文件模式下找不到的 include 记录为发起 include 的文件 absPath 的诊断信息。
+func (p *ThriftParser) recordMissingInclude(absPath string, m MissingInclude) {
+	p.diagnostics[absPath] = append(p.diagnostics[absPath], Diagnostic{
+		File:     m.File,
+		Message:  fmt.Sprintf("include %q not found", m.Include),
+		Location: m.Location,
+	})
+}
@@ -0,0 +1,166 @@
+package thriftparser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/Skyenought/idlanalyzer/internal/thriftsrc"
+)
+
+// MissingInclude 描述入口文件的 include 闭包中一个找不到的 include。
+type MissingInclude struct {
+	// File 是发起 include 的文件，相对于 rootDir。
+	File string `json:"file"`
+	// Include 是 include 语句中书写的路径。
+	Include  string            `json:"include"`
+	Location *idl_ast.Location `json:"location,omitempty"`
+}
+
+func (m MissingInclude) String() string {
+	if m.Location == nil {
+		return fmt.Sprintf("%s: include %q not found", m.File, m.Include)
+	}
+	return fmt.Sprintf("%s:%d:%d: include %q not found", m.File, m.Location.Start.Line, m.Location.Start.Column, m.Include)
+}
+
+// MissingIncludesError 是使用 WithEntryFiles 时，include 闭包中存在找不到的文件时返回的错误。
+type MissingIncludesError struct {
+	Missing []MissingInclude `json:"missing"`
+}
+
+func (e *MissingIncludesError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d include(s) not found:", len(e.Missing)))
+	for _, m := range e.Missing {
+		sb.WriteString("\n - " + m.String())
+	}
+	return sb.String()
+}
+
+// fileReader 读取 absPath 的内容，文件不存在时返回 false。
+type fileReader func(absPath string) ([]byte, bool, error)
+
+// diskReader 从文件系统读取文件。
+func diskReader(absPath string) ([]byte, bool, error) {
+	content, err := os.ReadFile(absPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return content, true, nil
+}
+
+// mapReader 从 NewParserFromMap 的 fileMap 中读取文件，fileMap 的键相对于 rootDir。
+func mapReader(rootDir string, fileMap map[string][]byte) fileReader {
+	files := make(map[string][]byte, len(fileMap))
+	for relativePath, content := range fileMap {
+		files[filepath.Join(rootDir, relativePath)] = content
+	}
+	return func(absPath string) ([]byte, bool, error) {
+		content, ok := files[absPath]
+		return content, ok, nil
+	}
+}
+
+// loadEntryClosure 从入口文件出发，沿 include（按 include 路径的规则解析）收集传递闭包中的所有文件，
+// 返回以相对 rootDir 的路径为键的文件内容。入口文件不存在时直接返回错误；
+// 闭包中找不到的 include 汇总为 *MissingIncludesError，恢复模式下则记录为诊断信息。
+func (p *ThriftParser) loadEntryClosure(entries []string, read fileReader) (map[string][]byte, error) {
+	loaded := make(map[string][]byte)
+	var queue []string
+	for _, entry := range entries {
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(p.rootDir, entry)
+		}
+		entry = filepath.Clean(entry)
+		if _, ok := loaded[entry]; ok {
+			continue
+		}
+		content, ok, err := read(entry)
+		if err != nil {
+			return nil, fmt.Errorf("read entry file %s: %w", entry, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("entry file %s not found", entry)
+		}
+		loaded[entry] = content
+		queue = append(queue, entry)
+	}
+
+	missing := &MissingIncludesError{}
+	var readErr error
+	exists := func(path string) bool {
+		if _, ok := loaded[path]; ok {
+			return true
+		}
+		content, ok, err := read(path)
+		if err != nil && readErr == nil {
+			readErr = err
+		}
+		if ok {
+			loaded[path] = content
+			queue = append(queue, path)
+		}
+		return ok
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		doc, _, err := thriftsrc.Parse(current, loaded[current])
+		if err != nil {
+			// 恢复模式下保留这个文件，构建快照时再记录它的诊断信息，只是不再跟随它的 include。
+			if p.opts.Recover {
+				continue
+			}
+			return nil, fmt.Errorf("failed to process %s: %w", current, err)
+		}
+		for _, inc := range doc.Includes {
+			if inc.Path == nil || inc.Path.Value == nil {
+				continue
+			}
+			// exists 会把新发现的文件加入队列。
+			if _, found := ResolveInclude(current, inc.Path.Value.Text, p.includePaths, exists); found {
+				continue
+			}
+			if readErr != nil {
+				return nil, fmt.Errorf("read include %q of %s: %w", inc.Path.Value.Text, current, readErr)
+			}
+			loc := convertLocation(inc.Location)
+			m := MissingInclude{
+				File:     p.relativePath(current),
+				Include:  inc.Path.Value.Text,
+				Location: &loc,
+			}
+			// 恢复模式下 include 了它的文件照常解析，指向它的类型会被标记为 Unresolved。
+			if p.opts.Recover {
+				p.recordMissingInclude(current, m)
+				continue
+			}
+			missing.Missing = append(missing.Missing, m)
+		}
+	}
+	if len(missing.Missing) > 0 {
+		return nil, missing
+	}
+
+	files := make(map[string][]byte, len(loaded))
+	for absPath, content := range loaded {
+		files[p.relativePath(absPath)] = content
+	}
+	return files, nil
+}
+
+func (p *ThriftParser) relativePath(absPath string) string {
+	if rel, err := filepath.Rel(p.rootDir, absPath); err == nil {
+		return rel
+	}
+	return absPath
+}
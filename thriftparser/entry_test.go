@@ -0,0 +1,79 @@
+package thriftparser
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_WithEntryFiles(t *testing.T) {
+	p, err := NewParser("testdata/thrifts", WithEntryFiles("person/person.thrift"))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	var paths []string
+	for _, f := range schema.Files {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{"common/entity/entity.thrift", "gender/gender.thrift", "person/person.thrift"}, paths)
+}
+
+func TestParser_WithEntryFilesFromMap(t *testing.T) {
+	files := map[string][]byte{
+		"svc/main.thrift": []byte(`
+include "../common/base.thrift"
+
+struct Request {
+  1: base.Base base
+}
+`),
+		"common/base.thrift": []byte(`
+struct Base {
+  1: string id
+}
+`),
+		// 不在入口文件的 include 闭包中，即使有语法错误也不会被解析。
+		"legacy/broken.thrift": []byte(`struct {`),
+	}
+
+	p, err := NewParserFromMap("project", files, WithEntryFiles("svc/main.thrift"))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	assert.Len(t, schema.Files, 2)
+	requests := schema.FindMessagesByFQN("svc/main.thrift#Request")
+	require.Len(t, requests, 1)
+	assert.Equal(t, "common/base.thrift#Base", requests[0].Fields[0].Type.FullyQualifiedName)
+
+	_, err = NewParserFromMap("project", files, WithEntryFiles("svc/missing.thrift"))
+	assert.Error(t, err)
+}
+
+func TestParser_WithEntryFilesMissingIncludes(t *testing.T) {
+	files := map[string][]byte{
+		"main.thrift": []byte(`include "a.thrift"
+include "gone.thrift"
+`),
+		"a.thrift": []byte(`include "lib/missing.thrift"
+`),
+	}
+
+	_, err := NewParserFromMap("project", files, WithEntryFiles("main.thrift"))
+	var missingErr *MissingIncludesError
+	require.True(t, errors.As(err, &missingErr), "unexpected error: %v", err)
+	require.Len(t, missingErr.Missing, 2)
+
+	assert.Equal(t, "main.thrift", missingErr.Missing[0].File)
+	assert.Equal(t, "gone.thrift", missingErr.Missing[0].Include)
+	require.NotNil(t, missingErr.Missing[0].Location)
+	assert.Equal(t, 2, missingErr.Missing[0].Location.Start.Line)
+
+	assert.Equal(t, "a.thrift", missingErr.Missing[1].File)
+	assert.Equal(t, "lib/missing.thrift", missingErr.Missing[1].Include)
+	assert.Contains(t, err.Error(), "2 include(s) not found")
+}
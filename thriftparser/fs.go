@@ -0,0 +1,58 @@
+package thriftparser
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Skyenought/idlanalyzer/idlfs"
+)
+
+// NewParserFromFS 从 fsys 中 root 目录下的 .thrift 文件创建解析器。fsys 可以是 embed.FS、
+// archive/zip.Reader，或者 idlfs.FromTarGz、idlfs.Overlay 返回的文件系统。
+// 与 NewParserFromMap 一样，文件路径（以及 FQN）相对于 root，root 只作为逻辑上的根目录。
+// 设置了 WithEntryFiles 时只读取入口文件的 include 闭包，不会遍历整个 root。
+func NewParserFromFS(fsys fs.FS, root string, opts ...Option) (*ThriftParser, error) {
+	root = path.Clean(root)
+	t := newLogicalParser(root, opts)
+
+	var fileMap map[string][]byte
+	var err error
+	if len(t.opts.EntryFiles) > 0 {
+		fileMap, err = t.loadEntryClosure(t.opts.EntryFiles, fsReader(fsys, root, t.rootDir))
+	} else {
+		fileMap, err = idlfs.ReadThriftFiles(fsys, root)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, files, err := t.buildSnapshotWithMap(t.rootDir, fileMap)
+	if err != nil {
+		return nil, err
+	}
+	t.snapshot = snapshot
+	t.files = files
+
+	return t, nil
+}
+
+// fsReader 从 fsys 中读取逻辑路径 absPath（位于 rootDir 之下）对应的文件 root/<相对路径>。
+func fsReader(fsys fs.FS, root, rootDir string) fileReader {
+	return func(absPath string) ([]byte, bool, error) {
+		rel, err := filepath.Rel(rootDir, absPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, false, nil
+		}
+		content, err := fs.ReadFile(fsys, path.Join(root, filepath.ToSlash(rel)))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return content, true, nil
+	}
+}
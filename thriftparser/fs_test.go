@@ -0,0 +1,70 @@
+package thriftparser
+
+import (
+	"os"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idlfs"
+)
+
+func TestNewParserFromFS(t *testing.T) {
+	p, err := NewParserFromFS(os.DirFS("testdata"), "thrifts")
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	var paths []string
+	for _, f := range schema.Files {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{"common/entity/entity.thrift", "gender/gender.thrift", "main.thrift", "person/person.thrift"}, paths)
+
+	// 在磁盘内容之上叠加未保存的修改，并且只解析入口文件的闭包。
+	overlay := idlfs.Overlay(os.DirFS("testdata"), map[string][]byte{
+		"thrifts/gender/gender.thrift": []byte(`
+include "../common/entity/entity.thrift"
+
+enum Gender {
+  UNKNOWN = 0
+}
+`),
+	})
+	p, err = NewParserFromFS(overlay, "thrifts", WithEntryFiles("person/person.thrift"))
+	require.NoError(t, err)
+	schema, err = p.ParseIDLs()
+	require.NoError(t, err)
+	assert.Len(t, schema.Files, 3)
+	enums := schema.FindEnumsByFQN("gender/gender.thrift#Gender")
+	require.Len(t, enums, 1)
+	require.Len(t, enums[0].Values, 1)
+	assert.Equal(t, "UNKNOWN", enums[0].Values[0].Name)
+}
+
+func TestNewParserFromFS_IncludePaths(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bundle/idl/svc/main.thrift": {Data: []byte(`
+include "base.thrift"
+
+struct Request {
+  1: base.Base base
+}
+`)},
+		"bundle/third_party/base.thrift": {Data: []byte(`
+struct Base {}
+`)},
+	}
+
+	p, err := NewParserFromFS(fsys, "bundle", WithIncludePaths("third_party"), WithEntryFiles("idl/svc/main.thrift"))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	requests := schema.FindMessagesByFQN("idl/svc/main.thrift#Request")
+	require.Len(t, requests, 1)
+	assert.Equal(t, "third_party/base.thrift#Base", requests[0].Fields[0].Type.FullyQualifiedName)
+}
@@ -0,0 +1,108 @@
+package thriftparser
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/joyme123/thrift-ls/lsp/lsputils"
+	"github.com/joyme123/thrift-ls/parser"
+	"go.lsp.dev/uri"
+)
+
+// ResolveInclude 按 Thrift 编译器 `-I` 的规则解析 currentFile 中的 `include "includePath"`：
+// 先相对于 currentFile 所在的目录查找，再依次在 includePaths 中查找，返回第一个 exists 为 true 的路径。
+// 绝对路径的 include 只检查它本身。找不到时返回相对于 currentFile 的路径和 false。
+func ResolveInclude(currentFile, includePath string, includePaths []string, exists func(path string) bool) (string, bool) {
+	if filepath.IsAbs(includePath) {
+		p := filepath.Clean(includePath)
+		return p, exists(p)
+	}
+	relative := filepath.Join(filepath.Dir(currentFile), includePath)
+	if exists(relative) {
+		return relative, true
+	}
+	for _, dir := range includePaths {
+		if p := filepath.Join(dir, includePath); exists(p) {
+			return p, true
+		}
+	}
+	return relative, false
+}
+
+// resolveInclude 返回 cur 中 include 的文件的 URI。没有配置 include 路径时与 thrift-ls 的行为一致。
+func (p *ThriftParser) resolveInclude(cur uri.URI, includePath string) uri.URI {
+	if len(p.includePaths) == 0 {
+		return lsputils.IncludeURI(cur, includePath)
+	}
+	path, _ := ResolveInclude(cur.Filename(), includePath, p.includePaths, func(path string) bool {
+		_, ok := p.fileAsts[path]
+		return ok
+	})
+	return uri.File(path)
+}
+
+// absIncludePaths 把相对路径形式的 include 路径转换为相对于 rootDir 的绝对路径。
+func absIncludePaths(rootDir string, dirs []string) []string {
+	res := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(rootDir, dir)
+		}
+		res = append(res, filepath.Clean(dir))
+	}
+	return res
+}
+
+// resolveTypeByIncludePaths 在 thrift-ls 无法解析类型时（被 include 的文件需要通过 include 路径查找），
+// 按 include 前缀找到定义所在的文件，返回文件的 URI 和定义名。
+func (p *ThriftParser) resolveTypeByIncludePaths(cur uri.URI, doc *parser.Document, typeName string) (uri.URI, string, bool) {
+	if len(p.includePaths) == 0 {
+		return "", "", false
+	}
+	for _, inc := range doc.Includes {
+		if inc.Path == nil || inc.Path.Value == nil {
+			continue
+		}
+		base := filepath.Base(inc.Path.Value.Text)
+		prefix := strings.TrimSuffix(base, filepath.Ext(base)) + "."
+		if !strings.HasPrefix(typeName, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(typeName, prefix)
+		target := p.resolveInclude(cur, inc.Path.Value.Text)
+		if targetDoc, ok := p.fileAsts[target.Filename()]; ok && definesType(targetDoc, name) {
+			return target, name, true
+		}
+	}
+	return "", "", false
+}
+
+// definesType 报告 doc 中是否定义了名为 name 的类型。
+func definesType(doc *parser.Document, name string) bool {
+	for _, s := range doc.Structs {
+		if s.Identifier != nil && s.Identifier.Name != nil && s.Identifier.Name.Text == name {
+			return true
+		}
+	}
+	for _, u := range doc.Unions {
+		if u.Name != nil && u.Name.Name != nil && u.Name.Name.Text == name {
+			return true
+		}
+	}
+	for _, e := range doc.Exceptions {
+		if e.Name != nil && e.Name.Name != nil && e.Name.Name.Text == name {
+			return true
+		}
+	}
+	for _, e := range doc.Enums {
+		if e.Name != nil && e.Name.Name != nil && e.Name.Name.Text == name {
+			return true
+		}
+	}
+	for _, t := range doc.Typedefs {
+		if t.Alias != nil && t.Alias.Name != nil && t.Alias.Name.Text == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInclude(t *testing.T) {
+	files := map[string]bool{
+		"/repo/idl/user/user.thrift":        true,
+		"/repo/idl/user/local.thrift":       true,
+		"/repo/idl/base.thrift":             true,
+		"/repo/third_party/base.thrift":     true,
+		"/repo/third_party/extra.thrift":    true,
+		"/repo/third_party/common/x.thrift": true,
+	}
+	exists := func(path string) bool { return files[path] }
+	dirs := []string{"/repo/idl", "/repo/third_party"}
+
+	tests := []struct {
+		include string
+		want    string
+		found   bool
+	}{
+		{"local.thrift", "/repo/idl/user/local.thrift", true},
+		{"base.thrift", "/repo/idl/base.thrift", true},
+		{"extra.thrift", "/repo/third_party/extra.thrift", true},
+		{"common/x.thrift", "/repo/third_party/common/x.thrift", true},
+		{"/repo/idl/base.thrift", "/repo/idl/base.thrift", true},
+		{"missing.thrift", "/repo/idl/user/missing.thrift", false},
+	}
+	for _, tt := range tests {
+		got, found := ResolveInclude("/repo/idl/user/user.thrift", tt.include, dirs, exists)
+		assert.Equal(t, tt.want, got, tt.include)
+		assert.Equal(t, tt.found, found, tt.include)
+	}
+}
+
+func TestParser_WithIncludePaths(t *testing.T) {
+	files := map[string][]byte{
+		"third_party/base.thrift": []byte(`
+struct Base {
+  1: string id
+}
+
+service BaseService {
+  void ping()
+}
+`),
+		"idl/common/types.thrift": []byte(`
+typedef i64 ID
+`),
+		"idl/user/user.thrift": []byte(`
+include "base.thrift"
+include "common/types.thrift"
+
+const types.ID DEFAULT_ID = 1
+
+struct User {
+  1: base.Base base
+  2: types.ID id
+}
+
+service UserService extends base.BaseService {
+  User get(1: types.ID id)
+}
+`),
+	}
+
+	p, err := NewParserFromMap("project", files, WithIncludePaths("third_party", "idl"))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	users := schema.FindMessagesByFQN("idl/user/user.thrift#User")
+	require.Len(t, users, 1)
+	assert.Equal(t, "third_party/base.thrift#Base", users[0].Fields[0].Type.FullyQualifiedName)
+	assert.Equal(t, "idl/common/types.thrift#ID", users[0].Fields[1].Type.FullyQualifiedName)
+
+	file := schema.Files[0]
+	for _, f := range schema.Files {
+		if f.Path == "idl/user/user.thrift" {
+			file = f
+		}
+	}
+	require.Len(t, file.Imports, 2)
+	assert.Equal(t, "third_party/base.thrift", file.Imports[0].Path)
+	assert.Equal(t, "idl/common/types.thrift", file.Imports[1].Path)
+
+	res, err := schema.ResolveService("idl/user/user.thrift#UserService")
+	require.NoError(t, err)
+	assert.Len(t, res.Functions, 2)
+
+	consts := schema.FindConstantsByFQN("idl/user/user.thrift#DEFAULT_ID")
+	require.Len(t, consts, 1)
+	assert.Equal(t, "idl/common/types.thrift#ID", consts[0].Type.FullyQualifiedName)
+
+	// 不设置 include 路径时，include 只相对于当前文件解析。
+	p, err = NewParserFromMap("project", files)
+	require.NoError(t, err)
+	schema, err = p.ParseIDLs()
+	require.NoError(t, err)
+	users = schema.FindMessagesByFQN("idl/user/user.thrift#User")
+	require.Len(t, users, 1)
+	assert.Empty(t, users[0].Fields[0].Type.FullyQualifiedName)
+}
@@ -0,0 +1,178 @@
+package thriftparser
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/joyme123/thrift-ls/lsp/cache"
+	"go.lsp.dev/uri"
+)
+
+// UpdateFile 用 content 替换 path 的内容（path 不存在时新增该文件），只重新解析这个文件，
+// 并重新转换类型解析依赖它的文件（即 include 了它的文件），其余文件的 AST 保持不变。
+// path 相对于 rootDir，也可以是绝对路径。
+//
+// 如果已经调用过 ParseIDLs，它返回的 schema 会被原地更新：文件被替换、新增的文件追加到末尾，
+// 常量引用重新解析，被重新转换的文件重新记录校验和。快照中的 thrift-ls 缓存会被复用。
+func (p *ThriftParser) UpdateFile(path string, content []byte) error {
+	absPath := p.absPath(path)
+	change, err := p.loadFile(absPath, content)
+	if err != nil {
+		if !p.opts.Recover {
+			return err
+		}
+		// 恢复模式下，改坏的文件从解析器中移除，只保留它的诊断信息。
+		if p.fileIndex(absPath) >= 0 {
+			if err := p.RemoveFile(absPath); err != nil {
+				return err
+			}
+		}
+		p.recordFailure(absPath, err)
+		return nil
+	}
+	delete(p.diagnostics, change.URI.Filename())
+	if err := p.overlay.Update(context.TODO(), []*cache.FileChange{change}); err != nil {
+		return fmt.Errorf("update %s: %w", absPath, err)
+	}
+	p.snapshot.ForgetFile(change.URI)
+	p.snapshot.Parse(context.TODO(), change.URI)
+
+	if i := p.fileIndex(change.URI.Filename()); i >= 0 {
+		p.files[i] = change
+	} else {
+		p.files = append(p.files, change)
+	}
+
+	return p.retransform(append([]string{change.URI.Filename()}, p.includersOf(change.URI.Filename())...), "")
+}
+
+// RemoveFile 从解析器中删除 path，并重新转换 include 了它的文件，这些文件中指向它的类型将不再有 FQN。
+// 与 UpdateFile 一样，已经返回的 schema 会被原地更新。恢复模式下无法解析的文件只删除它的诊断信息。
+// path 不存在时返回错误。
+func (p *ThriftParser) RemoveFile(path string) error {
+	absPath := p.absPath(path)
+	filename := uri.File(absPath).Filename()
+	_, failed := p.diagnostics[filename]
+	delete(p.diagnostics, filename)
+	index := p.fileIndex(filename)
+	if index < 0 {
+		if failed {
+			return nil
+		}
+		return fmt.Errorf("file %s not found", absPath)
+	}
+
+	// include 了它的文件要在删除之前计算，删除之后 include 路径可能解析到其它文件。
+	includers := p.includersOf(filename)
+
+	fileURI := p.files[index].URI
+	p.files = append(p.files[:index], p.files[index+1:]...)
+	delete(p.fileAsts, filename)
+	delete(p.sources, filename)
+	// 快照的文件系统不支持删除，用空内容覆盖，避免 thrift-ls 读到旧的定义。
+	if err := p.overlay.Update(context.TODO(), []*cache.FileChange{{URI: fileURI, From: cache.FileChangeTypeDidOpen}}); err != nil {
+		return fmt.Errorf("remove %s: %w", absPath, err)
+	}
+	p.snapshot.ForgetFile(fileURI)
+
+	return p.retransform(includers, filename)
+}
+
+func (p *ThriftParser) absPath(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.rootDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// fileIndex 返回 filename 在 p.files 中的下标，不存在时返回 -1。
+func (p *ThriftParser) fileIndex(filename string) int {
+	for i, fc := range p.files {
+		if fc.URI.Filename() == filename {
+			return i
+		}
+	}
+	return -1
+}
+
+// includersOf 返回 include 了 filename 的所有文件。
+func (p *ThriftParser) includersOf(filename string) []string {
+	var res []string
+	for _, fc := range p.files {
+		doc := p.fileAsts[fc.URI.Filename()]
+		if doc == nil {
+			continue
+		}
+		for _, inc := range doc.Includes {
+			if inc.Path == nil || inc.Path.Value == nil {
+				continue
+			}
+			if p.resolveInclude(fc.URI, inc.Path.Value.Text).Filename() == filename {
+				res = append(res, fc.URI.Filename())
+				break
+			}
+		}
+	}
+	return res
+}
+
+// retransform 在 ParseIDLs 已经生成 schema 时，重新转换 filenames 中的文件并删除 removed 对应的文件。
+func (p *ThriftParser) retransform(filenames []string, removed string) error {
+	if p.schema == nil {
+		return nil
+	}
+	schema := p.schema
+
+	if removed != "" {
+		relPath := p.relativePath(removed)
+		for i := range schema.Files {
+			if schema.Files[i].Path == relPath {
+				schema.Files = append(schema.Files[:i], schema.Files[i+1:]...)
+				break
+			}
+		}
+	}
+
+	changed := make(map[string]bool)
+	for _, filename := range filenames {
+		if changed[filename] {
+			continue
+		}
+		changed[filename] = true
+
+		idlFile, err := p.transformFile(p.files[p.fileIndex(filename)])
+		if err != nil {
+			return err
+		}
+		if p.opts.NoLocation {
+			tmp := &idl_ast.IDLSchema{Files: []idl_ast.File{*idlFile}}
+			removeLocationsInSchema(tmp)
+			idlFile = &tmp.Files[0]
+		}
+
+		replaced := false
+		for i := range schema.Files {
+			if schema.Files[i].Path == idlFile.Path {
+				schema.Files[i] = *idlFile
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			schema.Files = append(schema.Files, *idlFile)
+		}
+	}
+
+	schema.Reindex()
+	schema.ResolveConstantReferences()
+	for i := range schema.Files {
+		if changed[filepath.Join(p.rootDir, schema.Files[i].Path)] {
+			if err := schema.Files[i].UpdateChecksums(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+// holderRefs 是 Holder 两个字段的类型 FQN。
+type holderRefs struct {
+	entity, extra string
+}
+
+func TestParser_UpdateFile(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"common/base.thrift": []byte(`
+struct Entity {
+  1: string id
+}
+`),
+		"main.thrift": []byte(`
+include "common/base.thrift"
+include "common/extra.thrift"
+
+const base.Entity DEFAULT = {"id": "0"}
+
+struct Holder {
+  1: base.Entity entity
+  2: extra.Extra extra
+}
+`),
+		"other.thrift": []byte(`
+struct Other {
+  1: string name
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	holder := func() holderRefs {
+		holders := schema.FindMessagesByFQN("main.thrift#Holder")
+		require.Len(t, holders, 1)
+		return holderRefs{entity: holders[0].Fields[0].Type.FullyQualifiedName, extra: holders[0].Fields[1].Type.FullyQualifiedName}
+	}
+	others := schema.FindMessagesByFQN("other.thrift#Other")
+	require.Len(t, others, 1)
+	other := others[0]
+
+	assert.Equal(t, holderRefs{entity: "common/base.thrift#Entity"}, holder())
+
+	// 修改被 include 的文件：Entity 改名后 main.thrift 中的引用不再能解析。
+	require.NoError(t, p.UpdateFile("common/base.thrift", []byte(`
+struct Record {
+  1: string id
+}
+`)))
+	assert.Len(t, schema.Files, 3)
+	assert.Len(t, schema.FindMessagesByFQN("common/base.thrift#Record"), 1)
+	assert.Empty(t, schema.FindMessagesByFQN("common/base.thrift#Entity"))
+	assert.Equal(t, holderRefs{}, holder())
+	// 与修改无关的文件不会被重新转换。
+	assert.Same(t, other, schema.FindMessagesByFQN("other.thrift#Other")[0])
+
+	// 新增一个之前找不到的 include 目标。
+	require.NoError(t, p.UpdateFile("common/extra.thrift", []byte(`
+struct Extra {
+  1: i32 n
+}
+`)))
+	assert.Len(t, schema.Files, 4)
+	assert.Equal(t, holderRefs{extra: "common/extra.thrift#Extra"}, holder())
+
+	// 恢复 Entity，常量中的引用也重新解析。
+	require.NoError(t, p.UpdateFile("common/base.thrift", []byte(`
+struct Entity {
+  1: string id
+}
+`)))
+	assert.Equal(t, holderRefs{entity: "common/base.thrift#Entity", extra: "common/extra.thrift#Extra"}, holder())
+	consts := schema.FindConstantsByFQN("main.thrift#DEFAULT")
+	require.Len(t, consts, 1)
+	assert.Equal(t, "common/base.thrift#Entity", consts[0].Type.FullyQualifiedName)
+
+	// 删除文件。
+	require.NoError(t, p.RemoveFile("common/extra.thrift"))
+	assert.Len(t, schema.Files, 3)
+	assert.Equal(t, holderRefs{entity: "common/base.thrift#Entity"}, holder())
+	assert.Error(t, p.RemoveFile("common/extra.thrift"))
+
+	// 语法错误不会改变已有的结果。
+	assert.Error(t, p.UpdateFile("main.thrift", []byte(`struct {`)))
+	assert.Equal(t, holderRefs{entity: "common/base.thrift#Entity"}, holder())
+
+	// 增量更新的结果与重新解析一致。
+	again, err := p.ParseIDLs()
+	require.NoError(t, err)
+	assert.Same(t, schema, again)
+	for i := range schema.Files {
+		for _, def := range schema.Files[i].Definitions.Ordered() {
+			modified, err := idl_ast.IsModified(def)
+			require.NoError(t, err)
+			assert.False(t, modified, schema.Files[i].Path)
+		}
+	}
+}
+
+func TestParser_UpdateFileBeforeParse(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"a.thrift": []byte(`struct A {}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, p.UpdateFile("b.thrift", []byte(`
+include "a.thrift"
+
+struct B {
+  1: a.A a
+}
+`)))
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	bs := schema.FindMessagesByFQN("b.thrift#B")
+	require.Len(t, bs, 1)
+	assert.Equal(t, "a.thrift#A", bs[0].Fields[0].Type.FullyQualifiedName)
+}
@@ -0,0 +1,85 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+func TestIDLSchema_ResolveService(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"common/base.thrift": []byte(`
+service Root {
+  void ping()
+  string version()
+}
+
+service Base extends Root {
+  string version()
+  void health()
+}
+`),
+		"main.thrift": []byte(`
+include "common/base.thrift"
+
+service Main extends base.Base {
+  void health(1: i32 level)
+  void hello()
+  void hello(1: string name)
+}
+
+service LoopA extends LoopB {}
+service LoopB extends LoopA {}
+
+service Broken extends missing.Service {}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	res, err := schema.ResolveService("main.thrift#Main")
+	require.NoError(t, err)
+
+	var chain []string
+	for _, s := range res.Chain {
+		chain = append(chain, s.FullyQualifiedName)
+	}
+	assert.Equal(t, []string{"main.thrift#Main", "common/base.thrift#Base", "common/base.thrift#Root"}, chain)
+
+	var functions []string
+	for _, f := range res.Functions {
+		functions = append(functions, f.Service.Name+"."+f.Function.Name)
+	}
+	assert.Equal(t, []string{"Root.ping", "Base.version", "Main.health", "Main.hello"}, functions)
+
+	fn, ok := res.Function("hello")
+	require.True(t, ok)
+	assert.Empty(t, fn.Function.Parameters)
+
+	type conflict struct {
+		kind             idl_ast.FunctionConflictKind
+		function, hidden string
+	}
+	var conflicts []conflict
+	for _, c := range res.Conflicts {
+		conflicts = append(conflicts, conflict{c.Kind, c.Function.Function.FullyQualifiedName, c.Hidden.Function.FullyQualifiedName})
+	}
+	assert.Equal(t, []conflict{
+		{idl_ast.FunctionDuplicated, "main.thrift#Main.hello", "main.thrift#Main.hello"},
+		{idl_ast.FunctionOverridden, "main.thrift#Main.health", "common/base.thrift#Base.health"},
+		{idl_ast.FunctionOverridden, "common/base.thrift#Base.version", "common/base.thrift#Root.version"},
+	}, conflicts)
+
+	_, err = schema.ResolveService("main.thrift#LoopA")
+	assert.EqualError(t, err, "service inheritance cycle: main.thrift#LoopA -> main.thrift#LoopB -> main.thrift#LoopA")
+
+	_, err = schema.ResolveService("main.thrift#Broken")
+	assert.EqualError(t, err, `cannot resolve extends "missing.Service" of service "main.thrift#Broken"`)
+
+	_, err = schema.ResolveService("main.thrift#Nope")
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package thriftparser
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 
@@ -13,6 +14,12 @@ import (
 type Options struct {
 	NoLocation bool
 	NoComments bool
+	// IncludePaths 是 include 的搜索路径，相当于 Thrift 编译器的 `-I`。
+	IncludePaths []string
+	// EntryFiles 非空时，只解析这些入口文件及其 include 的传递闭包。
+	EntryFiles []string
+	// Recover 为 true 时，无法解析的文件不会导致整个解析失败，而是记录到 Diagnostics 中。
+	Recover bool
 }
 
 type Option func(*Options)
@@ -29,6 +36,33 @@ func WithNoComments(noComments bool) Option {
 	}
 }
 
+// WithIncludePaths 设置 include 的搜索路径，相当于 `thrift -I idl/ -I third_party/`。
+// `include "base.thrift"` 先相对于当前文件所在的目录查找，找不到时按顺序在这些目录中查找。
+// 相对路径相对于 rootDir；被 include 的文件需要位于 rootDir 之下（或者在 NewParserFromMap 的 fileMap 中）。
+func WithIncludePaths(dirs ...string) Option {
+	return func(o *Options) {
+		o.IncludePaths = append(o.IncludePaths, dirs...)
+	}
+}
+
+// WithEntryFiles 只解析指定的入口文件以及它们通过 include 传递依赖的文件，而不是 rootDir 下的所有
+// .thrift 文件。相对路径相对于 rootDir。闭包中有找不到的 include 时，NewParser / NewParserFromMap /
+// NewParserFromFS 返回 *MissingIncludesError，其中列出每个 include 所在的文件和位置；
+// 同时开启 WithRecover 时不返回错误，找不到的 include 通过 ThriftParser.Diagnostics 获取。
+func WithEntryFiles(files ...string) Option {
+	return func(o *Options) {
+		o.EntryFiles = append(o.EntryFiles, files...)
+	}
+}
+
+// WithRecover 开启恢复模式：某个文件存在语法错误时，其余能够解析的文件仍然生成 IDLSchema，
+// 无法解析的文件及其错误所在的行列通过 ThriftParser.Diagnostics 获取。
+func WithRecover(recover bool) Option {
+	return func(o *Options) {
+		o.Recover = recover
+	}
+}
+
 type ThriftParser struct {
 	rootDir     string
 	opts        *Options
@@ -36,7 +70,18 @@ type ThriftParser struct {
 	files       []*cache.FileChange
 	relationMap map[string][]byte
 	fileAsts    map[string]*parser.Document
-	schema      *idl_ast.IDLSchema
+	// sources 是各文件的原始内容，键与 fileAsts 相同，fileAsts 中的位置都指向它。
+	// files 中交给 thrift-ls 的内容可能经过修正，见 thriftsrc.Parse。
+	sources map[string][]byte
+	schema  *idl_ast.IDLSchema
+	// includePaths 是转换为绝对路径后的 Options.IncludePaths。
+	includePaths []string
+	// overlay 是快照底层的内存文件系统，UpdateFile / RemoveFile 通过它更新文件内容。
+	overlay interface {
+		Update(ctx context.Context, changes []*cache.FileChange) error
+	}
+	// diagnostics 记录恢复模式下无法解析的文件，键为文件的绝对路径。
+	diagnostics map[string][]Diagnostic
 }
 
 func NewParser(rootDir string, opts ...Option) (tt *ThriftParser, err error) {
@@ -59,10 +104,24 @@ func NewParser(rootDir string, opts ...Option) (tt *ThriftParser, err error) {
 		rootDir:     rootDir,
 		relationMap: make(map[string][]byte),
 		fileAsts:    make(map[string]*parser.Document),
+		sources:     make(map[string][]byte),
+		diagnostics: make(map[string][]Diagnostic),
 		opts:        defaultOptions,
 	}
+	t.includePaths = absIncludePaths(rootDir, defaultOptions.IncludePaths)
 
-	snapshot, files, err := t.buildSnapshot(rootDir, rootDir)
+	var snapshot *cache.Snapshot
+	var files []*cache.FileChange
+	if len(defaultOptions.EntryFiles) > 0 {
+		var fileMap map[string][]byte
+		fileMap, err = t.loadEntryClosure(defaultOptions.EntryFiles, diskReader)
+		if err != nil {
+			return nil, err
+		}
+		snapshot, files, err = t.buildSnapshotWithMap(rootDir, fileMap)
+	} else {
+		snapshot, files, err = t.buildSnapshot(rootDir, rootDir)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -84,20 +143,23 @@ func (p *ThriftParser) ParseIDLs() (*idl_ast.IDLSchema, error) {
 	}
 
 	for _, fileChange := range p.files {
-		parsedFile, ok := p.fileAsts[fileChange.URI.Filename()]
-		if !ok {
-			return nil, fmt.Errorf("failed to get parsed file for %s", fileChange.URI.Filename())
-		}
-
-		idlFile, err := transform(parsedFile, fileChange.Content, fileChange.URI, p.rootDir, p.snapshot)
+		idlFile, err := p.transformFile(fileChange)
 		if err != nil {
-			return nil, fmt.Errorf("failed to transform ast for %s: %w", fileChange.URI.Filename(), err)
+			return nil, err
 		}
 		if idlFile != nil {
 			schema.Files = append(schema.Files, *idlFile)
 		}
 	}
 
+	// 常量中的标识符要在所有文件都转换完成后才能解析，校验和需要在此之后计算。
+	schema.ResolveConstantReferences()
+	for i := range schema.Files {
+		if err := schema.Files[i].UpdateChecksums(); err != nil {
+			return nil, err
+		}
+	}
+
 	if p.opts.NoLocation {
 		removeLocationsInSchema(schema)
 	}
@@ -106,7 +168,43 @@ func (p *ThriftParser) ParseIDLs() (*idl_ast.IDLSchema, error) {
 	return p.schema, nil
 }
 
+// transformFile 把快照中的一个文件转换为 idl_ast.File。
+func (p *ThriftParser) transformFile(fileChange *cache.FileChange) (*idl_ast.File, error) {
+	parsedFile, ok := p.fileAsts[fileChange.URI.Filename()]
+	if !ok {
+		return nil, fmt.Errorf("failed to get parsed file for %s", fileChange.URI.Filename())
+	}
+
+	idlFile, err := p.transform(parsedFile, p.sources[fileChange.URI.Filename()], fileChange.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transform ast for %s: %w", fileChange.URI.Filename(), err)
+	}
+	return idlFile, nil
+}
+
 func NewParserFromMap(rootDir string, fileMap map[string][]byte, opts ...Option) (*ThriftParser, error) {
+	t := newLogicalParser(rootDir, opts)
+
+	if len(t.opts.EntryFiles) > 0 {
+		closure, err := t.loadEntryClosure(t.opts.EntryFiles, mapReader(t.rootDir, fileMap))
+		if err != nil {
+			return nil, err
+		}
+		fileMap = closure
+	}
+
+	snapshot, files, err := t.buildSnapshotWithMap(t.rootDir, fileMap)
+	if err != nil {
+		return nil, err
+	}
+	t.snapshot = snapshot
+	t.files = files
+
+	return t, nil
+}
+
+// newLogicalParser 创建一个不直接对应文件系统目录的解析器，rootDir 只作为逻辑上的根目录。
+func newLogicalParser(rootDir string, opts []Option) *ThriftParser {
 	if !filepath.IsAbs(rootDir) {
 		rootDir = "/" + rootDir
 	}
@@ -125,15 +223,10 @@ func NewParserFromMap(rootDir string, fileMap map[string][]byte, opts ...Option)
 		rootDir:     rootDir, // 直接使用传入的（清理过的）rootDir
 		relationMap: make(map[string][]byte),
 		fileAsts:    make(map[string]*parser.Document),
+		sources:     make(map[string][]byte),
+		diagnostics: make(map[string][]Diagnostic),
 		opts:        defaultOptions,
 	}
-
-	snapshot, files, err := t.buildSnapshotWithMap(rootDir, fileMap)
-	if err != nil {
-		return nil, err
-	}
-	t.snapshot = snapshot
-	t.files = files
-
-	return t, nil
+	t.includePaths = absIncludePaths(rootDir, defaultOptions.IncludePaths)
+	return t
 }
@@ -0,0 +1,133 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recoverTestFiles() map[string][]byte {
+	return map[string][]byte{
+		"main.thrift": []byte(`include "base.thrift"
+include "broken.thrift"
+
+struct Request {
+  1: base.Base base
+  2: broken.Item item
+  3: list<Missing> missing
+}
+`),
+		"base.thrift": []byte(`
+struct Base {
+  1: string id
+}
+`),
+		"broken.thrift": []byte(`struct Item {
+  1: string name
+}
+
+struct Other {
+  1: i32 x =
+}
+`),
+	}
+}
+
+func TestParser_WithRecover(t *testing.T) {
+	_, err := NewParserFromMap("project", recoverTestFiles())
+	require.Error(t, err)
+
+	p, err := NewParserFromMap("project", recoverTestFiles(), WithRecover(true))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	assert.Len(t, schema.Files, 2)
+
+	diags := p.Diagnostics()
+	require.Len(t, diags, 1)
+	assert.Equal(t, "broken.thrift", diags[0].File)
+	require.NotNil(t, diags[0].Location)
+	assert.Equal(t, 6, diags[0].Location.Start.Line)
+	assert.NotEmpty(t, diags[0].Message)
+
+	requests := schema.FindMessagesByFQN("main.thrift#Request")
+	require.Len(t, requests, 1)
+	fields := requests[0].Fields
+	assert.Equal(t, "base.thrift#Base", fields[0].Type.FullyQualifiedName)
+	assert.False(t, fields[0].Type.Unresolved)
+	assert.Empty(t, fields[1].Type.FullyQualifiedName)
+	assert.True(t, fields[1].Type.Unresolved)
+	assert.False(t, fields[2].Type.Unresolved)
+	assert.True(t, fields[2].Type.ValueType.Unresolved)
+}
+
+func TestParser_WithRecoverUpdateFile(t *testing.T) {
+	p, err := NewParserFromMap("project", recoverTestFiles(), WithRecover(true))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	// 修复语法错误后，文件加入 schema，引用它的类型重新解析。
+	require.NoError(t, p.UpdateFile("broken.thrift", []byte(`struct Item {
+  1: string name
+}
+`)))
+	assert.Empty(t, p.Diagnostics())
+	assert.Len(t, schema.Files, 3)
+	item := schema.FindMessagesByFQN("main.thrift#Request")[0].Fields[1].Type
+	assert.Equal(t, "broken.thrift#Item", item.FullyQualifiedName)
+	assert.False(t, item.Unresolved)
+
+	// 再次改坏时，文件从 schema 中移除并重新记录诊断信息。
+	require.NoError(t, p.UpdateFile("base.thrift", []byte(`struct Base {`)))
+	assert.Len(t, schema.Files, 2)
+	diags := p.Diagnostics()
+	require.NotEmpty(t, diags)
+	for _, d := range diags {
+		assert.Equal(t, "base.thrift", d.File)
+	}
+	assert.True(t, schema.FindMessagesByFQN("main.thrift#Request")[0].Fields[0].Type.Unresolved)
+
+	require.NoError(t, p.RemoveFile("base.thrift"))
+	assert.Empty(t, p.Diagnostics())
+}
+
+func TestParser_WithRecoverEntryFiles(t *testing.T) {
+	files := map[string][]byte{
+		"main.thrift": []byte(`include "base.thrift"
+include "gone.thrift"
+
+struct Request {
+  1: base.Base base
+  2: gone.Item item
+}
+`),
+		"base.thrift": []byte(`include "lib/missing.thrift"
+
+struct Base {
+  1: string id
+}
+`),
+	}
+
+	p, err := NewParserFromMap("project", files, WithEntryFiles("main.thrift"), WithRecover(true))
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	assert.Len(t, schema.Files, 2)
+
+	diags := p.Diagnostics()
+	require.Len(t, diags, 2)
+	assert.Equal(t, "base.thrift", diags[0].File)
+	assert.Equal(t, `include "lib/missing.thrift" not found`, diags[0].Message)
+	assert.Equal(t, "main.thrift", diags[1].File)
+	assert.Equal(t, `include "gone.thrift" not found`, diags[1].Message)
+	require.NotNil(t, diags[1].Location)
+	assert.Equal(t, 2, diags[1].Location.Start.Line)
+
+	fields := schema.FindMessagesByFQN("main.thrift#Request")[0].Fields
+	assert.Equal(t, "base.thrift#Base", fields[0].Type.FullyQualifiedName)
+	assert.False(t, fields[0].Type.Unresolved)
+	assert.True(t, fields[1].Type.Unresolved)
+}
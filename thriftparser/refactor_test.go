@@ -0,0 +1,327 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/Skyenought/idlanalyzer/thriftwriter"
+)
+
+var refactorTestFiles = map[string][]byte{
+	"common/base.thrift": []byte(`
+enum Status {
+  OK = 0,
+  ERROR = 1
+}
+
+struct Entity {
+  1: string id
+  2: Status status = Status.OK
+}
+
+service BaseService {
+  void ping()
+}
+`),
+	"main.thrift": []byte(`
+include "common/base.thrift"
+
+const base.Status DEFAULT_STATUS = base.Status.ERROR
+
+struct Holder {
+  1: list<base.Entity> entities
+  2: base.Status status = base.Status.OK
+}
+
+service Main extends base.BaseService {
+  base.Entity get(1: base.Entity req)
+}
+`),
+}
+
+func TestIDLSchema_Rename(t *testing.T) {
+	p, err := NewParserFromMap("project", refactorTestFiles)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	require.NoError(t, schema.Rename("common/base.thrift#Entity", "Record"))
+	require.NoError(t, schema.Rename("common/base.thrift#Status", "State"))
+	require.NoError(t, schema.Rename("common/base.thrift#BaseService", "Root"))
+
+	assert.Empty(t, schema.FindStructsByFQN("Entity"))
+	require.Len(t, schema.FindStructsByFQN("common/base.thrift#Record"), 1)
+	assert.Len(t, schema.FindReferences("common/base.thrift#Record"), 3)
+	assert.Len(t, schema.FindFunctionsByFQN("common/base.thrift#Root.ping"), 1)
+
+	assert.EqualError(t, schema.Rename("main.thrift#Holder", "Main"), `definition "main.thrift#Main" already exists`)
+	assert.Error(t, schema.Rename("main.thrift#Holder", "1Holder"))
+	assert.Error(t, schema.Rename("main.thrift#Missing", "Other"))
+
+	files, err := thriftwriter.Generate(schema)
+	require.NoError(t, err)
+	base := string(files["common/base.thrift"])
+	assert.Contains(t, base, "struct Record {")
+	assert.Contains(t, base, "2: State status = State.OK,")
+	assert.Contains(t, base, "service Root {")
+
+	main := string(files["main.thrift"])
+	assert.Contains(t, main, "const base.State DEFAULT_STATUS = base.State.ERROR")
+	assert.Contains(t, main, "1: list<base.Record> entities,")
+	assert.Contains(t, main, "2: base.State status = base.State.OK,")
+	assert.Contains(t, main, "service Main extends base.Root {")
+	assert.Contains(t, main, "base.Record get(1: base.Record req)")
+}
+
+func TestIDLSchema_Move(t *testing.T) {
+	files := map[string][]byte{
+		"common/base.thrift": refactorTestFiles["common/base.thrift"],
+		"main.thrift": []byte(`
+include "common/base.thrift"
+
+struct Profile {
+  1: string name
+  2: base.Status status = base.Status.OK
+}
+
+struct User {
+  1: Profile profile
+  2: list<Profile> history
+}
+`),
+	}
+	p, err := NewParserFromMap("project", files)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	// main.thrift#Profile -> common/base.thrift：main 里的引用要加前缀，Profile 对 Status 的引用去掉前缀。
+	require.NoError(t, schema.Move("main.thrift#Profile", "common/base.thrift"))
+	require.Len(t, schema.FindStructsByFQN("common/base.thrift#Profile"), 1)
+	assert.Empty(t, schema.FindStructsByFQN("main.thrift#Profile"))
+	assert.Len(t, schema.FindReferences("common/base.thrift#Profile"), 2)
+
+	out, err := thriftwriter.Generate(schema)
+	require.NoError(t, err)
+	base := string(out["common/base.thrift"])
+	assert.Contains(t, base, "struct Profile {")
+	assert.Contains(t, base, "2: Status status = Status.OK,")
+	assert.NotContains(t, base, "include")
+	main := string(out["main.thrift"])
+	assert.Contains(t, main, "1: base.Profile profile,")
+	assert.Contains(t, main, "2: list<base.Profile> history,")
+
+	// 移动 Status 到一个新文件：base.thrift 需要 include 新文件。
+	require.NoError(t, schema.Move("common/base.thrift#Status", "common/status.thrift"))
+	out, err = thriftwriter.Generate(schema)
+	require.NoError(t, err)
+	base = string(out["common/base.thrift"])
+	assert.Contains(t, base, `include "status.thrift"`)
+	assert.Contains(t, base, "2: status.Status status = status.Status.OK,")
+	assert.Contains(t, string(out["common/status.thrift"]), "enum Status {")
+
+	// 把 Profile 移回 main.thrift：main 不再需要 base 之外的 include，base 里也不再使用 status.thrift。
+	require.NoError(t, schema.Move("common/base.thrift#Profile", "main.thrift"))
+	out, err = thriftwriter.Generate(schema)
+	require.NoError(t, err)
+	main = string(out["main.thrift"])
+	assert.Contains(t, main, "1: Profile profile,")
+	assert.Contains(t, main, "2: status.Status status = status.Status.OK,")
+	assert.Contains(t, main, `include "common/status.thrift"`)
+	assert.NotContains(t, main, `include "common/base.thrift"`)
+	assert.Contains(t, string(out["common/base.thrift"]), `include "status.thrift"`)
+
+	assert.Error(t, schema.Move("main.thrift#Missing", "common/base.thrift"))
+	assert.Error(t, schema.Move("common/base.thrift#BaseService", "main.thrift"))
+}
+
+func TestIDLSchema_MoveIncludeCycle(t *testing.T) {
+	files := map[string][]byte{
+		"common/base.thrift": refactorTestFiles["common/base.thrift"],
+		"main.thrift": []byte(`
+include "common/base.thrift"
+
+struct Detail {
+  1: string text
+}
+
+struct Profile {
+  1: Detail detail
+  2: base.Status status
+}
+
+struct User {
+  1: Profile profile
+}
+`),
+	}
+	p, err := NewParserFromMap("project", files)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	// Profile 依赖留在 main.thrift 中的 Detail，而 User 仍然引用 Profile：
+	// base.thrift 要 include main.thrift，main.thrift 又要 include base.thrift。
+	err = schema.Move("main.thrift#Profile", "common/base.thrift")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "include cycle: common/base.thrift -> main.thrift -> common/base.thrift")
+	// 失败时 schema 保持不变。
+	assert.Len(t, schema.FindStructsByFQN("main.thrift#Profile"), 1)
+	assert.Empty(t, schema.FindStructsByFQN("common/base.thrift#Profile"))
+	out, err := thriftwriter.Generate(schema)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out["common/base.thrift"]), "include")
+
+	// 先把 User 移走后，main.thrift 不再使用 base.thrift，它的 include 会被删除，不会形成循环。
+	require.NoError(t, schema.Move("main.thrift#User", "user.thrift"))
+	require.NoError(t, schema.Move("main.thrift#Profile", "common/base.thrift"))
+	out, err = thriftwriter.Generate(schema)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out["main.thrift"]), "include")
+	assert.Contains(t, string(out["common/base.thrift"]), `include "../main.thrift"`)
+}
+
+func TestIDLSchema_Prune(t *testing.T) {
+	files := map[string][]byte{
+		"common/base.thrift": refactorTestFiles["common/base.thrift"],
+		"common/unused.thrift": []byte(`
+struct Unused {
+  1: string id
+}
+`),
+		"main.thrift": []byte(`
+include "common/base.thrift"
+include "common/unused.thrift"
+
+const i32 LIMIT = 10
+
+struct Query {
+  1: i32 limit = LIMIT
+}
+
+struct Report {
+  1: unused.Unused data
+}
+
+service Main extends base.BaseService {
+  list<base.Entity> search(1: Query q)
+  Report report()
+}
+`),
+	}
+	p, err := NewParserFromMap("project", files)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	pruned, err := schema.Prune("main.thrift#Main.search")
+	require.NoError(t, err)
+
+	// 原 schema 不受影响
+	assert.Len(t, schema.Files, 3)
+	assert.Len(t, schema.FindStructsByFQN("main.thrift#Report"), 1)
+
+	require.Len(t, pruned.Files, 2)
+	assert.Len(t, pruned.FindStructsByFQN("main.thrift#Query"), 1)
+	assert.Len(t, pruned.FindConstantsByFQN("main.thrift#LIMIT"), 1)
+	assert.Len(t, pruned.FindStructsByFQN("common/base.thrift#Entity"), 1)
+	assert.Len(t, pruned.FindEnumsByFQN("common/base.thrift#Status"), 1)
+	assert.Len(t, pruned.FindServicesByFQN("common/base.thrift#BaseService"), 1)
+	assert.Empty(t, pruned.FindStructsByFQN("main.thrift#Report"))
+	assert.Empty(t, pruned.FindFunctionsByFQN("main.thrift#Main.report"))
+
+	out, err := thriftwriter.Generate(pruned)
+	require.NoError(t, err)
+	main := string(out["main.thrift"])
+	assert.Contains(t, main, `include "common/base.thrift"`)
+	assert.NotContains(t, main, "unused")
+	assert.NotContains(t, main, "report")
+	_, ok := out["common/unused.thrift"]
+	assert.False(t, ok)
+
+	// 只需要一个枚举时，不相关的 struct 和 service 都会被删除
+	pruned, err = schema.Prune("common/base.thrift#Status")
+	require.NoError(t, err)
+	require.Len(t, pruned.Files, 1)
+	assert.Empty(t, pruned.FindServicesByFQN("common/base.thrift#BaseService"))
+
+	_, err = schema.Prune("main.thrift#Missing")
+	assert.Error(t, err)
+}
+
+func TestIDLSchema_DeclarationOrder(t *testing.T) {
+	p, err := NewParserFromMap("project", refactorTestFiles)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	orderOf := func(path string) []string {
+		var names []string
+		for _, ref := range schema.Files[fileIndex(schema, path)].Definitions.Order {
+			names = append(names, string(ref.Kind)+" "+ref.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"enum Status", "message Entity", "service BaseService"}, orderOf("common/base.thrift"))
+	assert.Equal(t, []string{"constant DEFAULT_STATUS", "message Holder", "service Main"}, orderOf("main.thrift"))
+
+	require.NoError(t, schema.Rename("main.thrift#Holder", "Box"))
+	assert.Equal(t, []string{"constant DEFAULT_STATUS", "message Box", "service Main"}, orderOf("main.thrift"))
+
+	// 移动的定义从源文件的 Order 中删除，追加到目标文件的末尾。
+	require.NoError(t, schema.Move("common/base.thrift#Entity", "main.thrift"))
+	assert.Equal(t, []string{"enum Status", "service BaseService"}, orderOf("common/base.thrift"))
+	assert.Equal(t, []string{"constant DEFAULT_STATUS", "message Box", "service Main", "message Entity"}, orderOf("main.thrift"))
+
+	pruned, err := schema.Prune("main.thrift#Box")
+	require.NoError(t, err)
+	var names []string
+	for _, def := range pruned.Files[fileIndex(pruned, "main.thrift")].Definitions.Ordered() {
+		names = append(names, idl_ast.RefOf(def).Name)
+	}
+	assert.Equal(t, []string{"Box", "Entity"}, names)
+}
+
+func fileIndex(schema *idl_ast.IDLSchema, path string) int {
+	for i := range schema.Files {
+		if schema.Files[i].Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestIDLSchema_RenameAfterEdit(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"main.thrift": []byte(`
+struct A {
+  1: string x
+}
+
+struct B {
+  1: A a
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	require.Len(t, schema.FindReferences("main.thrift#A"), 1)
+
+	// 追加字段会让 Fields 换到新的底层数组，之前收集的引用随之失效。
+	b := schema.FindMessagesByFQN("main.thrift#B")[0]
+	b.Fields = append(b.Fields, idl_ast.Field{
+		ID:   2,
+		Name: "other",
+		Type: idl_ast.Type{Name: "A", FullyQualifiedName: "main.thrift#A"},
+	})
+
+	require.NoError(t, schema.Rename("main.thrift#A", "C"))
+	for _, f := range b.Fields {
+		assert.Equal(t, "C", f.Type.Name)
+		assert.Equal(t, "main.thrift#C", f.Type.FullyQualifiedName)
+	}
+}
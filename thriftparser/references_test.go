@@ -0,0 +1,181 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/Skyenought/idlanalyzer/thriftwriter"
+)
+
+var referenceTestFiles = map[string][]byte{
+	"common/base.thrift": []byte(`
+struct Entity {
+  1: string id
+}
+
+exception BaseError {
+  1: string message
+}
+
+service BaseService {
+  void ping()
+}
+`),
+	"main.thrift": []byte(`
+include "common/base.thrift"
+
+typedef base.Entity EntityAlias
+
+const base.Entity DEFAULT_ENTITY = {"id": "0"}
+
+struct Holder {
+  1: list<base.Entity> entities
+  2: map<string, base.Entity> byID
+}
+
+service Main extends base.BaseService {
+  base.Entity get(1: base.Entity req) throws (1: base.BaseError err)
+}
+`),
+}
+
+func TestIDLSchema_FindReferences(t *testing.T) {
+	p, err := NewParserFromMap("project", referenceTestFiles)
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	refs := schema.FindReferences("common/base.thrift#Entity")
+	got := make(map[idl_ast.ReferenceKind][]string)
+	for _, ref := range refs {
+		assert.Equal(t, "main.thrift", ref.File)
+		assert.NotNil(t, ref.Location)
+		require.NotNil(t, ref.Type)
+		assert.Equal(t, "base.Entity", ref.Type.Name)
+		got[ref.Kind] = append(got[ref.Kind], ref.Referrer)
+	}
+	assert.Equal(t, map[idl_ast.ReferenceKind][]string{
+		idl_ast.ReferenceField:      {"main.thrift#Holder.entities", "main.thrift#Holder.byID"},
+		idl_ast.ReferenceParameter:  {"main.thrift#Main.get.req"},
+		idl_ast.ReferenceReturnType: {"main.thrift#Main.get"},
+		idl_ast.ReferenceTypedef:    {"main.thrift#EntityAlias"},
+		idl_ast.ReferenceConstant:   {"main.thrift#DEFAULT_ENTITY"},
+	}, got)
+
+	throws := schema.FindReferences("common/base.thrift#BaseError")
+	require.Len(t, throws, 1)
+	assert.Equal(t, idl_ast.ReferenceThrows, throws[0].Kind)
+	assert.Equal(t, "main.thrift#Main.get.err", throws[0].Referrer)
+
+	extends := schema.FindReferences("common/base.thrift#BaseService")
+	require.Len(t, extends, 1)
+	assert.Equal(t, idl_ast.ReferenceExtends, extends[0].Kind)
+	assert.Equal(t, "main.thrift#Main", extends[0].Referrer)
+	assert.Nil(t, extends[0].Type)
+
+	assert.Empty(t, schema.FindReferences("main.thrift#Holder"))
+
+	// 新增字段、修改参数类型后，查询结果立即反映当前的 AST。
+	holder := schema.FindMessagesByFQN("main.thrift#Holder")[0]
+	holder.Fields = append(holder.Fields, idl_ast.Field{
+		ID:   3,
+		Name: "first",
+		Type: idl_ast.Type{Name: "base.Entity", FullyQualifiedName: "common/base.thrift#Entity"},
+	})
+	get := schema.FindFunctionsByFQN("main.thrift#Main.get")[0]
+	get.Parameters[0].Type = idl_ast.Type{Name: "string", IsPrimitive: true}
+	got = make(map[idl_ast.ReferenceKind][]string)
+	for _, ref := range schema.FindReferences("common/base.thrift#Entity") {
+		got[ref.Kind] = append(got[ref.Kind], ref.Referrer)
+	}
+	assert.Equal(t, []string{"main.thrift#Holder.entities", "main.thrift#Holder.byID", "main.thrift#Holder.first"}, got[idl_ast.ReferenceField])
+	assert.Empty(t, got[idl_ast.ReferenceParameter])
+}
+
+func TestIDLSchema_ResolveConstantReferences(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"common/base.thrift": []byte(`
+enum Status {
+  OK = 0,
+  ERROR = 1
+}
+`),
+		"main.thrift": []byte(`
+include "common/base.thrift"
+
+const i32 LIMIT = 10
+const double RATIO = 0.5
+const bool ENABLED = true
+const i32 T = 1
+const i32 FROM_T = T
+const string NAME = "Status.OK"
+const base.Status STATUS = base.Status.ERROR
+const list<i32> LIMITS = [LIMIT, 20]
+const map<string, base.Status> BY_NAME = {"ok": base.Status.OK, "missing": Unknown.VALUE}
+
+struct Query {
+  1: i32 limit = LIMIT
+  2: i32 t = T
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	constant := func(name string) *idl_ast.ConstantValue {
+		res := schema.FindConstantsByFQN("main.thrift#" + name)
+		require.Len(t, res, 1)
+		return res[0].Value
+	}
+	assert.Equal(t, &idl_ast.ConstantValue{Kind: idl_ast.ConstantInt, Value: int64(10)}, constant("LIMIT"))
+	assert.Equal(t, &idl_ast.ConstantValue{Kind: idl_ast.ConstantDouble, Value: 0.5}, constant("RATIO"))
+	assert.Equal(t, &idl_ast.ConstantValue{Kind: idl_ast.ConstantBool, Value: true}, constant("ENABLED"))
+	// 名为 T 的常量是标识符，而不是布尔值。
+	assert.Equal(t, &idl_ast.ConstantValue{
+		Kind:               idl_ast.ConstantIdentifier,
+		Value:              "T",
+		FullyQualifiedName: "main.thrift#T",
+	}, constant("FROM_T"))
+	// 字符串字面量不会被当作标识符解析。
+	assert.Equal(t, &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"Status.OK"`}, constant("NAME"))
+	assert.Equal(t, &idl_ast.ConstantValue{
+		Kind:               idl_ast.ConstantIdentifier,
+		Value:              "base.Status.ERROR",
+		FullyQualifiedName: "common/base.thrift#Status.ERROR",
+	}, constant("STATUS"))
+
+	limits := constant("LIMITS")
+	assert.Equal(t, idl_ast.ConstantList, limits.Kind)
+	assert.Equal(t, "main.thrift#LIMIT", limits.Value.([]*idl_ast.ConstantValue)[0].FullyQualifiedName)
+
+	entries := constant("BY_NAME").Value.([]*idl_ast.ConstantMapEntry)
+	require.Len(t, entries, 2)
+	assert.Equal(t, idl_ast.ConstantString, entries[0].Key.Kind)
+	assert.Equal(t, "common/base.thrift#Status.OK", entries[0].Value.FullyQualifiedName)
+	assert.Equal(t, idl_ast.ConstantIdentifier, entries[1].Value.Kind)
+	assert.Empty(t, entries[1].Value.FullyQualifiedName)
+
+	query := schema.FindStructsByFQN("main.thrift#Query")
+	require.Len(t, query, 1)
+	assert.Equal(t, "main.thrift#LIMIT", query[0].Fields[0].DefaultValue.FullyQualifiedName)
+	assert.Equal(t, idl_ast.ConstantIdentifier, query[0].Fields[1].DefaultValue.Kind)
+
+	files, err := thriftwriter.Generate(schema)
+	require.NoError(t, err)
+	assert.Contains(t, string(files["main.thrift"]), "const i32 FROM_T = T")
+	assert.Contains(t, string(files["main.thrift"]), "2: i32 t = T,")
+
+	// 改名和移动之后引用会被重新解析。
+	require.NoError(t, schema.Rename("main.thrift#LIMIT", "MAX"))
+	assert.Equal(t, "main.thrift#MAX", query[0].Fields[0].DefaultValue.FullyQualifiedName)
+	require.NoError(t, schema.Move("common/base.thrift#Status", "main.thrift"))
+	assert.Equal(t, &idl_ast.ConstantValue{
+		Kind:               idl_ast.ConstantIdentifier,
+		Value:              "Status.ERROR",
+		FullyQualifiedName: "main.thrift#Status.ERROR",
+	}, constant("STATUS"))
+}
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/Skyenought/idlanalyzer/idl_ast"
 	"github.com/Skyenought/idlanalyzer/thriftwriter"
 )
@@ -55,3 +59,95 @@ func writeFiles(outputDir string, generatedFiles map[string][]byte) {
 		fmt.Printf("✅ 成功将修改后的内容写入到: %s\n", destPath)
 	}
 }
+
+func TestIDLSchema_IndexIsPerSchema(t *testing.T) {
+	parseMap := func(files map[string][]byte) *idl_ast.IDLSchema {
+		p, err := NewParserFromMap("project", files)
+		require.NoError(t, err)
+		schema, err := p.ParseIDLs()
+		require.NoError(t, err)
+		return schema
+	}
+
+	first := parseMap(map[string][]byte{
+		"a.thrift": []byte(`
+typedef i64 UserID
+enum Status {
+  OK = 0,
+  ERROR = 1
+}
+struct User {
+  1: UserID id
+}`),
+	})
+	second := parseMap(map[string][]byte{
+		"b.thrift": []byte(`struct Order {
+  1: i64 id
+}`),
+	})
+
+	require.Len(t, first.FindStructsByFQN("User"), 1)
+	assert.Empty(t, second.FindStructsByFQN("User"), "索引不应在 schema 之间共享")
+	require.Len(t, second.FindStructsByFQN("Order"), 1)
+
+	typedefs := first.FindTypedefsByFQN("a.thrift#UserID")
+	require.Len(t, typedefs, 1)
+	assert.Equal(t, "UserID", typedefs[0].Alias)
+
+	values := first.FindEnumValuesByFQN("Status.ERROR")
+	require.Len(t, values, 1)
+	assert.Equal(t, 1, values[0].Value)
+
+	// 直接修改 Files 后，Reindex 之前查询的仍是旧索引
+	defs := &second.Files[0].Definitions
+	defs.Messages = append(defs.Messages, idl_ast.Message{
+		Name:               "Refund",
+		FullyQualifiedName: "b.thrift#Refund",
+		Type:               "struct",
+	})
+	assert.Empty(t, second.FindStructsByFQN("Refund"))
+	second.Reindex()
+	assert.Len(t, second.FindStructsByFQN("Refund"), 1)
+
+	defs.Messages[0].FullyQualifiedName = "b.thrift#Purchase"
+	second.Reindex()
+	assert.Empty(t, second.FindStructsByFQN("Order"))
+	assert.Len(t, second.FindStructsByFQN("Purchase"), 1)
+}
+
+func TestIDLSchema_CopyHasOwnIndex(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"a.thrift": []byte(`struct User {
+  1: i64 id
+}`),
+	})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+	require.Len(t, schema.FindStructsByFQN("User"), 1)
+
+	// 浅复制后替换 Files 并 Reindex，不影响原 schema 的索引
+	copied := *schema
+	copied.Files = nil
+	copied.Reindex()
+	assert.Empty(t, copied.FindStructsByFQN("User"))
+	assert.Len(t, schema.FindStructsByFQN("User"), 1)
+}
+
+func TestIDLSchema_ConcurrentFind(t *testing.T) {
+	p, err := NewParser("testdata/thrifts")
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Len(t, schema.FindStructsByFQN("Person"), 1)
+			assert.Len(t, schema.FindEnumValuesByFQN("Status.OK"), 1)
+		}()
+	}
+	wg.Wait()
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/Skyenought/idlanalyzer/idl_ast"
 )
 
+// SortSchema 对每个文件中的定义按依赖关系做拓扑排序，被依赖的定义排在前面，
+// 排序结果同时写入 Definitions.Order。
 func SortSchema(schema *idl_ast.IDLSchema) {
 	for i := range schema.Files {
 		sortFileDefinitions(&schema.Files[i].Definitions)
@@ -44,8 +46,8 @@ func sortFileDefinitions(defs *idl_ast.Definitions) {
 	reverseGraph := make(map[string][]string)
 	inDegree := make(map[string]int)
 
-	for name, def := range allDefs {
-		dependencies := extractDependencies(def)
+	for _, name := range allDefNames {
+		dependencies := extractDependencies(allDefs[name])
 		inDegree[name] = len(dependencies)
 		graph[name] = dependencies
 		for _, depName := range dependencies {
@@ -54,7 +56,7 @@ func sortFileDefinitions(defs *idl_ast.Definitions) {
 	}
 
 	queue := make([]string, 0)
-	for name := range allDefs {
+	for _, name := range allDefNames {
 		if inDegree[name] == 0 {
 			queue = append(queue, name)
 		}
@@ -94,14 +96,19 @@ func sortFileDefinitions(defs *idl_ast.Definitions) {
 		switch v := def.(type) {
 		case idl_ast.Message:
 			newDefs.Messages = append(newDefs.Messages, v)
+			newDefs.Order = append(newDefs.Order, idl_ast.RefOf(&v))
 		case idl_ast.Enum:
 			newDefs.Enums = append(newDefs.Enums, v)
+			newDefs.Order = append(newDefs.Order, idl_ast.RefOf(&v))
 		case idl_ast.Typedef:
 			newDefs.Typedefs = append(newDefs.Typedefs, v)
+			newDefs.Order = append(newDefs.Order, idl_ast.RefOf(&v))
 		case idl_ast.Constant:
 			newDefs.Constants = append(newDefs.Constants, v)
+			newDefs.Order = append(newDefs.Order, idl_ast.RefOf(&v))
 		case idl_ast.Service:
 			newDefs.Services = append(newDefs.Services, v)
+			newDefs.Order = append(newDefs.Order, idl_ast.RefOf(&v))
 		}
 	}
 
@@ -118,26 +125,23 @@ func extractDependencies(def interface{}) []string {
 		}
 	}
 
+	var node any
 	switch v := def.(type) {
 	case idl_ast.Message:
-		for _, field := range v.Fields {
-			extractTypeDependencies(&field.Type, addDep)
-		}
+		node = &v
 	case idl_ast.Typedef:
-		extractTypeDependencies(&v.Type, addDep)
+		node = &v
 	case idl_ast.Constant:
-		extractTypeDependencies(&v.Type, addDep)
+		node = &v
 	case idl_ast.Service:
-		for _, fun := range v.Functions {
-			extractTypeDependencies(&fun.ReturnType, addDep)
-			for _, param := range fun.Parameters {
-				extractTypeDependencies(&param.Type, addDep)
-			}
-			for _, throw := range fun.Throws {
-				extractTypeDependencies(&throw.Type, addDep)
-			}
-		}
+		node = &v
 	}
+	idl_ast.Inspect(node, func(n any) bool {
+		if t, ok := n.(*idl_ast.Type); ok {
+			addDep(t.Name)
+		}
+		return true
+	})
 
 	deps := make([]string, 0, len(depSet))
 	for dep := range depSet {
@@ -145,17 +149,3 @@ func extractDependencies(def interface{}) []string {
 	}
 	return deps
 }
-
-// extractTypeDependencies 是一个递归辅助函数，用于从 Type 结构中提取所有用户定义的类型名称。
-func extractTypeDependencies(t *idl_ast.Type, addDep func(string)) {
-	if t == nil {
-		return
-	}
-	addDep(t.Name)
-	if t.KeyType != nil {
-		extractTypeDependencies(t.KeyType, addDep)
-	}
-	if t.ValueType != nil {
-		extractTypeDependencies(t.ValueType, addDep)
-	}
-}
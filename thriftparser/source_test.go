@@ -0,0 +1,43 @@
+package thriftparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_BareAnnotationsKeepSourceOffsets(t *testing.T) {
+	source := `struct A {
+  1: string a (api.none)
+} (bare)
+
+struct B {
+  1: string b
+}
+
+struct C {
+  1: string c
+}
+`
+	p, err := NewParserFromMap("project", map[string][]byte{"main.thrift": []byte(source)})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	file := schema.Files[0]
+	assert.Equal(t, source, file.Content)
+
+	messages := file.Definitions.Messages
+	require.Len(t, messages, 3)
+	assert.Equal(t, "struct A {\n  1: string a (api.none)\n} (bare)", messages[0].Content)
+	assert.Nil(t, messages[0].Annotations[0].Value)
+	assert.Nil(t, messages[0].Fields[0].Annotations[0].Value)
+
+	c := messages[2]
+	assert.Equal(t, "struct C {\n  1: string c\n}", c.Content)
+	assert.Equal(t, strings.Index(source, "struct C"), c.Location.Start.Offset)
+	assert.Equal(t, 9, c.Location.Start.Line)
+	assert.Equal(t, source[c.Location.Start.Offset:c.Location.End.Offset], c.Content)
+}
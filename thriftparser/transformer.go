@@ -4,18 +4,18 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
-	"strconv"
 
 	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/Skyenought/idlanalyzer/internal/thriftsrc"
 	"github.com/joyme123/thrift-ls/lsp/cache"
 	"github.com/joyme123/thrift-ls/lsp/codejump"
-	"github.com/joyme123/thrift-ls/lsp/lsputils"
 	"github.com/joyme123/thrift-ls/parser"
 	"go.lsp.dev/uri"
 )
 
 // transformContext 用于在转换函数之间传递共享状态和信息
 type transformContext struct {
+	parser     *ThriftParser
 	snapshot   *cache.Snapshot
 	currentURI uri.URI
 	currentAST *parser.Document
@@ -25,18 +25,19 @@ type transformContext struct {
 }
 
 // transform 是主转换函数，将一个 thrift-ls 的 ParsedFile 转换为我们的 idl_ast.File
-func transform(document *parser.Document, source []byte, fileURI uri.URI, rootDir string, snapshot *cache.Snapshot) (*idl_ast.File, error) {
+func (p *ThriftParser) transform(document *parser.Document, source []byte, fileURI uri.URI) (*idl_ast.File, error) {
 	absPath := fileURI.Filename()
-	relPath, err := filepath.Rel(rootDir, absPath)
+	relPath, err := filepath.Rel(p.rootDir, absPath)
 	if err != nil {
 		return nil, fmt.Errorf("could not compute relative path for %s: %w", absPath, err)
 	}
 
 	ctx := &transformContext{
-		snapshot:   snapshot,
+		parser:     p,
+		snapshot:   p.snapshot,
 		currentURI: fileURI,
 		currentAST: document,
-		rootDir:    rootDir,
+		rootDir:    p.rootDir,
 		source:     source,
 		relPath:    relPath,
 	}
@@ -45,6 +46,7 @@ func transform(document *parser.Document, source []byte, fileURI uri.URI, rootDi
 	idlFile := &idl_ast.File{
 		Path:       relPath,
 		Location:   &loc,
+		Content:    string(source),
 		Imports:    transformImports(ctx),
 		Namespaces: transformNamespaces(ctx),
 		Definitions: idl_ast.Definitions{
@@ -55,6 +57,7 @@ func transform(document *parser.Document, source []byte, fileURI uri.URI, rootDi
 			Typedefs:  transformTypedefs(ctx),
 		},
 	}
+	idlFile.Definitions.OrderByLocation()
 
 	return idlFile, nil
 }
@@ -103,24 +106,28 @@ func transformConstValue(cv *parser.ConstValue) *idl_ast.ConstantValue {
 		return nil
 	}
 
-	var val any
+	res := &idl_ast.ConstantValue{}
 	switch cv.TypeName {
 	case "string":
+		res.Kind = idl_ast.ConstantString
 		if strVal, ok := cv.Value.(string); ok {
-			val = fmt.Sprintf("%q", strVal)
+			res.Value = fmt.Sprintf("%q", strVal)
 		} else if literal, ok := cv.Value.(*parser.Literal); ok {
-			val = fmt.Sprintf("%s%s%s", literal.Quote, literal.Value.Text, literal.Quote)
+			res.Value = fmt.Sprintf("%s%s%s", literal.Quote, literal.Value.Text, literal.Quote)
 		}
 	case "i64":
-		val, _ = cv.Value.(int64)
+		res.Kind = idl_ast.ConstantInt
+		res.Value, _ = cv.Value.(int64)
 	case "double":
-		val, _ = cv.Value.(float64)
+		res.Kind = idl_ast.ConstantDouble
+		res.Value, _ = cv.Value.(float64)
 	case "identifier":
+		// 只有字面量 true / false 是布尔值，T、False 等都是普通的标识符。
 		strVal := cv.Value.(string)
-		if bVal, err := strconv.ParseBool(strVal); err == nil {
-			val = bVal
+		if strVal == "true" || strVal == "false" {
+			res.Kind, res.Value = idl_ast.ConstantBool, strVal == "true"
 		} else {
-			val = strVal
+			res.Kind, res.Value = idl_ast.ConstantIdentifier, strVal
 		}
 	case "list":
 		items := cv.Value.([]*parser.ConstValue)
@@ -128,7 +135,7 @@ func transformConstValue(cv *parser.ConstValue) *idl_ast.ConstantValue {
 		for _, item := range items {
 			listVal = append(listVal, transformConstValue(item))
 		}
-		val = listVal
+		res.Kind, res.Value = idl_ast.ConstantList, listVal
 	case "map":
 		items := cv.Value.([]*parser.ConstValue)
 		mapVal := make([]*idl_ast.ConstantMapEntry, 0, len(items))
@@ -140,13 +147,14 @@ func transformConstValue(cv *parser.ConstValue) *idl_ast.ConstantValue {
 				})
 			}
 		}
-		val = mapVal
+		res.Kind, res.Value = idl_ast.ConstantMap, mapVal
 	}
 
-	return &idl_ast.ConstantValue{Value: val}
+	return res
 }
 
-// transformAnnotations 将 parser.Annotations 转换为 []idl_ast.Annotation
+// transformAnnotations 将 parser.Annotations 转换为 []idl_ast.Annotation。
+// 注解按源码顺序保留（包括重复的名称），值保留原始的引号和转义，例如 'a"b' 或 "a\"b"。
 func transformAnnotations(p *parser.Annotations) []idl_ast.Annotation {
 	if p == nil || len(p.Annotations) == 0 {
 		return nil
@@ -154,12 +162,9 @@ func transformAnnotations(p *parser.Annotations) []idl_ast.Annotation {
 	res := make([]idl_ast.Annotation, len(p.Annotations))
 	for i, anno := range p.Annotations {
 		var constVal *idl_ast.ConstantValue
-		if anno.Value != nil {
-			tempConst := &parser.ConstValue{
-				TypeName: "string",
-				Value:    anno.Value,
-			}
-			constVal = transformConstValue(tempConst)
+		// 没有值的注解在解析前被补上了值，见 thriftsrc.Parse。
+		if anno.Value != nil && anno.Value.Value != nil && !thriftsrc.IsBareAnnotation(anno) {
+			constVal = &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: anno.Value.Quote + anno.Value.Value.Text + anno.Value.Quote}
 		}
 		res[i] = idl_ast.Annotation{
 			Name:  anno.Identifier.Name.Text,
@@ -173,7 +178,7 @@ func transformImports(ctx *transformContext) []idl_ast.Import {
 	imports := ctx.currentAST.Includes
 	res := make([]idl_ast.Import, len(imports))
 	for i, imp := range imports {
-		absImportURI := lsputils.IncludeURI(ctx.currentURI, imp.Path.Value.Text)
+		absImportURI := ctx.parser.resolveInclude(ctx.currentURI, imp.Path.Value.Text)
 		absImportPath := absImportURI.Filename()
 		relPath, err := filepath.Rel(ctx.rootDir, absImportPath)
 		if err != nil {
@@ -261,6 +266,7 @@ func transformFunctions(functions []*parser.Function, serviceName string, ctx *t
 			Parameters:         transformFields(f.Arguments, ctx),
 			Throws:             throws,
 			Annotations:        transformAnnotations(f.Annotations),
+			Oneway:             f.Oneway != nil,
 		}
 	}
 	return res
@@ -392,7 +398,7 @@ func transformConstants(ctx *transformContext) []idl_ast.Constant {
 			Name:               name,
 			FullyQualifiedName: fmt.Sprintf("%s#%s", ctx.relPath, name),
 			Type:               transformType(c.ConstType, ctx),
-			Value:              c.Value.ValueInText,
+			Value:              transformConstValue(c.Value),
 			Annotations:        transformAnnotations(c.Annotations),
 		}
 	}
@@ -405,13 +411,15 @@ func transformTypedefs(ctx *transformContext) []idl_ast.Typedef {
 	for i, t := range typedefs {
 		startPos, endPos := getRealTypedefPositions(t)
 		loc := convertLocation(parser.Location{StartPos: startPos, EndPos: endPos})
+		alias := t.Alias.Name.Text
 		res[i] = idl_ast.Typedef{
-			Comments:    convertComments(t.Comments),
-			Location:    &loc,
-			Content:     getRealContent(ctx.source, startPos.Offset, endPos.Offset),
-			Alias:       t.Alias.Name.Text,
-			Type:        transformType(t.T, ctx),
-			Annotations: transformAnnotations(t.Annotations),
+			Comments:           convertComments(t.Comments),
+			Location:           &loc,
+			Content:            getRealContent(ctx.source, startPos.Offset, endPos.Offset),
+			Alias:              alias,
+			FullyQualifiedName: fmt.Sprintf("%s#%s", ctx.relPath, alias),
+			Type:               transformType(t.T, ctx),
+			Annotations:        transformAnnotations(t.Annotations),
 		}
 	}
 	return res
@@ -441,7 +449,13 @@ func transformType(p *parser.FieldType, ctx *transformContext) idl_ast.Type {
 			if err == nil {
 				t.FullyQualifiedName = fmt.Sprintf("%s#%s", relPath, defIdentifier.Name.Text)
 			}
+		} else if defURI, name, ok := ctx.parser.resolveTypeByIncludePaths(ctx.currentURI, ctx.currentAST, t.Name); ok {
+			relPath, err := filepath.Rel(ctx.rootDir, defURI.Filename())
+			if err == nil {
+				t.FullyQualifiedName = fmt.Sprintf("%s#%s", relPath, name)
+			}
 		}
+		t.Unresolved = t.FullyQualifiedName == ""
 	}
 
 	switch t.Name {
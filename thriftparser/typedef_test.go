@@ -0,0 +1,100 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+func TestIDLSchema_ResolveType(t *testing.T) {
+	p, err := NewParserFromMap("project", map[string][]byte{
+		"common.thrift": []byte(`
+typedef i64 ID
+
+struct Entity {
+  1: ID id
+}
+`),
+		"main.thrift": []byte(`
+include "common.thrift"
+
+typedef common.ID UserID
+typedef UserID OwnerID
+typedef list<OwnerID> OwnerIDs
+typedef common.Entity Item
+
+typedef Loop1 Loop2
+typedef Loop2 Loop1
+
+typedef list<ListLoop2> ListLoop1
+typedef list<ListLoop1> ListLoop2
+
+struct Holder {
+  1: OwnerID owner
+  2: map<UserID, list<Item>> items
+  3: OwnerIDs owners
+  4: string name
+  5: Loop1 loop
+  6: ListLoop1 listLoop
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	holders := schema.FindMessagesByFQN("main.thrift#Holder")
+	require.Len(t, holders, 1)
+	fields := holders[0].Fields
+
+	aliasNames := func(res *idl_ast.ResolvedType) []string {
+		var names []string
+		for _, td := range res.Aliases {
+			names = append(names, td.FullyQualifiedName)
+		}
+		return names
+	}
+
+	// 跨文件展开多层 typedef。
+	res, err := schema.ResolveType(&fields[0].Type)
+	require.NoError(t, err)
+	assert.Equal(t, "i64", res.Type.Name)
+	assert.Empty(t, res.Type.FullyQualifiedName)
+	assert.Equal(t, []string{"main.thrift#OwnerID", "main.thrift#UserID", "common.thrift#ID"}, aliasNames(res))
+
+	// 容器元素类型被递归展开，原 AST 保持不变。
+	res, err = schema.ResolveType(&fields[1].Type)
+	require.NoError(t, err)
+	assert.Empty(t, res.Aliases)
+	assert.Equal(t, "i64", res.Type.KeyType.Name)
+	assert.Equal(t, "list", res.Type.ValueType.Name)
+	assert.Equal(t, "common.thrift#Entity", res.Type.ValueType.ValueType.FullyQualifiedName)
+	assert.Equal(t, "main.thrift#UserID", fields[1].Type.KeyType.FullyQualifiedName)
+	assert.Equal(t, "main.thrift#Item", fields[1].Type.ValueType.ValueType.FullyQualifiedName)
+
+	// typedef 本身是容器时，继续展开它的元素类型。
+	res, err = schema.ResolveType(&fields[2].Type)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.thrift#OwnerIDs"}, aliasNames(res))
+	assert.Equal(t, "list", res.Type.Name)
+	assert.Equal(t, "i64", res.Type.ValueType.Name)
+
+	// 不是 typedef 的类型原样返回。
+	res, err = schema.ResolveType(&fields[3].Type)
+	require.NoError(t, err)
+	assert.Same(t, &fields[3].Type, res.Type)
+	assert.Empty(t, res.Aliases)
+
+	// 检测 typedef 循环。
+	_, err = schema.ResolveType(&fields[4].Type)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "typedef cycle: main.thrift#Loop1 -> main.thrift#Loop2 -> main.thrift#Loop1")
+
+	// 经过容器元素类型形成的循环同样返回错误，而不是无限递归。
+	_, err = schema.ResolveType(&fields[5].Type)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "typedef cycle: main.thrift#ListLoop1 -> main.thrift#ListLoop2 -> main.thrift#ListLoop1")
+}
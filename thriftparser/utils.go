@@ -5,11 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/Skyenought/idlanalyzer/internal/thriftsrc"
 	"github.com/joyme123/thrift-ls/format"
 	"github.com/joyme123/thrift-ls/lsp/cache"
 	"github.com/joyme123/thrift-ls/lsp/memoize"
@@ -55,13 +54,12 @@ func getRealContent(source []byte, startOffset, endOffset int) string {
 	}
 	sourceLen := len(source)
 	if startOffset < 0 || endOffset > sourceLen || startOffset > endOffset {
-		fmt.Errorf("invalid content offset. Start: %d, End: %d, Source Length: %d", startOffset, endOffset, sourceLen)
 		return ""
 	}
 	return string(source[startOffset:endOffset])
 }
 
-// getRealStructPositions returns a Struct node's real start and end positions (from 'struct' keyword to '}').
+// getRealStructPositions returns a Struct node's real start and end positions (from 'struct' keyword to '}' or its trailing annotations).
 func getRealStructPositions(s *parser.Struct) (parser.Position, parser.Position) {
 	if s == nil {
 		return parser.InvalidPosition, parser.InvalidPosition
@@ -71,7 +69,9 @@ func getRealStructPositions(s *parser.Struct) (parser.Position, parser.Position)
 		startPos = s.StructKeyword.Pos()
 	}
 	endPos := s.Location.EndPos
-	if s.RCurKeyword != nil {
+	if s.Annotations != nil {
+		endPos = s.Annotations.End()
+	} else if s.RCurKeyword != nil {
 		endPos = s.RCurKeyword.End()
 	}
 	return startPos, endPos
@@ -87,7 +87,9 @@ func getRealEnumPositions(e *parser.Enum) (parser.Position, parser.Position) {
 		startPos = e.EnumKeyword.Pos()
 	}
 	endPos := e.Location.EndPos
-	if e.RCurKeyword != nil {
+	if e.Annotations != nil {
+		endPos = e.Annotations.End()
+	} else if e.RCurKeyword != nil {
 		endPos = e.RCurKeyword.End()
 	}
 	return startPos, endPos
@@ -103,7 +105,9 @@ func getRealServicePositions(s *parser.Service) (parser.Position, parser.Positio
 		startPos = s.ServiceKeyword.Pos()
 	}
 	endPos := s.Location.EndPos
-	if s.RCurKeyword != nil {
+	if s.Annotations != nil {
+		endPos = s.Annotations.End()
+	} else if s.RCurKeyword != nil {
 		endPos = s.RCurKeyword.End()
 	}
 	return startPos, endPos
@@ -119,7 +123,9 @@ func getRealExceptionPositions(e *parser.Exception) (parser.Position, parser.Pos
 		startPos = e.ExceptionKeyword.Pos()
 	}
 	endPos := e.Location.EndPos
-	if e.RCurKeyword != nil {
+	if e.Annotations != nil {
+		endPos = e.Annotations.End()
+	} else if e.RCurKeyword != nil {
 		endPos = e.RCurKeyword.End()
 	}
 	return startPos, endPos
@@ -135,7 +141,9 @@ func getRealUnionPositions(u *parser.Union) (parser.Position, parser.Position) {
 		startPos = u.UnionKeyword.Pos()
 	}
 	endPos := u.Location.EndPos
-	if u.RCurKeyword != nil {
+	if u.Annotations != nil {
+		endPos = u.Annotations.End()
+	} else if u.RCurKeyword != nil {
 		endPos = u.RCurKeyword.End()
 	}
 	return startPos, endPos
@@ -151,7 +159,9 @@ func getRealTypedefPositions(t *parser.Typedef) (parser.Position, parser.Positio
 		startPos = t.TypedefKeyword.Pos()
 	}
 	endPos := t.Location.EndPos
-	if t.Alias != nil {
+	if t.Annotations != nil {
+		endPos = t.Annotations.End()
+	} else if t.Alias != nil {
 		endPos = t.Alias.End()
 	}
 	return startPos, endPos
@@ -169,6 +179,8 @@ func getRealConstPositions(c *parser.Const) (parser.Position, parser.Position) {
 	endPos := c.Location.EndPos
 	if c.ListSeparatorKeyword != nil {
 		endPos = c.ListSeparatorKeyword.End()
+	} else if c.Annotations != nil {
+		endPos = c.Annotations.End()
 	} else if c.Value != nil {
 		endPos = c.Value.End()
 	}
@@ -427,43 +439,22 @@ func (p *ThriftParser) buildSnapshotWithMap(name string, fileMap map[string][]by
 		}
 
 		logicalAbsPath := filepath.Join(p.rootDir, relativePath)
-
-		finalAST, fixedContent, err := parseThriftFile(logicalAbsPath, content)
+		change, err := p.loadFile(logicalAbsPath, content)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to process %s: %w", logicalAbsPath, err)
-		}
-		if p.opts.NoComments && finalAST != nil {
-			removeAllComments(finalAST)
-			contentString, err := format.FormatDocument(finalAST)
-			if err != nil {
-				return nil, nil, fmt.Errorf("formatting after comment removal failed for %s: %w", logicalAbsPath, err)
-			}
-			content = []byte(contentString)
-
-			reParsedAST, err := parser.Parse(logicalAbsPath, content)
-			if err != nil {
-				fmt.Errorf("initial parse failed for %s: %v", logicalAbsPath, err)
-				return nil, nil, err
-			} else {
-				finalAST = reParsedAST.(*parser.Document)
+			if p.opts.Recover {
+				p.recordFailure(logicalAbsPath, err)
+				continue
 			}
+			return nil, nil, err
 		}
-
-		uriFile := uri.File(logicalAbsPath)
-
-		p.fileAsts[uriFile.Filename()] = finalAST
-
-		fileChanges = append(fileChanges, &cache.FileChange{
-			URI:     uriFile,
-			Content: fixedContent,
-			From:    cache.FileChangeTypeDidOpen,
-		})
+		fileChanges = append(fileChanges, change)
 	}
 
 	store := &memoize.Store{}
 	c := cache.New(store)
 	fs := cache.NewOverlayFS(c)
 	fs.Update(context.TODO(), fileChanges)
+	p.overlay = fs
 
 	// 使用 p.rootDir 构造 View 的根 URI
 	view := cache.NewView(name, uri.File(p.rootDir), fs, store)
@@ -476,6 +467,41 @@ func (p *ThriftParser) buildSnapshotWithMap(name string, fileMap map[string][]by
 	return ss, fileChanges, nil
 }
 
+// loadFile 解析一个文件并记录它的 AST，返回加入快照时使用的 FileChange。
+func (p *ThriftParser) loadFile(logicalAbsPath string, content []byte) (*cache.FileChange, error) {
+	finalAST, fixedContent, err := thriftsrc.Parse(logicalAbsPath, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process %s: %w", logicalAbsPath, err)
+	}
+	if p.opts.NoComments && finalAST != nil {
+		removeAllComments(finalAST)
+		contentString, err := format.FormatDocument(finalAST)
+		if err != nil {
+			return nil, fmt.Errorf("formatting after comment removal failed for %s: %w", logicalAbsPath, err)
+		}
+		// 之后的位置和 Content 都基于格式化后的文本，因此快照中也要使用它。
+		fixedContent = []byte(contentString)
+		content = fixedContent
+
+		reParsedAST, err := parser.Parse(logicalAbsPath, fixedContent)
+		if err != nil {
+			return nil, fmt.Errorf("parse after comment removal failed for %s: %w", logicalAbsPath, err)
+		}
+		finalAST = reParsedAST.(*parser.Document)
+	}
+
+	uriFile := uri.File(logicalAbsPath)
+
+	p.fileAsts[uriFile.Filename()] = finalAST
+	p.sources[uriFile.Filename()] = content
+
+	return &cache.FileChange{
+		URI:     uriFile,
+		Content: fixedContent,
+		From:    cache.FileChangeTypeDidOpen,
+	}, nil
+}
+
 func (p *ThriftParser) buildSnapshot(name, folder string) (*cache.Snapshot, []*cache.FileChange, error) {
 	fileMap := make(map[string][]byte)
 
@@ -511,152 +537,33 @@ func removeLocationsInSchema(schema *idl_ast.IDLSchema) {
 	if schema == nil {
 		return
 	}
-	for i := range schema.Files {
-		removeLocationsInFile(&schema.Files[i])
-	}
-}
-
-func removeLocationsInFile(file *idl_ast.File) {
-	if file == nil {
-		return
-	}
-	file.Location = nil
-	for i := range file.Imports {
-		file.Imports[i].Location = nil
-	}
-	for i := range file.Namespaces {
-		file.Namespaces[i].Location = nil
-	}
-	removeLocationsInDefinitions(&file.Definitions)
-}
-
-func removeLocationsInDefinitions(defs *idl_ast.Definitions) {
-	for i := range defs.Services {
-		removeLocationsInService(&defs.Services[i])
-	}
-	for i := range defs.Messages {
-		removeLocationsInMessage(&defs.Messages[i])
-	}
-	for i := range defs.Enums {
-		removeLocationsInEnum(&defs.Enums[i])
-	}
-	for i := range defs.Constants {
-		defs.Constants[i].Location = nil
-		removeLocationsInType(&defs.Constants[i].Type)
-	}
-	for i := range defs.Typedefs {
-		removeLocationsInTypedef(&defs.Typedefs[i])
-	}
-}
-
-func removeLocationsInService(svc *idl_ast.Service) {
-	svc.Location = nil
-	for i := range svc.Functions {
-		removeLocationsInFunction(&svc.Functions[i])
-	}
-}
-
-func removeLocationsInFunction(fun *idl_ast.Function) {
-	fun.Location = nil
-	removeLocationsInType(&fun.ReturnType)
-	for i := range fun.Parameters {
-		removeLocationsInField(&fun.Parameters[i])
-	}
-	for i := range fun.Throws {
-		removeLocationsInField(&fun.Throws[i])
-	}
-}
-
-func removeLocationsInMessage(msg *idl_ast.Message) {
-	msg.Location = nil
-	for i := range msg.Fields {
-		removeLocationsInField(&msg.Fields[i])
-	}
-}
-
-func removeLocationsInEnum(enum *idl_ast.Enum) {
-	enum.Location = nil
-	for i := range enum.Values {
-		enum.Values[i].Location = nil
-	}
-}
-
-func removeLocationsInTypedef(td *idl_ast.Typedef) {
-	td.Location = nil
-	removeLocationsInType(&td.Type)
-}
-
-func removeLocationsInField(field *idl_ast.Field) {
-	field.Location = nil
-	removeLocationsInType(&field.Type)
-}
-
-func removeLocationsInType(t *idl_ast.Type) {
-	if t == nil {
-		return
-	}
-	t.Location = nil
-	if t.KeyType != nil {
-		removeLocationsInType(t.KeyType)
-	}
-	if t.ValueType != nil {
-		removeLocationsInType(t.ValueType)
-	}
-}
-
-const (
-	maxParseRetries = 5
-)
-
-var (
-	// 从错误信息中提取行号: `:(\d+):\d+`
-	lineFromErrorRegex = regexp.MustCompile(`:(\d+):\d+`)
-	// 查找并修复容器类型关键词，(?i)表示不区分大小写, \b是单词边界
-	containerTypeRegex = regexp.MustCompile(`\b(?i)(map|list|set)\b`)
-)
-
-// parseThriftFile 是一个高度封装的辅助函数。
-func parseThriftFile(filename string, initialContent []byte) (doc *parser.Document, finalContent []byte, err error) {
-	currentContent := initialContent
-	var lastErr error
-
-	for i := 0; i < maxParseRetries; i++ {
-		ast, parseErr := parser.Parse(filename, currentContent)
-		if parseErr == nil {
-			return ast.(*parser.Document), currentContent, nil
-		}
-
-		lastErr = parseErr
-		errorString := parseErr.Error()
-
-		isFixableError := strings.Contains(errorString, "rule ErrStructField") || strings.Contains(errorString, "expecting")
-		if !isFixableError {
-			break
-		}
-		tm := make(map[string][]byte)
-		tm[filename] = currentContent
-		matches := lineFromErrorRegex.FindStringSubmatch(errorString)
-		if len(matches) < 2 {
-			break
-		}
-		lineNum, _ := strconv.Atoi(matches[1])
-
-		lines := strings.Split(string(currentContent), "\n")
-		if lineNum <= 0 || lineNum > len(lines) {
-			break
-		}
-
-		// 应用修复策略：将 Map/List/Set 纠正为小写
-		problematicLine := lines[lineNum-1]
-		fixedLine := containerTypeRegex.ReplaceAllStringFunc(problematicLine, strings.ToLower)
-
-		if problematicLine == fixedLine {
-			break
-		}
-
-		lines[lineNum-1] = fixedLine
-		currentContent = []byte(strings.Join(lines, "\n"))
-	}
-
-	return nil, initialContent, fmt.Errorf("failed to parse after %d retries, last error: %w", maxParseRetries, lastErr)
+	idl_ast.Inspect(schema, func(node any) bool {
+		switch n := node.(type) {
+		case *idl_ast.File:
+			n.Location = nil
+		case *idl_ast.Import:
+			n.Location = nil
+		case *idl_ast.Namespace:
+			n.Location = nil
+		case *idl_ast.Service:
+			n.Location = nil
+		case *idl_ast.Function:
+			n.Location = nil
+		case *idl_ast.Message:
+			n.Location = nil
+		case *idl_ast.Field:
+			n.Location = nil
+		case *idl_ast.Enum:
+			n.Location = nil
+		case *idl_ast.EnumValue:
+			n.Location = nil
+		case *idl_ast.Constant:
+			n.Location = nil
+		case *idl_ast.Typedef:
+			n.Location = nil
+		case *idl_ast.Type:
+			n.Location = nil
+		}
+		return true
+	})
 }
@@ -0,0 +1,74 @@
+package thriftparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+)
+
+func TestWalk(t *testing.T) {
+	p, err := NewParser("testdata/thrifts")
+	require.NoError(t, err)
+	schema, err := p.ParseIDLs()
+	require.NoError(t, err)
+
+	t.Run("path", func(t *testing.T) {
+		var found bool
+		idl_ast.Walk(schema, func(c *idl_ast.Cursor) bool {
+			typ, ok := c.Node().(*idl_ast.Type)
+			if !ok || typ.Name != "person.Person" {
+				return true
+			}
+			found = true
+			path := c.Path()
+			require.Len(t, path, 5)
+			assert.IsType(t, &idl_ast.IDLSchema{}, path[0])
+			assert.Equal(t, "main.thrift", path[1].(*idl_ast.File).Path)
+			assert.IsType(t, &idl_ast.Definitions{}, path[2])
+			assert.Equal(t, "HelloResponse", path[3].(*idl_ast.Message).Name)
+			assert.Equal(t, "person", c.Parent().(*idl_ast.Field).Name)
+			return true
+		})
+		assert.True(t, found)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		var types int
+		idl_ast.Inspect(schema, func(node any) bool {
+			switch node.(type) {
+			case *idl_ast.Service:
+				return false
+			case *idl_ast.Function:
+				t.Fatal("children of a skipped node must not be visited")
+			case *idl_ast.Type:
+				types++
+			}
+			return true
+		})
+		assert.Greater(t, types, 0)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		idl_ast.Walk(schema, func(c *idl_ast.Cursor) bool {
+			if f, ok := c.Node().(*idl_ast.Field); ok && f.Name == "email" {
+				c.Replace(&idl_ast.Field{ID: f.ID, Name: "mail", Type: idl_ast.Type{Name: "string", IsPrimitive: true}})
+			}
+			return true
+		})
+		profile := schema.FindStructsByFQN("UserProfile")
+		require.Len(t, profile, 1)
+		assert.Equal(t, "mail", profile[0].Fields[2].Name)
+
+		assert.Panics(t, func() {
+			idl_ast.Walk(schema, func(c *idl_ast.Cursor) bool {
+				if _, ok := c.Node().(*idl_ast.Field); ok {
+					c.Replace(&idl_ast.Type{})
+				}
+				return true
+			})
+		})
+	})
+}
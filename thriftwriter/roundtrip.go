@@ -0,0 +1,282 @@
+package thriftwriter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joyme123/thrift-ls/parser"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
+	"github.com/Skyenought/idlanalyzer/internal/thriftsrc"
+)
+
+// segmentKind 区分原始文件中的顶层元素。
+type segmentKind int
+
+const (
+	segmentNamespace segmentKind = iota
+	segmentInclude
+	segmentDefinition
+)
+
+// segment 是原始文件中一个顶层元素占据的字节范围 [start, end)。
+// 定义的范围包含它前面的空行和注释，include 和 namespace 的范围包含前导注释。
+type segment struct {
+	kind       segmentKind
+	start, end int
+	// nodeStart 是元素本身（不含前导注释）的起始偏移量，用来与 idl_ast 中的 Location 对应。
+	nodeStart int
+	// text 是 include 或 namespace 在 idl_ast 中的等价写法，用来判断它是否被修改过。
+	text string
+	// claimed 是当前 AST 中与该元素对应的节点。
+	claimed any
+}
+
+// writeRoundTrip 以 file.Content 为底稿输出文件：
+//   - 未修改的定义（见 idl_ast.IsModified）连同它前面的注释和空行按原样输出；
+//   - 被修改的定义在原来的位置重新生成；
+//   - 被删除的定义、include 和 namespace 从原文中移除；
+//   - 新增的 include 和 namespace 插入到同类语句之后，新增的定义追加到文件末尾。
+//
+// 因此只改动了一个字段时，输出与原文的差异只有这个定义。
+func (w *thriftWriter) writeRoundTrip(file *idl_ast.File) error {
+	w.collectIncludeBasenames(file)
+	source := file.Content
+	doc, _, err := thriftsrc.Parse(file.Path, []byte(source))
+	if err != nil {
+		return fmt.Errorf("failed to parse original content of %s: %w", file.Path, err)
+	}
+	segments := collectSegments(doc)
+
+	// 把当前 AST 中的节点对应到原文中的元素。
+	var newNamespaces []*idl_ast.Namespace
+	for i := range file.Namespaces {
+		if !claim(segments, segmentNamespace, file.Namespaces[i].Location, &file.Namespaces[i]) {
+			newNamespaces = append(newNamespaces, &file.Namespaces[i])
+		}
+	}
+	var newImports []*idl_ast.Import
+	for i := range file.Imports {
+		if !claim(segments, segmentInclude, file.Imports[i].Location, &file.Imports[i]) {
+			newImports = append(newImports, &file.Imports[i])
+		}
+	}
+	var newDefs []any
+	for _, def := range w.orderedDefinitions(&file.Definitions) {
+		loc, content := definitionSource(def)
+		if !claimDefinition(segments, source, loc, content, def) {
+			newDefs = append(newDefs, def)
+		}
+	}
+
+	// 新增的 namespace 放在最后一个 namespace 之后，新增的 include 放在最后一个 include 之后。
+	nsAnchor, incAnchor := -1, -1
+	for i, seg := range segments {
+		switch seg.kind {
+		case segmentNamespace:
+			nsAnchor = i
+		case segmentInclude:
+			incAnchor = i
+		}
+	}
+	if incAnchor == -1 {
+		incAnchor = nsAnchor
+	}
+	var nsLines, incLines []string
+	for _, ns := range newNamespaces {
+		nsLines = append(nsLines, fmt.Sprintf("namespace %s %s", ns.Scope, ns.Name))
+	}
+	for _, imp := range newImports {
+		incLines = append(incLines, fmt.Sprintf("include %s", imp.Value))
+	}
+
+	var out strings.Builder
+	switch {
+	case incAnchor == -1 && len(nsLines)+len(incLines) > 0:
+		// 原文中没有任何 namespace 和 include，新增的语句写在文件开头。
+		out.WriteString(strings.Join(append(nsLines, incLines...), "\n") + "\n\n")
+	case nsAnchor == -1 && len(nsLines) > 0:
+		out.WriteString(strings.Join(nsLines, "\n") + "\n")
+	}
+
+	pos := 0
+	for i, seg := range segments {
+		out.WriteString(source[pos:seg.start])
+		pos = seg.end
+		modified := true
+		if seg.claimed != nil && seg.kind == segmentDefinition {
+			if modified, err = idl_ast.IsModified(seg.claimed); err != nil {
+				return fmt.Errorf("failed to check %s for modifications: %w", file.Path, err)
+			}
+		}
+		switch {
+		case seg.claimed == nil && seg.kind != segmentDefinition:
+			// 删除整行，避免留下空行。
+			pos = skipLineEnd(source, pos)
+		case seg.claimed == nil:
+			// 被删除的定义：它的范围已经包含了前导注释和空行。
+		case seg.kind == segmentDefinition && !modified:
+			out.WriteString(source[seg.start:seg.end])
+		case seg.kind == segmentDefinition:
+			text := source[seg.start:seg.end]
+			out.WriteString(text[:len(text)-len(strings.TrimLeft(text, " \t\r\n"))])
+			out.WriteString(strings.TrimSuffix(w.render(func() { w.writeDefinition(seg.claimed) }), "\n"))
+		case headerText(seg.claimed) == seg.text:
+			out.WriteString(source[seg.start:seg.end])
+		default:
+			out.WriteString(headerText(seg.claimed))
+		}
+		if i == nsAnchor && len(nsLines) > 0 {
+			out.WriteString("\n" + strings.Join(nsLines, "\n"))
+		}
+		if i == incAnchor && len(incLines) > 0 {
+			out.WriteString("\n" + strings.Join(incLines, "\n"))
+		}
+	}
+	out.WriteString(source[pos:])
+
+	if len(newDefs) > 0 {
+		text := strings.TrimRight(out.String(), "\r\n")
+		out.Reset()
+		out.WriteString(text)
+		if text != "" {
+			out.WriteString("\n")
+		}
+		for _, def := range newDefs {
+			if out.Len() > 0 {
+				out.WriteString(strings.Repeat("\n", w.opts.Style.BlankLinesBetweenDefinitions))
+			}
+			out.WriteString(w.render(func() { w.writeDefinition(def) }))
+		}
+	}
+	w.b.WriteString(out.String())
+	return nil
+}
+
+// collectSegments 按出现顺序返回原始文档中的 namespace、include 和定义。
+func collectSegments(doc *parser.Document) []*segment {
+	var segments []*segment
+	header := func(kind segmentKind, loc parser.Location, comments []*parser.Comment, text string) {
+		start := loc.StartPos.Offset
+		if len(comments) > 0 && comments[0].Pos().Offset < start {
+			start = comments[0].Pos().Offset
+		}
+		segments = append(segments, &segment{kind: kind, start: start, end: loc.EndPos.Offset, nodeStart: loc.StartPos.Offset, text: text})
+	}
+	for _, ns := range doc.Namespaces {
+		header(segmentNamespace, ns.Location, ns.Comments, fmt.Sprintf("namespace %s %s", ns.Language.Name.Text, ns.Name.Name.Text))
+	}
+	for _, inc := range doc.Includes {
+		header(segmentInclude, inc.Location, inc.Comments, fmt.Sprintf("include %s%s%s", inc.Path.Quote, inc.Path.Value.Text, inc.Path.Quote))
+	}
+	definition := func(loc parser.Location) {
+		segments = append(segments, &segment{kind: segmentDefinition, start: loc.StartPos.Offset, end: loc.EndPos.Offset})
+	}
+	for _, n := range doc.Consts {
+		definition(n.Location)
+	}
+	for _, n := range doc.Typedefs {
+		definition(n.Location)
+	}
+	for _, n := range doc.Enums {
+		definition(n.Location)
+	}
+	for _, n := range doc.Structs {
+		definition(n.Location)
+	}
+	for _, n := range doc.Unions {
+		definition(n.Location)
+	}
+	for _, n := range doc.Exceptions {
+		definition(n.Location)
+	}
+	for _, n := range doc.Services {
+		definition(n.Location)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].start < segments[j].start
+	})
+	return segments
+}
+
+// claim 把 include 或 namespace 节点对应到起始位置相同的原始元素。
+func claim(segments []*segment, kind segmentKind, loc *idl_ast.Location, node any) bool {
+	if loc == nil {
+		return false
+	}
+	for _, seg := range segments {
+		if seg.kind == kind && seg.claimed == nil && seg.nodeStart == loc.Start.Offset {
+			seg.claimed = node
+			return true
+		}
+	}
+	return false
+}
+
+// claimDefinition 把定义对应到包含它的原始元素。定义的 Content 必须与原文中 Location 处的文本一致，
+// 这样从其他文件移动过来的定义（Location 指向的是原来的文件）不会被误认。
+func claimDefinition(segments []*segment, source string, loc *idl_ast.Location, content string, def any) bool {
+	if loc == nil || content == "" {
+		return false
+	}
+	start, end := loc.Start.Offset, loc.End.Offset
+	if start < 0 || end > len(source) || start > end || source[start:end] != content {
+		return false
+	}
+	for _, seg := range segments {
+		if seg.kind == segmentDefinition && seg.claimed == nil && seg.start <= start && end <= seg.end {
+			seg.claimed = def
+			return true
+		}
+	}
+	return false
+}
+
+// definitionSource 返回顶层定义解析时记录的 Location 和源码文本。
+func definitionSource(def any) (*idl_ast.Location, string) {
+	switch d := def.(type) {
+	case *idl_ast.Constant:
+		return d.Location, d.Content
+	case *idl_ast.Typedef:
+		return d.Location, d.Content
+	case *idl_ast.Enum:
+		return d.Location, d.Content
+	case *idl_ast.Message:
+		return d.Location, d.Content
+	case *idl_ast.Service:
+		return d.Location, d.Content
+	}
+	return nil, ""
+}
+
+func headerText(node any) string {
+	switch n := node.(type) {
+	case *idl_ast.Namespace:
+		return fmt.Sprintf("namespace %s %s", n.Scope, n.Name)
+	case *idl_ast.Import:
+		return fmt.Sprintf("include %s", n.Value)
+	}
+	return ""
+}
+
+// skipLineEnd 跳过 pos 之后的行尾空白和一个换行符。
+func skipLineEnd(source string, pos int) int {
+	for pos < len(source) && (source[pos] == ' ' || source[pos] == '\t' || source[pos] == '\r') {
+		pos++
+	}
+	if pos < len(source) && source[pos] == '\n' {
+		pos++
+	}
+	return pos
+}
+
+// render 返回 fn 写入的文本，而不把它写入 w.b。
+func (w *thriftWriter) render(fn func()) string {
+	b := w.b
+	w.b = &strings.Builder{}
+	fn()
+	text := w.b.String()
+	w.b = b
+	return text
+}
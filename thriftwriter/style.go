@@ -0,0 +1,53 @@
+package thriftwriter
+
+// Separator 是写在 struct 字段、枚举成员和 service 方法之后的分隔符。
+type Separator string
+
+const (
+	SeparatorComma     Separator = ","
+	SeparatorSemicolon Separator = ";"
+	SeparatorNone      Separator = ""
+)
+
+// AnnotationLayout 决定注解的排版方式。
+type AnnotationLayout int
+
+const (
+	// AnnotationsInline 把所有注解写在同一行，例如 `(api.get = "/ping", api.serializer = "json")`。
+	AnnotationsInline AnnotationLayout = iota
+	// AnnotationsOnePerLine 把每个注解单独写成一行，括号分别位于首行末尾和单独的末行。
+	// 函数参数和 throws 上的注解仍然写在同一行。
+	AnnotationsOnePerLine
+)
+
+// Style 描述生成代码的排版风格。应从 DefaultStyle 的返回值出发修改需要调整的字段，
+// 因为零值的 Style 表示不缩进、不加分隔符、定义之间不留空行。
+type Style struct {
+	// Indent 是每一级缩进使用的字符串。
+	Indent string
+	// Separator 是 struct 字段、枚举成员和 service 方法之后的分隔符。struct 的每个字段都会带上分隔符，
+	// 枚举成员和方法只写在相邻两项之间。
+	Separator Separator
+	// AlignFields 为 true 时，struct 字段的 ID、requiredness、类型和名称按列对齐，枚举成员的 `=` 对齐。
+	AlignFields bool
+	// BlankLinesBetweenDefinitions 是顶层定义之间的空行数。
+	BlankLinesBetweenDefinitions int
+	// BlankLinesBetweenFunctions 是 service 中相邻方法之间的空行数。
+	BlankLinesBetweenFunctions int
+	// MaxLineWidth 大于 0 时，超过该宽度（包含缩进）的方法签名会折行，每个参数和 throws 字段各占一行。
+	MaxLineWidth int
+	// AnnotationLayout 决定注解的排版方式。
+	AnnotationLayout AnnotationLayout
+}
+
+// DefaultStyle 返回 thriftwriter 默认使用的风格：四个空格缩进、逗号分隔、不对齐、
+// 定义之间和方法之间各空一行、不折行、注解写在同一行。
+func DefaultStyle() Style {
+	return Style{
+		Indent:                       "    ",
+		Separator:                    SeparatorComma,
+		BlankLinesBetweenDefinitions: 1,
+		BlankLinesBetweenFunctions:   1,
+		AnnotationLayout:             AnnotationsInline,
+	}
+}
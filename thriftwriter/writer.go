@@ -3,6 +3,7 @@ package thriftwriter
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/Skyenought/idlanalyzer/idl_ast"
@@ -10,6 +11,14 @@ import (
 
 type Options struct {
 	NoComments bool
+	// RoundTrip 为 true 时，以解析时记录的 File.Content 为底稿，只重新生成被修改过的定义，
+	// 其余内容（包括注释、空行和声明顺序）按原样保留。没有 Content 的文件仍然完整生成。
+	RoundTrip bool
+	// GroupByKind 为 true 时忽略 Definitions.Order 记录的声明顺序，
+	// 按 constant、typedef、enum、message、service 的顺序分组输出定义。
+	GroupByKind bool
+	// Style 决定缩进、分隔符、对齐、空行、折行和注解的排版，默认为 DefaultStyle()。
+	Style Style
 }
 
 type Option func(o *Options)
@@ -20,12 +29,31 @@ func WithNoComments(noComments bool) Option {
 	}
 }
 
+func WithRoundTrip(roundTrip bool) Option {
+	return func(o *Options) {
+		o.RoundTrip = roundTrip
+	}
+}
+
+func WithGroupByKind(groupByKind bool) Option {
+	return func(o *Options) {
+		o.GroupByKind = groupByKind
+	}
+}
+
+func WithStyle(style Style) Option {
+	return func(o *Options) {
+		o.Style = style
+	}
+}
+
 func Generate(schema *idl_ast.IDLSchema, opts ...Option) (map[string][]byte, error) {
 	if schema == nil {
 		return nil, fmt.Errorf("input schema cannot be nil")
 	}
 	options := &Options{
 		NoComments: false,
+		Style:      DefaultStyle(),
 	}
 	for _, opt := range opts {
 		opt(options)
@@ -35,10 +63,19 @@ func Generate(schema *idl_ast.IDLSchema, opts ...Option) (map[string][]byte, err
 		writer := &thriftWriter{
 			b:                &strings.Builder{},
 			indentationLevel: 0,
-			indentStr:        "    ",
+			indentStr:        options.Style.Indent,
 			opts:             options,
 		}
-		writer.writeFileContent(&fileAST)
+		if options.RoundTrip && fileAST.Content != "" {
+			if err := writer.writeRoundTrip(&fileAST); err != nil {
+				return nil, err
+			}
+		} else {
+			writer.writeFileContent(&fileAST)
+		}
+		if writer.err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", fileAST.Path, writer.err)
+		}
 		outputFiles[fileAST.Path] = []byte(writer.b.String())
 	}
 	return outputFiles, nil
@@ -50,19 +87,24 @@ type thriftWriter struct {
 	indentStr        string
 	opts             *Options
 	includeBasenames map[string]struct{}
+	// err 记录生成过程中遇到的第一个无法写出的值。
+	err error
 }
 
 func (w *thriftWriter) writeFileContent(file *idl_ast.File) {
+	w.collectIncludeBasenames(file)
+	w.writeNamespaces(file.Namespaces)
+	w.writeImports(file.Imports)
+	w.writeDefinitions(&file.Definitions)
+}
+
+func (w *thriftWriter) collectIncludeBasenames(file *idl_ast.File) {
 	w.includeBasenames = make(map[string]struct{})
 	for _, imp := range file.Imports {
 		base := filepath.Base(imp.Path)
 		name := strings.TrimSuffix(base, filepath.Ext(base))
 		w.includeBasenames[name] = struct{}{}
 	}
-
-	w.writeNamespaces(file.Namespaces)
-	w.writeImports(file.Imports)
-	w.writeDefinitions(&file.Definitions)
 }
 
 func (w *thriftWriter) writeComments(comments []idl_ast.Comment, useIndent bool) {
@@ -83,55 +125,132 @@ func (w *thriftWriter) writeComments(comments []idl_ast.Comment, useIndent bool)
 	}
 }
 
+// formatConstantValue 按值的种类（见 ConstantValue.InferKind）生成 Thrift 常量字面量。
+// 字符串字面量保留原有的引号和转义，没有引号的字符串会被加上双引号。
 func (w *thriftWriter) formatConstantValue(cv *idl_ast.ConstantValue) string {
 	if cv == nil || cv.Value == nil {
 		return ""
 	}
-	switch v := cv.Value.(type) {
-	case string:
-		if (strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`)) || (strings.HasPrefix(v, `'`) && strings.HasSuffix(v, `'`)) {
-			return v
+	switch cv.InferKind() {
+	case idl_ast.ConstantString:
+		text := fmt.Sprint(cv.Value)
+		if isQuotedLiteral(text) {
+			return text
 		}
-		return v
-	case int64, float64, bool:
-		return fmt.Sprintf("%v", v)
-	case []*idl_ast.ConstantValue:
-		var items []string
-		for _, item := range v {
-			items = append(items, w.formatConstantValue(item))
+		return w.quoteLiteral(text)
+	case idl_ast.ConstantDouble:
+		if v, ok := cv.Value.(float64); ok {
+			return formatDouble(v)
 		}
-		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
-	case []*idl_ast.ConstantMapEntry:
-		var entries []string
-		for _, entry := range v {
-			keyStr := w.formatConstantValue(entry.Key)
-			valStr := w.formatConstantValue(entry.Value)
-			entries = append(entries, fmt.Sprintf("%s: %s", keyStr, valStr))
+	case idl_ast.ConstantList:
+		if v, ok := cv.Value.([]*idl_ast.ConstantValue); ok {
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, w.formatConstantValue(item))
+			}
+			return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+		}
+	case idl_ast.ConstantMap:
+		if v, ok := cv.Value.([]*idl_ast.ConstantMapEntry); ok {
+			entries := make([]string, 0, len(v))
+			for _, entry := range v {
+				entries = append(entries, fmt.Sprintf("%s: %s", w.formatConstantValue(entry.Key), w.formatConstantValue(entry.Value)))
+			}
+			return fmt.Sprintf("{%s}", strings.Join(entries, ", "))
 		}
-		return fmt.Sprintf("{%s}", strings.Join(entries, ", "))
-	default:
-		return ""
 	}
+	return fmt.Sprint(cv.Value)
 }
 
+// formatDouble 格式化浮点数，保证结果中带有小数点或指数，重新解析时仍然是 double。
+func formatDouble(v float64) string {
+	text := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eEnN") {
+		text += ".0"
+	}
+	return text
+}
+
+// formatAnnotations 按 Style.AnnotationLayout 格式化定义、字段、枚举成员和方法上的注解。
+// 多行布局中的后续行会带上相对当前缩进级别的缩进。
 func (w *thriftWriter) formatAnnotations(annos []idl_ast.Annotation) string {
-	if len(annos) == 0 {
+	parts := w.annotationParts(annos)
+	if len(parts) == 0 {
 		return ""
 	}
-	var parts []string
-	for _, anno := range annos {
-		valStr, err := anno.Value.StringValue()
-		if err != nil {
-			continue
-		}
-		parts = append(parts, fmt.Sprintf(`%s = "%s"`, anno.Name, valStr))
+	if w.opts.Style.AnnotationLayout == AnnotationsOnePerLine {
+		inner := w.getIndent() + w.indentStr
+		return fmt.Sprintf(" (\n%s%s\n%s)", inner, strings.Join(parts, ",\n"+inner), w.getIndent())
 	}
+	return fmt.Sprintf(" (%s)", strings.Join(parts, ", "))
+}
+
+// formatInlineAnnotations 总是把注解写在同一行，用于函数参数和 throws 字段。
+func (w *thriftWriter) formatInlineAnnotations(annos []idl_ast.Annotation) string {
+	parts := w.annotationParts(annos)
 	if len(parts) == 0 {
 		return ""
 	}
 	return fmt.Sprintf(" (%s)", strings.Join(parts, ", "))
 }
 
+// annotationParts 把注解格式化为 `name = "value"`，没有值的注解只写名称。
+func (w *thriftWriter) annotationParts(annos []idl_ast.Annotation) []string {
+	parts := make([]string, 0, len(annos))
+	for _, anno := range annos {
+		if anno.Value == nil || anno.Value.Value == nil {
+			parts = append(parts, anno.Name)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s = %s", anno.Name, w.annotationLiteral(anno.Value)))
+	}
+	return parts
+}
+
+// annotationLiteral 把注解值格式化为 Thrift 字符串字面量。已经带引号的字符串按原样写出，
+// 保留原有的引号和转义；其他值先格式化为文本，再写成双引号字符串。
+func (w *thriftWriter) annotationLiteral(cv *idl_ast.ConstantValue) string {
+	text, ok := cv.Value.(string)
+	if ok && isQuotedLiteral(text) {
+		return text
+	}
+	if !ok {
+		text = w.formatConstantValue(cv)
+	}
+	return w.quoteLiteral(text)
+}
+
+// quoteLiteral 把 text 写成双引号字符串字面量，其中的双引号会被转义。thriftparser 使用的语法中反斜杠加引号总是构成转义，
+// 并且没有表示反斜杠本身的转义，因此以反斜杠结尾的文本（例如 `C:\`）无论用哪种引号都无法闭合，
+// 这种情况会记录为错误，而不是输出一个无法解析的字面量。
+func (w *thriftWriter) quoteLiteral(text string) string {
+	if strings.HasSuffix(text, `\`) {
+		if w.err == nil {
+			w.err = fmt.Errorf("string %q ends with a backslash and cannot be written as a Thrift literal", text)
+		}
+		return `""`
+	}
+	return `"` + strings.ReplaceAll(text, `"`, `\"`) + `"`
+}
+
+// isQuotedLiteral 判断 s 是否是一个完整的单引号或双引号字面量，即以引号开头，
+// 并且第一个未转义的同种引号恰好是最后一个字符。与 thriftparser 使用的语法一致，
+// 只有反斜杠加引号才构成转义。
+func isQuotedLiteral(s string) bool {
+	if len(s) < 2 || (s[0] != '"' && s[0] != '\'') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\''):
+			i++
+		case s[i] == s[0]:
+			return i == len(s)-1
+		}
+	}
+	return false
+}
+
 func (w *thriftWriter) writeNamespaces(namespaces []idl_ast.Namespace) {
 	if len(namespaces) == 0 {
 		return
@@ -155,57 +274,42 @@ func (w *thriftWriter) writeImports(imports []idl_ast.Import) {
 }
 
 func (w *thriftWriter) writeDefinitions(defs *idl_ast.Definitions) {
-	if len(defs.Constants) > 0 {
-		for i, constant := range defs.Constants {
-			w.writeConstant(&constant)
-			if i < len(defs.Constants)-1 {
-				w.writeLine("")
-			}
-		}
-		w.writeLine("")
-	}
-	if len(defs.Typedefs) > 0 {
-		for i, typedef := range defs.Typedefs {
-			w.writeTypedef(&typedef)
-			if i < len(defs.Typedefs)-1 {
-				w.writeLine("")
-			}
-		}
-		w.writeLine("")
+	for _, def := range w.orderedDefinitions(defs) {
+		w.writeDefinition(def)
+		w.writeBlankLines(w.opts.Style.BlankLinesBetweenDefinitions)
 	}
-	if len(defs.Enums) > 0 {
-		for i, enum := range defs.Enums {
-			w.writeEnum(&enum)
-			if i < len(defs.Enums)-1 {
-				w.writeLine("")
-			}
-		}
-		w.writeLine("")
-	}
-	if len(defs.Messages) > 0 {
-		for i, msg := range defs.Messages {
-			w.writeMessage(&msg)
-			if i < len(defs.Messages)-1 {
-				w.writeLine("")
-			}
-		}
-		w.writeLine("")
-	}
-	if len(defs.Services) > 0 {
-		for i, svc := range defs.Services {
-			w.writeService(&svc)
-			if i < len(defs.Services)-1 {
-				w.writeLine("")
-			}
-		}
-		w.writeLine("")
+}
+
+// orderedDefinitions 返回 defs 中所有顶层定义的输出顺序：默认按 Definitions.Order 记录的声明顺序，
+// 开启 GroupByKind 时按 constant、typedef、enum、message、service 分组。
+func (w *thriftWriter) orderedDefinitions(defs *idl_ast.Definitions) []any {
+	if w.opts.GroupByKind {
+		grouped := *defs
+		grouped.Order = nil
+		return grouped.Ordered()
+	}
+	return defs.Ordered()
+}
+
+func (w *thriftWriter) writeDefinition(def any) {
+	switch d := def.(type) {
+	case *idl_ast.Constant:
+		w.writeConstant(d)
+	case *idl_ast.Typedef:
+		w.writeTypedef(d)
+	case *idl_ast.Enum:
+		w.writeEnum(d)
+	case *idl_ast.Message:
+		w.writeMessage(d)
+	case *idl_ast.Service:
+		w.writeService(d)
 	}
 }
 
 func (w *thriftWriter) writeConstant(c *idl_ast.Constant) {
 	w.writeComments(c.Comments, false)
 	typeStr := w.formatType(&c.Type)
-	line := fmt.Sprintf("const %s %s = %s%s", typeStr, c.Name, c.Value, w.formatAnnotations(c.Annotations))
+	line := fmt.Sprintf("const %s %s = %s%s", typeStr, c.Name, w.formatConstantValue(c.Value), w.formatAnnotations(c.Annotations))
 	w.writeLine(line)
 }
 
@@ -221,18 +325,24 @@ func (w *thriftWriter) writeEnum(e *idl_ast.Enum) {
 	line := fmt.Sprintf("enum %s%s {", e.Name, w.formatAnnotations(e.Annotations))
 	w.writeLine(line)
 	w.indent()
+	nameWidth := 0
+	if w.opts.Style.AlignFields {
+		for _, val := range e.Values {
+			nameWidth = max(nameWidth, len(val.Name))
+		}
+	}
 	for i, val := range e.Values {
-		w.writeEnumValue(&val, i < len(e.Values)-1)
+		w.writeEnumValue(&val, nameWidth, i < len(e.Values)-1)
 	}
 	w.unindent()
 	w.writeLine("}")
 }
 
-func (w *thriftWriter) writeEnumValue(val *idl_ast.EnumValue, needsComma bool) {
+func (w *thriftWriter) writeEnumValue(val *idl_ast.EnumValue, nameWidth int, needsSeparator bool) {
 	w.writeComments(val.Comments, true)
-	line := fmt.Sprintf("%s = %d%s", val.Name, val.Value, w.formatAnnotations(val.Annotations))
-	if needsComma {
-		line += ","
+	line := fmt.Sprintf("%-*s = %d%s", nameWidth, val.Name, val.Value, w.formatAnnotations(val.Annotations))
+	if needsSeparator {
+		line += string(w.opts.Style.Separator)
 	}
 	w.writeLine(line)
 }
@@ -242,21 +352,48 @@ func (w *thriftWriter) writeMessage(m *idl_ast.Message) {
 	header := fmt.Sprintf("%s %s%s {", m.Type, m.Name, w.formatAnnotations(m.Annotations))
 	w.writeLine(header)
 	w.indent()
+	var widths fieldWidths
+	if w.opts.Style.AlignFields {
+		for _, field := range m.Fields {
+			widths.fit(w.fieldColumns(&field))
+		}
+	}
 	for _, field := range m.Fields {
-		w.writeField(&field, true)
+		w.writeField(&field, widths, true)
 	}
 	w.unindent()
 	w.writeLine("}")
 }
 
-func (w *thriftWriter) writeField(f *idl_ast.Field, trailingSeparator bool) {
+// fieldColumns 返回 struct 字段中参与对齐的 ID、requiredness 和类型三列，requiredness 可能为空。
+func (w *thriftWriter) fieldColumns(f *idl_ast.Field) [3]string {
+	required := ""
+	if f.Required != "" && f.Required != "optional" {
+		required = f.Required
+	}
+	return [3]string{fmt.Sprintf("%d:", f.ID), required, w.formatType(&f.Type)}
+}
+
+// fieldWidths 记录对齐时 fieldColumns 各列的宽度，零值表示不对齐。
+type fieldWidths [3]int
+
+func (fw *fieldWidths) fit(cols [3]string) {
+	for i, col := range cols {
+		fw[i] = max(fw[i], len(col))
+	}
+}
+
+func (w *thriftWriter) writeField(f *idl_ast.Field, widths fieldWidths, trailingSeparator bool) {
 	w.writeComments(f.Comments, true)
 	var parts []string
-	parts = append(parts, fmt.Sprintf("%d:", f.ID))
-	if f.Required != "" && f.Required != "optional" {
-		parts = append(parts, f.Required)
+	for i, col := range w.fieldColumns(f) {
+		switch {
+		case widths[i] > 0:
+			parts = append(parts, fmt.Sprintf("%-*s", widths[i], col))
+		case col != "":
+			parts = append(parts, col)
+		}
 	}
-	parts = append(parts, w.formatType(&f.Type))
 	parts = append(parts, f.Name)
 	if f.DefaultValue != nil {
 		defaultValueStr := w.formatConstantValue(f.DefaultValue)
@@ -264,7 +401,7 @@ func (w *thriftWriter) writeField(f *idl_ast.Field, trailingSeparator bool) {
 	}
 	line := strings.Join(parts, " ") + w.formatAnnotations(f.Annotations)
 	if trailingSeparator {
-		line += ","
+		line += string(w.opts.Style.Separator)
 	}
 	w.writeLine(line)
 }
@@ -283,40 +420,53 @@ func (w *thriftWriter) writeService(s *idl_ast.Service) {
 		w.writeComments(fun.Comments, true)
 		line := w.formatFunction(&fun)
 		if i < len(s.Functions)-1 {
-			line += ","
+			line += string(w.opts.Style.Separator)
 		}
 		w.writeLine(line)
 		if i < len(s.Functions)-1 {
-			w.writeLine("")
+			w.writeBlankLines(w.opts.Style.BlankLinesBetweenFunctions)
 		}
 	}
 	w.unindent()
 	w.writeLine("}")
 }
 
+// formatFunction 格式化方法签名。设置了 Style.MaxLineWidth 且签名超出宽度时，
+// 参数和 throws 字段各占一行。
 func (w *thriftWriter) formatFunction(f *idl_ast.Function) string {
-	isOneway := f.ReturnType.Name == "void" && len(f.Throws) == 0
-	onewayStr := ""
-	if isOneway {
-		onewayStr = "oneway "
+	head := w.formatType(&f.ReturnType) + " " + f.Name
+	if f.Oneway {
+		head = "oneway " + head
 	}
-	returnTypeStr := w.formatType(&f.ReturnType)
-	var paramParts []string
+	var params, throws []string
 	for _, p := range f.Parameters {
-		paramParts = append(paramParts, w.formatParamField(&p))
+		params = append(params, w.formatParamField(&p))
+	}
+	for _, t := range f.Throws {
+		throws = append(throws, w.formatParamField(&t))
+	}
+	line := w.functionSignature(head, params, throws, false, f.Annotations)
+	firstLine, _, _ := strings.Cut(line, "\n")
+	if maxWidth := w.opts.Style.MaxLineWidth; maxWidth > 0 && len(w.getIndent()+firstLine) > maxWidth && len(params)+len(throws) > 0 {
+		line = w.functionSignature(head, params, throws, true, f.Annotations)
 	}
-	paramsStr := strings.Join(paramParts, ", ")
-	var throwsParts []string
-	if len(f.Throws) > 0 {
-		for _, t := range f.Throws {
-			throwsParts = append(throwsParts, w.formatParamField(&t))
+	return line
+}
+
+// functionSignature 拼接方法签名，wrap 为 true 时参数和 throws 字段各占一行。
+func (w *thriftWriter) functionSignature(head string, params, throws []string, wrap bool, annos []idl_ast.Annotation) string {
+	fieldList := func(parts []string) string {
+		if !wrap || len(parts) == 0 {
+			return strings.Join(parts, ", ")
 		}
+		inner := w.getIndent() + w.indentStr
+		return "\n" + inner + strings.Join(parts, ",\n"+inner) + "\n" + w.getIndent()
 	}
 	throwsStr := ""
-	if len(throwsParts) > 0 {
-		throwsStr = fmt.Sprintf(" throws (%s)", strings.Join(throwsParts, ", "))
+	if len(throws) > 0 {
+		throwsStr = fmt.Sprintf(" throws (%s)", fieldList(throws))
 	}
-	return fmt.Sprintf("%s%s %s(%s)%s%s", onewayStr, returnTypeStr, f.Name, paramsStr, throwsStr, w.formatAnnotations(f.Annotations))
+	return fmt.Sprintf("%s(%s)%s%s", head, fieldList(params), throwsStr, w.formatAnnotations(annos))
 }
 
 func (w *thriftWriter) formatParamField(f *idl_ast.Field) string {
@@ -331,7 +481,7 @@ func (w *thriftWriter) formatParamField(f *idl_ast.Field) string {
 	if f.DefaultValue != nil {
 		parts = append(parts, "=", w.formatConstantValue(f.DefaultValue))
 	}
-	return strings.Join(parts, " ") + w.formatAnnotations(f.Annotations)
+	return strings.Join(parts, " ") + w.formatInlineAnnotations(f.Annotations)
 }
 
 func (w *thriftWriter) formatType(t *idl_ast.Type) string {
@@ -372,6 +522,12 @@ func (w *thriftWriter) writeLine(s string) {
 	w.b.WriteString("\n")
 }
 
+func (w *thriftWriter) writeBlankLines(n int) {
+	for i := 0; i < n; i++ {
+		w.writeLine("")
+	}
+}
+
 func (w *thriftWriter) writeLinef(format string, a ...any) {
 	w.writeLine(fmt.Sprintf(format, a...))
 }
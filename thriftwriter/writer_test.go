@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Skyenought/idlanalyzer/idl_ast"
 	"github.com/Skyenought/idlanalyzer/thriftparser"
 )
 
@@ -28,6 +33,466 @@ func TestWriter(t *testing.T) {
 	writeFiles("./generated_thrifts", generatedFiles)
 }
 
+func TestWriter_Oneway(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"main.thrift": []byte(`
+service Main {
+  oneway void notify(1: string msg)
+  void ping()
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	fns := schema.Files[0].Definitions.Services[0].Functions
+	assert.True(t, fns[0].Oneway)
+	assert.False(t, fns[1].Oneway)
+
+	generatedFiles, err := Generate(schema)
+	require.NoError(t, err)
+	out := string(generatedFiles["main.thrift"])
+	assert.Contains(t, out, "oneway void notify(1: string msg)")
+	assert.Contains(t, out, "    void ping()")
+}
+
+func TestWriter_DeclarationOrder(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"main.thrift": []byte(`
+struct Request {
+  1: string id
+}
+
+service Echo {
+  Request echo(1: Request req)
+}
+
+const i32 LIMIT = 10
+
+enum Kind {
+  A = 1
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	assert.Equal(t, []idl_ast.DefinitionRef{
+		{Kind: idl_ast.DefinitionMessage, Name: "Request"},
+		{Kind: idl_ast.DefinitionService, Name: "Echo"},
+		{Kind: idl_ast.DefinitionConstant, Name: "LIMIT"},
+		{Kind: idl_ast.DefinitionEnum, Name: "Kind"},
+	}, schema.Files[0].Definitions.Order)
+
+	keywordOrder := func(out string) []string {
+		var res []string
+		for _, line := range strings.Split(out, "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				switch fields[0] {
+				case "struct", "service", "const", "enum":
+					res = append(res, fields[0])
+				}
+			}
+		}
+		return res
+	}
+
+	out, err := Generate(schema)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"struct", "service", "const", "enum"}, keywordOrder(string(out["main.thrift"])))
+
+	out, err = Generate(schema, WithGroupByKind(true))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"const", "enum", "struct", "service"}, keywordOrder(string(out["main.thrift"])))
+
+	// SortSchema 的拓扑序同样记录在 Order 中。
+	thriftparser.SortSchema(schema)
+	out, err = Generate(schema)
+	require.NoError(t, err)
+	order := keywordOrder(string(out["main.thrift"]))
+	assert.Less(t, indexOf(order, "struct"), indexOf(order, "service"))
+}
+
+func indexOf(s []string, v string) int {
+	for i := range s {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestWriter_Style(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"main.thrift": []byte(`
+enum Kind {
+  A = 1
+  LONG_NAME = 2
+}
+
+struct Request {
+  1: required i64 id (api.path = "id", go.tag = "json:\"id\"")
+  2: string name
+}
+
+exception Failure {
+  1: string message
+}
+
+service Echo {
+  Request echo(1: Request request, 2: string traceIdentifier) throws (1: Failure failure)
+  void ping()
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	style := DefaultStyle()
+	style.Indent = "  "
+	style.Separator = SeparatorSemicolon
+	style.AlignFields = true
+	style.BlankLinesBetweenFunctions = 0
+	style.MaxLineWidth = 60
+	style.AnnotationLayout = AnnotationsOnePerLine
+	out, err := Generate(schema, WithNoComments(true), WithStyle(style))
+	require.NoError(t, err)
+	assert.Equal(t, `enum Kind {
+  A         = 1;
+  LONG_NAME = 2
+}
+
+struct Request {
+  1: required i64    id (
+    api.path = "id",
+    go.tag = "json:\"id\""
+  );
+  2:          string name;
+}
+
+exception Failure {
+  1: string message;
+}
+
+service Echo {
+  Request echo(
+    1: Request request,
+    2: string traceIdentifier
+  ) throws (
+    1: Failure failure
+  );
+  void ping()
+}
+
+`, string(out["main.thrift"]))
+
+	// 折行和多行注解的输出仍然可以被解析。
+	reparsed, err := thriftparser.NewParserFromMap("project", out)
+	require.NoError(t, err)
+	_, err = reparsed.ParseIDLs()
+	require.NoError(t, err)
+}
+
+func TestWriter_Annotations(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"main.thrift": []byte(`struct User {
+    1: string name (go.tag = 'json:"name"', api.vd = "len($) > 0 && $ != \"admin\"", api.tag = "a", api.tag = "b")
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	field := &schema.Files[0].Definitions.Messages[0].Fields[0]
+	assert.Equal(t, []idl_ast.Annotation{
+		{Name: "go.tag", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `'json:"name"'`}},
+		{Name: "api.vd", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"len($) > 0 && $ != \"admin\""`}},
+		{Name: "api.tag", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"a"`}},
+		{Name: "api.tag", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"b"`}},
+	}, field.Annotations)
+
+	out, err := Generate(schema, WithNoComments(true))
+	require.NoError(t, err)
+	assert.Contains(t, string(out["main.thrift"]), `1: string name (go.tag = 'json:"name"', api.vd = "len($) > 0 && $ != \"admin\"", api.tag = "a", api.tag = "b"),`)
+
+	reparsed, err := thriftparser.NewParserFromMap("project", out)
+	require.NoError(t, err)
+	reparsedSchema, err := reparsed.ParseIDLs()
+	require.NoError(t, err)
+	assert.Equal(t, field.Annotations, reparsedSchema.Files[0].Definitions.Messages[0].Fields[0].Annotations)
+
+	// 其他来源构造的注解：没有值的注解只写名称，未加引号的值会被转义后加上引号。
+	field.Annotations = []idl_ast.Annotation{
+		{Name: "cpp.bare"},
+		{Name: "api.raw", Value: &idl_ast.ConstantValue{Value: `say "hi"`}},
+		{Name: "api.limit", Value: &idl_ast.ConstantValue{Value: int64(10)}},
+	}
+	out, err = Generate(schema, WithNoComments(true))
+	require.NoError(t, err)
+	assert.Contains(t, string(out["main.thrift"]), `1: string name (cpp.bare, api.raw = "say \"hi\"", api.limit = "10"),`)
+
+	// 反斜杠在中间时原样写出；以反斜杠结尾的文本会转义结尾的引号，无法写成字面量。
+	field.Annotations = []idl_ast.Annotation{{Name: "api.path", Value: &idl_ast.ConstantValue{Value: `C:\dir`}}}
+	out, err = Generate(schema, WithNoComments(true))
+	require.NoError(t, err)
+	assert.Contains(t, string(out["main.thrift"]), `1: string name (api.path = "C:\dir"),`)
+
+	field.Annotations = []idl_ast.Annotation{{Name: "api.path", Value: &idl_ast.ConstantValue{Value: `C:\`}}}
+	_, err = Generate(schema, WithNoComments(true))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ends with a backslash")
+}
+
+func TestWriter_BareAnnotations(t *testing.T) {
+	source := `// 注释中的 (not.annotation) 保持不变
+typedef i32 Code (cpp.bare)
+
+enum Status {
+  OK = 0 (cpp.bare)
+}
+
+struct User {
+  1: string name (cpp.bare, go.tag = "json:\"name\""),
+  2: string empty (api.doc = ""),
+  3: string multi (
+    cpp.bare
+  )
+}
+
+service UserService {
+  void ping(1: string msg) (cpp.bare)
+}
+`
+	parse := func(files map[string][]byte) *idl_ast.IDLSchema {
+		p, err := thriftparser.NewParserFromMap("project", files)
+		require.NoError(t, err)
+		schema, err := p.ParseIDLs()
+		require.NoError(t, err)
+		return schema
+	}
+	schema := parse(map[string][]byte{"main.thrift": []byte(source)})
+	defs := schema.Files[0].Definitions
+	bare := []idl_ast.Annotation{{Name: "cpp.bare"}}
+	assert.Equal(t, bare, defs.Typedefs[0].Annotations)
+	assert.Equal(t, bare, defs.Enums[0].Values[0].Annotations)
+	assert.Equal(t, []idl_ast.Annotation{
+		{Name: "cpp.bare"},
+		{Name: "go.tag", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `"json:\"name\""`}},
+	}, defs.Messages[0].Fields[0].Annotations)
+	assert.Equal(t, []idl_ast.Annotation{
+		{Name: "api.doc", Value: &idl_ast.ConstantValue{Kind: idl_ast.ConstantString, Value: `""`}},
+	}, defs.Messages[0].Fields[1].Annotations)
+	assert.Equal(t, bare, defs.Messages[0].Fields[2].Annotations)
+	assert.Equal(t, bare, defs.Services[0].Functions[0].Annotations)
+	require.Len(t, defs.Typedefs[0].Comments, 1)
+	assert.Equal(t, "// 注释中的 (not.annotation) 保持不变", defs.Typedefs[0].Comments[0].Text)
+
+	// 普通模式重新生成定义，没有值的注解只写名称，重新解析后仍然没有值。
+	out, err := Generate(schema, WithNoComments(true))
+	require.NoError(t, err)
+	text := string(out["main.thrift"])
+	assert.Contains(t, text, `1: string name (cpp.bare, go.tag = "json:\"name\""),`)
+	assert.Contains(t, text, `2: string empty (api.doc = ""),`)
+	assert.NotContains(t, text, "cpp.bare =")
+	reparsed := parse(out)
+	assert.Equal(t, defs.Messages[0].Fields[0].Annotations, reparsed.Files[0].Definitions.Messages[0].Fields[0].Annotations)
+	assert.Equal(t, defs.Messages[0].Fields[1].Annotations, reparsed.Files[0].Definitions.Messages[0].Fields[1].Annotations)
+	assert.Equal(t, bare, reparsed.Files[0].Definitions.Messages[0].Fields[2].Annotations)
+
+	// round-trip 模式输出的原文与输入一致。
+	out, err = Generate(schema, WithRoundTrip(true))
+	require.NoError(t, err)
+	assert.Equal(t, source, string(out["main.thrift"]))
+}
+
+func TestWriter_Constants(t *testing.T) {
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"main.thrift": []byte(`
+enum Status {
+  OK = 0
+}
+
+const double RATIO = 3.0
+const string QUOTED = 'say "hi"'
+const Status DEFAULT = Status.OK
+const map<string, list<Status>> GROUPS = {
+  "ok": [Status.OK],
+}
+`),
+	})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+
+	out, err := Generate(schema)
+	require.NoError(t, err)
+	text := string(out["main.thrift"])
+	assert.Contains(t, text, "const double RATIO = 3.0\n")
+	assert.Contains(t, text, `const string QUOTED = 'say "hi"'`)
+	assert.Contains(t, text, "const Status DEFAULT = Status.OK\n")
+	assert.Contains(t, text, `const map<string, list<Status>> GROUPS = {"ok": [Status.OK]}`)
+
+	reparsed, err := thriftparser.NewParserFromMap("project", out)
+	require.NoError(t, err)
+	reparsedSchema, err := reparsed.ParseIDLs()
+	require.NoError(t, err)
+	for _, c := range schema.Files[0].Definitions.Constants {
+		found := reparsedSchema.FindConstantsByFQN(c.FullyQualifiedName)
+		require.Len(t, found, 1)
+		assert.Equal(t, c.Value, found[0].Value, c.Name)
+	}
+}
+
+const roundTripSource = `namespace go example.user
+
+include "common.thrift"
+
+// 用户状态
+enum Status {
+  ACTIVE = 1,   // 活跃
+  DISABLED = 2
+}
+
+struct User   {
+    1: required i64    id
+    2: string name  // 用户名
+}
+
+
+/* 分组 */
+struct Group {
+  1: list<User> members
+}
+
+service UserService {
+  User getUser(1: i64 id)
+}
+`
+
+func parseRoundTripSource(t *testing.T, opts ...thriftparser.Option) *idl_ast.IDLSchema {
+	t.Helper()
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{
+		"user.thrift":   []byte(roundTripSource),
+		"common.thrift": []byte("struct Base {\n  1: string id\n}\n"),
+	}, opts...)
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+	return schema
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	schema := parseRoundTripSource(t)
+	out, err := Generate(schema, WithRoundTrip(true))
+	require.NoError(t, err)
+	assert.Equal(t, roundTripSource, string(out["user.thrift"]))
+
+	file := &schema.Files[0]
+	if file.Path != "user.thrift" {
+		file = &schema.Files[1]
+	}
+	users := schema.FindStructsByFQN("user.thrift#User")
+	require.Len(t, users, 1)
+	users[0].Fields[1].Name = "nickname"
+
+	// 删除 Group，新增一个 struct、一个 include 和一个 namespace
+	file.Definitions.Messages = file.Definitions.Messages[:1]
+	file.Definitions.Messages = append(file.Definitions.Messages, idl_ast.Message{
+		Name: "Extra",
+		Type: "struct",
+		Fields: []idl_ast.Field{
+			{ID: 1, Name: "base", Type: idl_ast.Type{Name: "other.Base"}, Required: "optional"},
+		},
+	})
+	file.Imports = append(file.Imports, idl_ast.Import{Value: `"other.thrift"`, Path: "other.thrift"})
+	file.Namespaces = append(file.Namespaces, idl_ast.Namespace{Scope: "java", Name: "example.user"})
+
+	out, err = Generate(schema, WithRoundTrip(true))
+	require.NoError(t, err)
+	assert.Equal(t, `namespace go example.user
+namespace java example.user
+
+include "common.thrift"
+include "other.thrift"
+
+// 用户状态
+enum Status {
+  ACTIVE = 1,   // 活跃
+  DISABLED = 2
+}
+
+struct User {
+    1: required i64 id,
+    2: string nickname,
+}
+
+service UserService {
+  User getUser(1: i64 id)
+}
+
+struct Extra {
+    1: other.Base base,
+}
+`, string(out["user.thrift"]))
+
+	// 默认模式不受影响
+	out, err = Generate(schema)
+	require.NoError(t, err)
+	assert.Contains(t, string(out["user.thrift"]), "enum Status {\n    ACTIVE = 1,")
+}
+
+func TestWriter_RoundTripNoComments(t *testing.T) {
+	schema := parseRoundTripSource(t, thriftparser.WithNoComments(true))
+	groups := schema.FindStructsByFQN("user.thrift#Group")
+	require.Len(t, groups, 1)
+	assert.True(t, strings.HasPrefix(groups[0].Content, "struct Group"))
+
+	out, err := Generate(schema, WithRoundTrip(true))
+	require.NoError(t, err)
+	assert.NotContains(t, string(out["user.thrift"]), "用户状态")
+	assert.Contains(t, string(out["user.thrift"]), "struct Group")
+}
+
+func TestWriter_RoundTripCorrectedSource(t *testing.T) {
+	// 解析器需要修正 Map 关键词和没有值的注解才能解析这个文件。
+	source := `struct A {
+  1: Map<string,string> m (cpp.bare)
+}
+
+struct B {
+  1: string b
+}
+`
+	parser, err := thriftparser.NewParserFromMap("project", map[string][]byte{"main.thrift": []byte(source)})
+	require.NoError(t, err)
+	schema, err := parser.ParseIDLs()
+	require.NoError(t, err)
+	assert.Equal(t, source, schema.Files[0].Content)
+
+	out, err := Generate(schema, WithRoundTrip(true))
+	require.NoError(t, err)
+	assert.Equal(t, source, string(out["main.thrift"]))
+
+	b := schema.FindStructsByFQN("main.thrift#B")
+	require.Len(t, b, 1)
+	b[0].Fields[0].Name = "renamed"
+
+	out, err = Generate(schema, WithRoundTrip(true))
+	require.NoError(t, err)
+	assert.Equal(t, `struct A {
+  1: Map<string,string> m (cpp.bare)
+}
+
+struct B {
+    1: string renamed,
+}
+`, string(out["main.thrift"]))
+}
+
 func writeFiles(outputDir string, generatedFiles map[string][]byte) {
 	for relativePath, content := range generatedFiles {
 		destPath := filepath.Join(outputDir, relativePath)